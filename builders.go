@@ -0,0 +1,118 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tfortools
+
+import (
+	"text/template"
+)
+
+func list(items ...interface{}) []interface{} {
+	return items
+}
+
+func dict(keyValues ...interface{}) map[string]interface{} {
+	if len(keyValues)%2 != 0 {
+		fatalArity("dict", "an even number of arguments expected")
+	}
+
+	m := make(map[string]interface{}, len(keyValues)/2)
+	for i := 0; i < len(keyValues); i += 2 {
+		key, ok := keyValues[i].(string)
+		if !ok {
+			fatalType("dict", "key %d is not a string", i/2)
+		}
+		m[key] = keyValues[i+1]
+	}
+	return m
+}
+
+func (e *env) seq(bounds ...int) []int {
+	start, end, step := 0, 0, 1
+	switch len(bounds) {
+	case 1:
+		end = bounds[0]
+	case 2:
+		start, end = bounds[0], bounds[1]
+	case 3:
+		start, end, step = bounds[0], bounds[1], bounds[2]
+	default:
+		fatalArity("seq", "between one and three arguments expected")
+	}
+	if step == 0 {
+		fatalf("seq", "step must not be zero")
+	}
+
+	n := seqLen(start, end, step)
+	if e.limits.sliceLen > 0 && n > uint64(e.limits.sliceLen) {
+		exceeded("seq", ResourceSliceLen, int64(e.limits.sliceLen))
+	}
+	if n > uint64(maxInt) {
+		fatalf("seq", "sequence of %d integers is too long", n)
+	}
+
+	s := make([]int, n)
+	for i := range s {
+		e.checkContext("seq")
+		s[i] = start + i*step
+	}
+	return s
+}
+
+// seqLen returns the number of integers in the sequence that starts at
+// start and ends before end, in increments of step.  The differences are
+// computed as unsigned integers so that they cannot overflow.
+func seqLen(start, end, step int) uint64 {
+	switch {
+	case step > 0 && end > start:
+		return (uint64(end-start)-1)/uint64(step) + 1
+	case step < 0 && start > end:
+		return (uint64(start-end)-1)/uint64(-step) + 1
+	}
+	return 0
+}
+
+// isEmpty returns true if obj would be considered false by the if action.
+func isEmpty(obj interface{}) bool {
+	if f, ok := obj.(formattedValue); ok {
+		obj = f.value
+	}
+	truth, _ := template.IsTrue(obj)
+	return !truth
+}
+
+func defaultValue(obj, def interface{}) interface{} {
+	if isEmpty(obj) {
+		return def
+	}
+	return obj
+}
+
+func coalesce(objs ...interface{}) interface{} {
+	for _, obj := range objs {
+		if !isEmpty(obj) {
+			return obj
+		}
+	}
+	return nil
+}
+
+func ternary(cond, whenTrue, whenFalse interface{}) interface{} {
+	if isEmpty(cond) {
+		return whenFalse
+	}
+	return whenTrue
+}
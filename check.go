@@ -0,0 +1,687 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tfortools
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// CheckError describes a single problem found by Check.  Line and Column
+// identify the position of the problem in the template source.
+type CheckError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e CheckError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Msg)
+}
+
+// CheckErrors is the error returned by Check when it finds problems in a
+// template.  It contains one CheckError for each problem found, in the order
+// in which the problems appear in the template.
+type CheckErrors []CheckError
+
+func (e CheckErrors) Error() string {
+	var buf bytes.Buffer
+	for i, err := range e {
+		if i > 0 {
+			_ = buf.WriteByte('\n')
+		}
+		_, _ = buf.WriteString(err.Error())
+	}
+	return buf.String()
+}
+
+// preservingFns lists the functions that return a value of the same type as
+// their first argument.
+var preservingFns = map[string]bool{
+	"filter": true, "filterContains": true, "filterHasPrefix": true,
+	"filterHasSuffix": true, "filterFolded": true, "filterRegexp": true,
+	"filterNil": true, "filterNotNil": true, "sort": true, "rows": true,
+	"head": true, "tail": true, "reverse": true, "skip": true, "subslice": true,
+	"shuffle": true, "sample": true, "union": true, "intersect": true,
+	"difference": true,
+}
+
+// fieldArgs maps the names of the functions that accept a field path to
+// the index of the argument that contains it.  The path is resolved against
+// the element type of the function's first argument.
+var fieldArgs = map[string]int{
+	"filter": 1, "filterContains": 1, "filterHasPrefix": 1,
+	"filterHasSuffix": 1, "filterFolded": 1, "filterRegexp": 1,
+	"filterNil": 1, "filterNotNil": 1, "sort": 1, "select": 1,
+	"selectalt": 1, "promote": 1, "rank": 1, "union": 2, "intersect": 2,
+	"difference": 2,
+}
+
+// builtinArity records the minimum and maximum number of arguments, -1
+// meaning unlimited, accepted by the functions built into Go's template
+// language.
+var builtinArity = map[string][2]int{
+	"and": {1, -1}, "or": {1, -1}, "not": {1, 1}, "len": {1, 1},
+	"index": {1, -1}, "slice": {1, 4}, "call": {1, -1}, "print": {0, -1},
+	"printf": {1, -1}, "println": {0, -1}, "html": {0, -1}, "js": {0, -1},
+	"urlquery": {0, -1}, "eq": {2, -1}, "ne": {2, 2}, "lt": {2, 2},
+	"le": {2, 2}, "gt": {2, 2}, "ge": {2, 2},
+}
+
+var (
+	boolType   = reflect.TypeOf(false)
+	stringType = reflect.TypeOf("")
+)
+
+type checkVar struct {
+	name string
+	typ  reflect.Type
+}
+
+// checker walks the parse trees of a template inferring the type of dot,
+// variables and pipelines.  A nil reflect.Type denotes a value whose type
+// is not known until the template is executed.  Nothing is reported about
+// such values.
+type checker struct {
+	tmpl    *template.Template
+	funcs   template.FuncMap
+	tree    *parse.Tree
+	vars    []checkVar
+	checked map[string]map[reflect.Type]bool
+	errs    CheckErrors
+
+	// sandbox, if not nil, identifies the methods that can be called.
+	// If sandboxOnly is true, only calls to methods that are not
+	// permitted are reported.  guards records the nodes whose fields
+	// cannot be checked statically, and the index of the first such
+	// field.
+	sandbox     *sandbox
+	sandboxOnly bool
+	guards      map[parse.Node]int
+}
+
+// maxCheckedTypes is the number of different types of dot with which a
+// template is checked before it is checked with an unknown dot.  This
+// prevents templates that invoke themselves recursively, deriving a new
+// type of dot each time, from being checked forever.
+const maxCheckedTypes = 8
+
+func (c *checker) errorf(n parse.Node, format string, args ...interface{}) {
+	if !c.sandboxOnly {
+		c.report(n, format, args...)
+	}
+}
+
+func (c *checker) report(n parse.Node, format string, args ...interface{}) {
+	err := CheckError{Msg: fmt.Sprintf(format, args...)}
+	location, _ := c.tree.ErrorContext(n)
+	parts := strings.Split(location, ":")
+	if len(parts) >= 2 {
+		err.Line, _ = strconv.Atoi(parts[len(parts)-2])
+		err.Column, _ = strconv.Atoi(parts[len(parts)-1])
+	}
+
+	// Templates checked with several types of dot may report the same
+	// problem more than once.
+
+	for _, e := range c.errs {
+		if e == err {
+			return
+		}
+	}
+	c.errs = append(c.errs, err)
+}
+
+// guard records that the fields of n, from the ith field onwards, must be
+// checked by the sandbox when the template is executed.
+func (c *checker) guard(n parse.Node, i int) {
+	if c.sandbox == nil {
+		return
+	}
+	if j, ok := c.guards[n]; !ok || i < j {
+		c.guards[n] = i
+	}
+}
+
+func (c *checker) lookupVar(name string) reflect.Type {
+	for i := len(c.vars) - 1; i >= 0; i-- {
+		if c.vars[i].name == name {
+			return c.vars[i].typ
+		}
+	}
+	return nil
+}
+
+func (c *checker) setVar(name string, typ reflect.Type) {
+	for i := len(c.vars) - 1; i >= 0; i-- {
+		if c.vars[i].name == name {
+			if c.vars[i].typ != typ {
+				c.vars[i].typ = nil
+			}
+			return
+		}
+	}
+}
+
+// elemType returns the type of the elements of t, if t is a slice, array,
+// map or channel, and nil otherwise.
+func elemType(t reflect.Type) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	t = derefType(t)
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+		return t.Elem()
+	}
+	return nil
+}
+
+// fieldType returns the type of the field, method or map element called
+// name of a value of type t.  ok is false if t has no such field.
+func fieldType(t reflect.Type, name string) (ft reflect.Type, ok bool) {
+	if t == nil || t.Kind() == reflect.Interface {
+		return nil, true
+	}
+	if m, ok := templateMethod(t, name); ok {
+		return m.Type.Out(0), true
+	}
+
+	t = derefType(t)
+	switch t.Kind() {
+	case reflect.Interface:
+		return nil, true
+	case reflect.Map:
+		if t.Key().Kind() == reflect.String {
+			return t.Elem(), true
+		}
+	case reflect.Struct:
+		if m, ok := templateMethod(reflect.PtrTo(t), name); ok {
+			return m.Type.Out(0), true
+		}
+		if sf, found := t.FieldByName(name); found && sf.PkgPath == "" {
+			return sf.Type, true
+		}
+	}
+	return t, false
+}
+
+// fieldChain resolves the fields in idents starting from a value of type t.
+func (c *checker) fieldChain(n parse.Node, t reflect.Type, idents []string) reflect.Type {
+	for i, id := range idents {
+		if c.sandbox.denied(t, id) {
+			c.report(n, "method %s of type %s is not permitted", id, t)
+			return nil
+		}
+		if t == nil || derefType(t).Kind() == reflect.Interface {
+			c.guard(n, i)
+			return nil
+		}
+		ft, ok := fieldType(t, id)
+		if !ok {
+			c.errorf(n, "can't evaluate field %s in type %s%s", id, ft,
+				fieldHint(id, pathNames(reflect.Value{}, t)))
+			c.guard(n, i)
+			return nil
+		}
+		t = ft
+	}
+	return t
+}
+
+func (c *checker) arg(dot reflect.Type, n parse.Node) reflect.Type {
+	switch n := n.(type) {
+	case *parse.DotNode:
+		return dot
+	case *parse.FieldNode:
+		return c.fieldChain(n, dot, n.Ident)
+	case *parse.VariableNode:
+		return c.fieldChain(n, c.lookupVar(n.Ident[0]), n.Ident[1:])
+	case *parse.ChainNode:
+		return c.fieldChain(n, c.arg(dot, n.Node), n.Field)
+	case *parse.PipeNode:
+		return c.pipe(dot, n, false)
+	case *parse.BoolNode:
+		return boolType
+	case *parse.StringNode:
+		return stringType
+	case *parse.NumberNode:
+		switch {
+		case n.IsInt:
+			return reflect.TypeOf(0)
+		case n.IsFloat:
+			return reflect.TypeOf(0.0)
+		}
+	case *parse.IdentifierNode:
+		return c.call(dot, n, nil, nil, false)
+	}
+	return nil
+}
+
+// checkArity reports an error if the function called name, which accepts
+// between min and max arguments, cannot be called with got arguments.
+func (c *checker) checkArity(n parse.Node, name string, min, max, got int) bool {
+	switch {
+	case max == -1 && got < min:
+		c.errorf(n, "wrong number of args for %s: want at least %d got %d", name, min, got)
+	case max != -1 && (got < min || got > max) && min == max:
+		c.errorf(n, "wrong number of args for %s: want %d got %d", name, min, got)
+	case max != -1 && (got < min || got > max):
+		c.errorf(n, "wrong number of args for %s: want %d to %d got %d", name, min, max, got)
+	default:
+		return true
+	}
+	return false
+}
+
+// checkFieldArg reports an error if the string literal arg does not name a
+// field path of the elements of a collection of type t.
+func (c *checker) checkFieldArg(name string, t reflect.Type, arg parse.Node) {
+	s, ok := arg.(*parse.StringNode)
+	et := elemType(t)
+	if !ok || et == nil {
+		return
+	}
+	for _, seg := range strings.Split(s.Text, ".") {
+		et = derefType(et)
+		switch et.Kind() {
+		case reflect.Map, reflect.Interface:
+			return
+		case reflect.Struct:
+			if sf, found := et.FieldByName(seg); found {
+				et = sf.Type
+				continue
+			}
+		}
+		c.errorf(arg, "%s: %s is not a valid field name%s", name, seg,
+			fieldHint(seg, exportedFieldNames(et)))
+		return
+	}
+}
+
+// stringArgs returns the values of args if they are all string literals.
+func stringArgs(args []parse.Node) ([]string, bool) {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		s, ok := arg.(*parse.StringNode)
+		if !ok {
+			return nil, false
+		}
+		strs[i] = s.Text
+	}
+	return strs, true
+}
+
+// resultType infers the type returned by the tfortools function called
+// name, whose declared return type is rt, from the types and values of its
+// arguments.
+func (c *checker) resultType(n parse.Node, name string, rt reflect.Type, types []reflect.Type, args []parse.Node) reflect.Type {
+	if rt.Kind() != reflect.Interface {
+		return rt
+	}
+	if len(types) == 0 || types[0] == nil {
+		return nil
+	}
+
+	t := types[0]
+	et := elemType(t)
+	switch {
+	case preservingFns[name]:
+		return t
+	case name == "default" && len(types) == 2 && types[1] == t:
+		return t
+	case name == "sliceof":
+		return reflect.SliceOf(t)
+	case et == nil:
+		return nil
+	}
+
+	st := derefType(et)
+	switch name {
+	case "cols":
+		fields, ok := stringArgs(args[1:])
+		if !ok || st.Kind() != reflect.Struct {
+			return t
+		}
+		for i, f := range fields {
+			if sf, found := st.FieldByName(f); !found || sf.PkgPath != "" || len(sf.Index) > 1 {
+				c.errorf(args[i+1], "cols: %s is not a valid field name%s", f,
+					fieldHint(f, exportedFieldNames(st)))
+				return nil
+			}
+		}
+		newTyp, _ := structCols(st, fields)
+		return reflect.SliceOf(newTyp)
+	case "promote":
+		path, ok := stringArgs(args[1:2])
+		if !ok || st.Kind() != reflect.Struct {
+			return nil
+		}
+		ft, err := catchExecError(func() interface{} {
+			return findFieldType(name, strings.Split(path[0], "."), st)
+		})
+		if err != nil {
+			return nil
+		}
+		return reflect.SliceOf(ft.(reflect.Type))
+	case "enumerate", "rank":
+		col := []string{"Rank"}
+		if name == "enumerate" {
+			var ok bool
+			if col, ok = stringArgs(args[1:2]); !ok {
+				return nil
+			}
+		}
+		if st.Kind() != reflect.Struct {
+			return nil
+		}
+		ct, err := catchExecError(func() interface{} {
+			return newColumnAdder(name, col[0]).structType(st)
+		})
+		if err != nil {
+			c.errorf(n, "%s: %v", name, err)
+			return nil
+		}
+		return reflect.SliceOf(ct.(reflect.Type))
+	case "keys":
+		return reflect.SliceOf(derefType(t).Key())
+	case "values":
+		return reflect.SliceOf(et)
+	case "entries":
+		return reflect.SliceOf(reflect.StructOf([]reflect.StructField{
+			{Name: "Key", Type: derefType(t).Key()},
+			{Name: "Value", Type: et},
+		}))
+	case "chunk":
+		return reflect.SliceOf(reflect.SliceOf(et))
+	case "flattenSlices":
+		if inner := elemType(et); inner != nil && st.Kind() != reflect.Map {
+			return reflect.SliceOf(inner)
+		}
+	case "zip":
+		fields := make([]reflect.StructField, len(types))
+		for i, t := range types {
+			if elemType(t) == nil {
+				return nil
+			}
+			fields[i] = reflect.StructField{
+				Name: "Item" + strconv.Itoa(i+1),
+				Type: elemType(t),
+			}
+		}
+		return reflect.SliceOf(reflect.StructOf(fields))
+	}
+	return nil
+}
+
+// call checks a call to the function id with the arguments args.  If final
+// is true, the function is also passed the result of the previous command
+// in the pipeline, whose type is finalType.
+func (c *checker) call(dot reflect.Type, id *parse.IdentifierNode, args []parse.Node, finalType reflect.Type, final bool) reflect.Type {
+	types := make([]reflect.Type, 0, len(args)+1)
+	for _, arg := range args {
+		types = append(types, c.arg(dot, arg))
+	}
+	if final {
+		types = append(types, finalType)
+	}
+	got := len(types)
+
+	fn, ok := c.funcs[id.Ident]
+	if !ok {
+		arity, ok := builtinArity[id.Ident]
+		if !ok || !c.checkArity(id, id.Ident, arity[0], arity[1], got) {
+			return nil
+		}
+		switch id.Ident {
+		case "not", "eq", "ne", "lt", "le", "gt", "ge":
+			return boolType
+		case "len":
+			return reflect.TypeOf(0)
+		case "print", "printf", "println", "html", "js", "urlquery":
+			return stringType
+		case "slice":
+			return types[0]
+		case "index":
+			t := types[0]
+			for i := 1; i < got && t != nil; i++ {
+				t = elemType(t)
+			}
+			return t
+		}
+		return nil
+	}
+
+	ft := reflect.TypeOf(fn)
+	min, max := ft.NumIn(), ft.NumIn()
+	if ft.IsVariadic() {
+		min, max = min-1, -1
+	}
+	if !c.checkArity(id, id.Ident, min, max, got) {
+		return nil
+	}
+
+	if i, ok := fieldArgs[id.Ident]; ok && i < len(args) && got > 0 {
+		c.checkFieldArg(id.Ident, types[0], args[i])
+	}
+
+	if ft.NumOut() == 0 {
+		return nil
+	}
+	return c.resultType(id, id.Ident, ft.Out(0), types, args)
+}
+
+func (c *checker) command(dot reflect.Type, cmd *parse.CommandNode, finalType reflect.Type, final bool) reflect.Type {
+	first := cmd.Args[0]
+	if id, ok := first.(*parse.IdentifierNode); ok {
+		return c.call(dot, id, cmd.Args[1:], finalType, final)
+	}
+
+	for _, arg := range cmd.Args[1:] {
+		c.arg(dot, arg)
+	}
+	return c.arg(dot, first)
+}
+
+// pipe checks the pipeline p and returns its type.  Any variables declared
+// by p are added to the current scope, unless p belongs to a range action,
+// in which case the caller declares them.
+func (c *checker) pipe(dot reflect.Type, p *parse.PipeNode, isRange bool) reflect.Type {
+	if p == nil {
+		return nil
+	}
+
+	var t reflect.Type
+	for i, cmd := range p.Cmds {
+		t = c.command(dot, cmd, t, i > 0)
+	}
+
+	if isRange {
+		return t
+	}
+	for _, v := range p.Decl {
+		if p.IsAssign {
+			c.setVar(v.Ident[0], t)
+		} else {
+			c.vars = append(c.vars, checkVar{v.Ident[0], t})
+		}
+	}
+	return t
+}
+
+// rangeTypes returns the types of the keys and elements produced by
+// ranging over a value of type t.
+func rangeTypes(t reflect.Type) (reflect.Type, reflect.Type) {
+	if t == nil {
+		return nil, nil
+	}
+	t = derefType(t)
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return reflect.TypeOf(0), t.Elem()
+	case reflect.Map:
+		return t.Key(), t.Elem()
+	case reflect.Chan:
+		return nil, t.Elem()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return nil, t
+	}
+	return nil, nil
+}
+
+func (c *checker) walk(dot reflect.Type, n parse.Node) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, node := range n.Nodes {
+			c.walk(dot, node)
+		}
+	case *parse.ActionNode:
+		c.pipe(dot, n.Pipe, false)
+	case *parse.IfNode:
+		c.branch(dot, &n.BranchNode, dot)
+	case *parse.WithNode:
+		c.branch(dot, &n.BranchNode, nil)
+	case *parse.RangeNode:
+		mark := len(c.vars)
+		t := c.pipe(dot, n.Pipe, true)
+		key, elem := rangeTypes(t)
+		switch len(n.Pipe.Decl) {
+		case 1:
+			c.vars = append(c.vars, checkVar{n.Pipe.Decl[0].Ident[0], elem})
+		case 2:
+			c.vars = append(c.vars,
+				checkVar{n.Pipe.Decl[0].Ident[0], key},
+				checkVar{n.Pipe.Decl[1].Ident[0], elem})
+		}
+		c.walk(elem, n.List)
+		c.vars = c.vars[:mark]
+		c.walk(dot, n.ElseList)
+	case *parse.TemplateNode:
+		t := c.pipe(dot, n.Pipe, false)
+		c.template(n.Name, t)
+	}
+}
+
+// branch checks an if or with action.  For if actions, the body is checked
+// using the type bodyDot.  For with actions, bodyDot is nil and the body is
+// checked using the type of the pipeline.
+func (c *checker) branch(dot reflect.Type, b *parse.BranchNode, bodyDot reflect.Type) {
+	mark := len(c.vars)
+	t := c.pipe(dot, b.Pipe, false)
+	if b.NodeType == parse.NodeWith {
+		bodyDot = t
+	}
+	c.walk(bodyDot, b.List)
+	c.vars = c.vars[:mark]
+	c.walk(dot, b.ElseList)
+}
+
+// template checks the template called name with dot set to the type dot.
+// A template is checked once for each type of dot with which it is
+// invoked.
+func (c *checker) template(name string, dot reflect.Type) {
+	t := c.tmpl.Lookup(name)
+	if t == nil || t.Tree == nil {
+		return
+	}
+	checked := c.checked[name]
+	if checked == nil {
+		checked = make(map[reflect.Type]bool)
+		c.checked[name] = checked
+	}
+	if len(checked) >= maxCheckedTypes {
+		dot = nil
+	}
+	if checked[dot] {
+		return
+	}
+	checked[dot] = true
+
+	tree, vars := c.tree, c.vars
+	c.tree, c.vars = t.Tree, []checkVar{{"$", dot}}
+	c.walk(dot, t.Tree.Root)
+	c.tree, c.vars = tree, vars
+}
+
+// Check parses the template source tmplSrc and checks it against the type
+// of sampleType, which may be a value of the type passed to the template
+// or a reflect.Type.  Check infers the type of dot through range and with
+// actions, and through the functions provided by tfortools that preserve
+// or derive the types of their inputs, such as cols, filter and promote.
+// It reports references to unknown fields, unknown field names passed to
+// functions such as sort and filter, and calls to functions with the wrong
+// number of arguments.  If sandbox mode is enabled in cfg, calls to methods
+// that templates are not permitted to call are also reported.  The
+// functions enabled in the cfg parameter are made available to the
+// template.  If cfg is nil, all the additional functions provided by
+// tfortools are enabled.
+//
+// If the template cannot be parsed, the parse error is returned.
+// Otherwise, if problems are found, Check returns a CheckErrors value
+// describing each problem.  Values whose types are only known at runtime,
+// such as the elements of a []interface{}, cannot be checked.
+func Check(tmplSrc string, sampleType interface{}, cfg *Config) error {
+	funcs := getFuncMap(cfg)
+	tmpl, err := template.New("check").Funcs(funcs).Parse(tmplSrc)
+	if err != nil {
+		return suggestFunction(err, cfg)
+	}
+
+	t, ok := sampleType.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(sampleType)
+	}
+
+	if errs, _ := checkTemplate(tmpl, funcs, t, configSandbox(cfg), false); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkTemplate checks tmpl, and the templates associated with it, against
+// the type t, returning the errors found.  If sandboxOnly is true, only calls
+// to methods not permitted by sb are reported.  If sb is not nil, the nodes
+// whose fields must be checked when the template is executed are also
+// returned.  Associated templates that are not invoked by tmpl are checked
+// with an unknown dot, as are all the templates if t is nil, as they may be
+// executed directly.
+func checkTemplate(tmpl *template.Template, funcs template.FuncMap, t reflect.Type,
+	sb *sandbox, sandboxOnly bool) (CheckErrors, map[parse.Node]int) {
+	c := &checker{
+		tmpl:        tmpl,
+		funcs:       funcs,
+		checked:     make(map[string]map[reflect.Type]bool),
+		sandbox:     sb,
+		sandboxOnly: sandboxOnly,
+		guards:      make(map[parse.Node]int),
+	}
+	c.template(tmpl.Name(), t)
+	for _, associated := range tmpl.Templates() {
+		if t == nil || len(c.checked[associated.Name()]) == 0 {
+			c.template(associated.Name(), nil)
+		}
+	}
+	return c.errs, c.guards
+}
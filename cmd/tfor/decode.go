@@ -0,0 +1,124 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/intel/tfortools"
+)
+
+// formatFromPath returns the format of the file at path based on its
+// extension.  An empty string is returned if the extension is not
+// recognised, in which case the format is detected from the data.
+func formatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	case ".csv":
+		return "csv"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	return ""
+}
+
+// detectFormat guesses the format of data.  JSON documents start with an
+// object or an array.  Anything else is assumed to be YAML, of which JSON
+// is a subset.
+func detectFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return "json"
+	}
+	return "yaml"
+}
+
+// decodeJSON decodes one or more JSON values from data.  If data contains a
+// single value, that value is returned.  Otherwise, as is the case for
+// NDJSON, a slice containing all the values is returned.
+func decodeJSON(data []byte, stream bool) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var values []interface{}
+	for {
+		var v interface{}
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+
+	if len(values) == 1 && !stream {
+		return values[0], nil
+	}
+	if values == nil {
+		values = []interface{}{}
+	}
+	return values, nil
+}
+
+// decode reads data in the given format from r and converts it into a
+// value that can be passed to a template.  JSON and YAML data are converted
+// into structures whose types are inferred from the data.  CSV data are
+// converted using tfortools.ToTable.
+func decode(r io.Reader, format string) (interface{}, error) {
+	if format == "csv" {
+		records, err := csv.NewReader(bufio.NewReader(r)).ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		return tfortools.ToTable(records)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = detectFormat(data)
+	}
+
+	var v interface{}
+	switch format {
+	case "json", "ndjson":
+		v, err = decodeJSON(data, format == "ndjson")
+	case "yaml":
+		v, err = tfortools.DecodeYAML(data)
+	default:
+		return nil, fmt.Errorf("unknown format %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return toStructs(v), nil
+}
@@ -0,0 +1,176 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// tfor executes a template, written using the functions provided by
+// tfortools, on JSON, NDJSON, CSV or YAML data read from files or from
+// the standard input.  For example,
+//
+//	kubectl get pods -o json | tfor -f '{{table (cols (promote .Items "Metadata") "Name" "Namespace")}}'
+//
+// The data are converted into slices of structures whose types are inferred
+// from the data, so they can be passed directly to functions such as sort
+// and table.  The names of the fields of these structures are derived from
+// the keys found in the data, converted into exported Go identifiers, e.g.,
+// the key metadata becomes the field Metadata.  The inferred types can be
+// displayed using the --describe flag.
+//
+// Templates can be developed interactively by running
+//
+//	tfor repl [-format format] file
+//
+// which loads the data in file and evaluates each line entered on the
+// standard input as a template.  Type :help inside the REPL for more
+// information.  When the standard input is a terminal, the REPL provides
+// line editing, completes function names and field paths when the tab key
+// is pressed, and saves its history in ~/.tfor_history.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/intel/tfortools"
+)
+
+var (
+	code     string
+	format   string
+	describe bool
+)
+
+// cfg enables all the functions provided by tfortools.
+var cfg = tfortools.NewConfig(tfortools.OptAllFns)
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-f template] [-format format] [--describe] [file...]\n",
+			os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s repl [-format format] file\n", os.Args[0])
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Data are read from the standard input if no files are specified.")
+		fmt.Fprintln(os.Stderr)
+		flag.PrintDefaults()
+	}
+	flag.StringVar(&code, "f", "{{tojson .}}", "string containing the template code to execute")
+	flag.StringVar(&format, "format", "",
+		"format of the input data, one of json, ndjson, csv or yaml.  By default the\n"+
+			"format is derived from the file extension, or detected if reading from the\n"+
+			"standard input")
+	flag.BoolVar(&describe, "describe", false,
+		"describe the type of the input data and the functions that can be used in templates")
+}
+
+func applyTemplate(w io.Writer, name string, data interface{}) error {
+	if describe {
+		_, err := fmt.Fprintln(w, tfortools.GenerateUsageDecorated("f", data, cfg))
+		return err
+	}
+
+	return tfortools.OutputToTemplate(w, name, code, data, cfg)
+}
+
+func decodeFile(path string) (interface{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open %s : %v", path, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	fileFormat := format
+	if fileFormat == "" {
+		fileFormat = formatFromPath(path)
+	}
+	data, err := decode(f, fileFormat)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read %s : %v", path, err)
+	}
+	return data, nil
+}
+
+func runREPL(path string) error {
+	data, err := decodeFile(path)
+	if err != nil {
+		return err
+	}
+
+	// Line editing and a persistent history are only provided when the
+	// standard input is a terminal.
+
+	restore, err := makeRaw(os.Stdin.Fd())
+	if err != nil {
+		return tfortools.REPL(os.Stdin, os.Stdout, data, cfg)
+	}
+	defer restore()
+
+	tfortools.OptREPLLineEditing(cfg)
+	if home, err := os.UserHomeDir(); err == nil {
+		tfortools.OptREPLHistory(filepath.Join(home, ".tfor_history"))(cfg)
+	}
+	return tfortools.REPL(os.Stdin, os.Stdout, data, cfg)
+}
+
+func run() error {
+	w := bufio.NewWriter(os.Stdout)
+	defer func() {
+		_ = w.Flush()
+	}()
+
+	if len(flag.Args()) == 0 {
+		data, err := decode(os.Stdin, format)
+		if err != nil {
+			return fmt.Errorf("Unable to read stdin : %v", err)
+		}
+		return applyTemplate(w, "stdin", data)
+	}
+
+	for _, path := range flag.Args() {
+		data, err := decodeFile(path)
+		if err != nil {
+			return err
+		}
+		if err := applyTemplate(w, path, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+
+	var err error
+	if flag.Arg(0) == "repl" {
+		_ = flag.CommandLine.Parse(flag.Args()[1:])
+		if len(flag.Args()) != 1 {
+			flag.Usage()
+			os.Exit(1)
+		}
+		err = runREPL(flag.Arg(0))
+	} else {
+		err = run()
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
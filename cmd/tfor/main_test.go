@@ -0,0 +1,77 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/intel/tfortools"
+)
+
+const podsJSON = `{
+	"apiVersion": "v1",
+	"kind": "List",
+	"items": [
+		{
+			"kind": "Pod",
+			"metadata": {"name": "web-1", "namespace": "default", "labels": {"app": "web"}},
+			"status": {"phase": "Running"}
+		},
+		{
+			"kind": "Pod",
+			"metadata": {"name": "db-0", "namespace": "prod"},
+			"status": {"phase": "Pending"}
+		}
+	]
+}`
+
+var exampleRE = regexp.MustCompile(`tfor -f '([^']*)'`)
+
+// TestDocExamples executes each of the templates passed to tfor -f in the
+// package documentation on the output of kubectl get pods -o json.
+func TestDocExamples(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", nil,
+		parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Unable to parse main.go: %v", err)
+	}
+
+	examples := exampleRE.FindAllStringSubmatch(f.Doc.Text(), -1)
+	if len(examples) == 0 {
+		t.Fatal("No examples found in the package documentation")
+	}
+
+	for _, ex := range examples {
+		data, err := decode(strings.NewReader(podsJSON), "json")
+		if err != nil {
+			t.Fatalf("Unable to decode pods: %v", err)
+		}
+		var b bytes.Buffer
+		if err := tfortools.OutputToTemplate(&b, "doc", ex[1], data, cfg); err != nil {
+			t.Errorf("%s: unexpected error: %v", ex[1], err)
+			continue
+		}
+		if b.Len() == 0 {
+			t.Errorf("%s: no output", ex[1])
+		}
+	}
+}
@@ -0,0 +1,304 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/intel/tfortools"
+)
+
+type shapeKind int
+
+const (
+	shapeNull shapeKind = iota
+	shapeBool
+	shapeInt
+	shapeFloat
+	shapeString
+	shapeObject
+	shapeArray
+	shapeMixed
+)
+
+// shape describes the type inferred for a set of decoded JSON or YAML
+// values.  A shape is nullable if one or more of the values it describes
+// are null or, in the case of object fields, missing.
+type shape struct {
+	kind     shapeKind
+	nullable bool
+	keys     []string
+	fields   map[string]*shape
+	elem     *shape
+}
+
+func scalarKind(v interface{}) shapeKind {
+	switch v := v.(type) {
+	case nil:
+		return shapeNull
+	case bool:
+		return shapeBool
+	case int, int64:
+		return shapeInt
+	case float64:
+		return shapeFloat
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return shapeInt
+		}
+		return shapeFloat
+	case string:
+		return shapeString
+	}
+	return shapeMixed
+}
+
+// objectFields returns the keys of v, which must be a map decoded from JSON
+// or YAML, in the order in which they should appear in the generated
+// structure.
+func objectFields(v interface{}) ([]string, map[string]interface{}, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, nil, false
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, m, true
+}
+
+func arrayElements(v interface{}) ([]interface{}, bool) {
+	switch v := v.(type) {
+	case []interface{}:
+		return v, true
+	case []map[string]interface{}:
+		a := make([]interface{}, len(v))
+		for i := range v {
+			a[i] = v[i]
+		}
+		return a, true
+	}
+	return nil, false
+}
+
+// add merges the shape of v into s.
+func (s *shape) add(v interface{}) {
+	k := scalarKind(v)
+	if k == shapeMixed {
+		if _, _, ok := objectFields(v); ok {
+			k = shapeObject
+		} else if _, ok := arrayElements(v); ok {
+			k = shapeArray
+		}
+	}
+
+	if k == shapeNull {
+		s.nullable = true
+		return
+	}
+
+	switch {
+	case s.kind == shapeNull:
+		s.kind = k
+	case s.kind == shapeMixed:
+		return
+	case s.kind == k:
+	case (s.kind == shapeInt && k == shapeFloat) || (s.kind == shapeFloat && k == shapeInt):
+		s.kind = shapeFloat
+		return
+	default:
+		s.kind = shapeMixed
+		s.keys, s.fields, s.elem = nil, nil, nil
+		return
+	}
+
+	switch k {
+	case shapeObject:
+		s.addObject(v)
+	case shapeArray:
+		if s.elem == nil {
+			s.elem = &shape{}
+		}
+		a, _ := arrayElements(v)
+		for _, e := range a {
+			s.elem.add(e)
+		}
+	}
+}
+
+func (s *shape) addObject(v interface{}) {
+	keys, m, _ := objectFields(v)
+	first := s.fields == nil
+	if first {
+		s.fields = make(map[string]*shape)
+	}
+	for _, k := range keys {
+		f, ok := s.fields[k]
+		if !ok {
+			f = &shape{nullable: !first}
+			s.fields[k] = f
+			s.keys = append(s.keys, k)
+		}
+		f.add(m[k])
+	}
+	for _, k := range s.keys {
+		if _, ok := m[k]; !ok {
+			s.fields[k].nullable = true
+		}
+	}
+}
+
+// typeOf returns the Go type used to represent values of shape s.
+func (s *shape) typeOf() reflect.Type {
+	var t reflect.Type
+	switch s.kind {
+	case shapeBool:
+		t = reflect.TypeOf(false)
+	case shapeInt:
+		t = reflect.TypeOf(int64(0))
+	case shapeFloat:
+		t = reflect.TypeOf(float64(0))
+	case shapeString:
+		t = reflect.TypeOf("")
+	case shapeArray:
+		return reflect.SliceOf(s.elem.typeOf())
+	case shapeObject:
+		names := tfortools.FieldNames(s.keys)
+		fields := make([]reflect.StructField, len(s.keys))
+		for i, k := range s.keys {
+			fields[i] = reflect.StructField{
+				Name: names[i],
+				Type: s.fields[k].typeOf(),
+				Tag:  reflect.StructTag(fmt.Sprintf("json:%q", k)),
+			}
+		}
+		t = reflect.StructOf(fields)
+	default:
+		return reflect.TypeOf((*interface{})(nil)).Elem()
+	}
+
+	if s.nullable {
+		t = reflect.PtrTo(t)
+	}
+	return t
+}
+
+// plainValue converts json.Numbers contained within v into int64 or float64
+// values.  It is used for values whose shape is mixed and which are
+// therefore stored in interface{} fields.
+func plainValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i := range v {
+			a[i] = plainValue(v[i])
+		}
+		return a
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = plainValue(e)
+		}
+		return m
+	}
+	return v
+}
+
+func scalarValue(t reflect.Type, v interface{}) reflect.Value {
+	switch v := v.(type) {
+	case json.Number:
+		if t.Kind() == reflect.Int64 {
+			i, _ := v.Int64()
+			return reflect.ValueOf(i)
+		}
+		f, _ := v.Float64()
+		return reflect.ValueOf(f)
+	case int:
+		return reflect.ValueOf(v).Convert(t)
+	case int64:
+		return reflect.ValueOf(v).Convert(t)
+	}
+	return reflect.ValueOf(v)
+}
+
+// valueOf converts v, whose shape is s, into a value of type s.typeOf().
+func (s *shape) valueOf(t reflect.Type, v interface{}) reflect.Value {
+	if s.kind == shapeMixed || s.kind == shapeNull {
+		r := reflect.New(t).Elem()
+		if v != nil {
+			r.Set(reflect.ValueOf(plainValue(v)))
+		}
+		return r
+	}
+
+	if t.Kind() == reflect.Ptr {
+		r := reflect.New(t).Elem()
+		if v != nil {
+			p := reflect.New(t.Elem())
+			p.Elem().Set(s.valueOf(t.Elem(), v))
+			r.Set(p)
+		}
+		return r
+	}
+
+	switch s.kind {
+	case shapeArray:
+		a, _ := arrayElements(v)
+		r := reflect.MakeSlice(t, len(a), len(a))
+		for i, e := range a {
+			r.Index(i).Set(s.elem.valueOf(t.Elem(), e))
+		}
+		return r
+	case shapeObject:
+		_, m, _ := objectFields(v)
+		r := reflect.New(t).Elem()
+		for i, k := range s.keys {
+			if e, ok := m[k]; ok {
+				r.Field(i).Set(s.fields[k].valueOf(t.Field(i).Type, e))
+			}
+		}
+		return r
+	}
+	return scalarValue(t, v)
+}
+
+// toStructs converts v, a value decoded from JSON or YAML, into a value
+// whose type is synthesised from the data.  Objects are converted into
+// structures with one exported field for each key and arrays into slices.
+// Values whose type varies, for example an array containing both strings
+// and objects, are stored in interface{} fields.
+func toStructs(v interface{}) interface{} {
+	var s shape
+	s.add(v)
+	if s.kind == shapeNull {
+		return nil
+	}
+	s.nullable = false
+	t := s.typeOf()
+	return s.valueOf(t, v).Interface()
+}
@@ -0,0 +1,130 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tfortools
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// normalizeRows converts any []interface{} in v whose elements are all
+// map[string]interface{} into a []map[string]interface{}, so that the
+// decoded arrays of objects can be passed directly to functions such as
+// table and sort.
+func normalizeRows(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = normalizeRows(e)
+		}
+	case []interface{}:
+		rows := make([]map[string]interface{}, 0, len(t))
+		for i, e := range t {
+			t[i] = normalizeRows(e)
+			if m, ok := t[i].(map[string]interface{}); ok {
+				rows = append(rows, m)
+			}
+		}
+		if len(t) > 0 && len(rows) == len(t) {
+			return rows
+		}
+	}
+	return v
+}
+
+// jsonNumbers converts the json.Numbers in v into int64s, if they are
+// integers that fit in an int64, or into float64s otherwise.
+func jsonNumbers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = jsonNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = jsonNumbers(e)
+		}
+	}
+	return v
+}
+
+func fromJSON(data string) interface{} {
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		fatalf("fromjson", "%v", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		fatalf("fromjson", "unexpected data after the JSON value")
+	}
+	return normalizeRows(jsonNumbers(v))
+}
+
+func fromYAML(data string) interface{} {
+	return normalizeRows(parseYAML(data))
+}
+
+func fromCSV(data string, options ...string) interface{} {
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		fatalf("fromcsv", "%v", err)
+	}
+	return toTable(records, options...)
+}
+
+// catchExecError calls fn, converting any ExecError raised by fatalf into an
+// error.
+func catchExecError(fn func() interface{}) (v interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			execErr, ok := r.(template.ExecError)
+			if !ok {
+				panic(r)
+			}
+			err = execErr.Err
+		}
+	}()
+	return fn(), nil
+}
+
+// DecodeYAML decodes data, which must contain YAML, in the same way as the
+// 'fromyaml' template function.  Mappings are decoded into
+// map[string]interface{}, sequences of mappings into
+// []map[string]interface{}, other sequences into []interface{} and scalars
+// into strings, ints, float64s, bools or nil.  Only the subset of YAML
+// described in the help for 'fromyaml' is supported.
+func DecodeYAML(data []byte) (interface{}, error) {
+	return catchExecError(func() interface{} { return fromYAML(string(data)) })
+}
+
+// ToTable converts a slice of rows of strings, such as those returned by
+// csv.Reader.ReadAll, into a slice of structures in the same way as the
+// 'totable' template function.  options may contain any of the options
+// accepted by 'totable', e.g., "noheader".
+func ToTable(data [][]string, options ...string) (interface{}, error) {
+	return catchExecError(func() interface{} { return toTable(data, options...) })
+}
@@ -16,7 +16,128 @@
 
 package tfortools
 
-import "text/template"
+import (
+	"context"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// An env gives the template functions that depend on the settings of a
+// Config object access to those settings.  A new env is created each time
+// a template is created.
+type env struct {
+	nilPlaceholder string
+	firstSeenKeys  bool
+	now            func() time.Time
+
+	// ctx is nil if the template cannot be cancelled.  Otherwise, it is
+	// consulted periodically by long running functions.  ticks counts
+	// the number of calls to checkContext.
+	ctx   context.Context
+	ticks int
+
+	// limits holds the resource limits of the Config object.  The
+	// number of range iterations performed and the current template
+	// depth are recorded in iterations and depth.
+	limits     limits
+	iterations int64
+	depth      int
+
+	// sandbox restricts the methods listed by describe.  It is nil
+	// if sandbox mode is not enabled.
+	sandbox *sandbox
+}
+
+// An envFn is stored in a FuncMap in place of a template function that
+// needs access to an env.  getFuncMap calls the envFn to obtain the
+// function that is actually added to the template.
+type envFn func(*env) interface{}
+
+var (
+	selectField    = envFn(func(e *env) interface{} { return e.selectField })
+	selectFieldAlt = envFn(func(e *env) interface{} { return e.selectFieldAlt })
+	toCSV          = envFn(func(e *env) interface{} { return e.toCSV })
+	table          = envFn(func(e *env) interface{} { return e.table })
+	tableAlt       = envFn(func(e *env) interface{} { return e.tableAlt })
+	tablex         = envFn(func(e *env) interface{} { return e.tablex })
+	tablexAlt      = envFn(func(e *env) interface{} { return e.tablexAlt })
+	htable         = envFn(func(e *env) interface{} { return e.htable })
+	htableAlt      = envFn(func(e *env) interface{} { return e.htableAlt })
+	htablex        = envFn(func(e *env) interface{} { return e.htablex })
+	htablexAlt     = envFn(func(e *env) interface{} { return e.htablexAlt })
+	formatCol      = envFn(func(e *env) interface{} { return e.formatCol })
+	since          = envFn(func(e *env) interface{} { return e.since })
+	ago            = envFn(func(e *env) interface{} { return e.ago })
+	now            = envFn(func(e *env) interface{} { return e.now })
+	describe       = envFn(func(e *env) interface{} { return e.describe })
+
+	filterByField     = envFn(func(e *env) interface{} { return e.filterByField })
+	filterByContains  = envFn(func(e *env) interface{} { return e.filterByContains })
+	filterByHasPrefix = envFn(func(e *env) interface{} { return e.filterByHasPrefix })
+	filterByHasSuffix = envFn(func(e *env) interface{} { return e.filterByHasSuffix })
+	filterByFolded    = envFn(func(e *env) interface{} { return e.filterByFolded })
+	filterByRegexp    = envFn(func(e *env) interface{} { return e.filterByRegexp })
+	filterByNil       = envFn(func(e *env) interface{} { return e.filterByNil })
+	filterByNotNil    = envFn(func(e *env) interface{} { return e.filterByNotNil })
+	cols              = envFn(func(e *env) interface{} { return e.cols })
+	sortSlice         = envFn(func(e *env) interface{} { return e.sortSlice })
+	union             = envFn(func(e *env) interface{} { return e.union })
+	intersect         = envFn(func(e *env) interface{} { return e.intersect })
+	difference        = envFn(func(e *env) interface{} { return e.difference })
+	rank              = envFn(func(e *env) interface{} { return e.rank })
+	seq               = envFn(func(e *env) interface{} { return e.seq })
+	padLeft           = envFn(func(e *env) interface{} { return e.padLeft })
+	padRight          = envFn(func(e *env) interface{} { return e.padRight })
+	chunk             = envFn(func(e *env) interface{} { return e.chunk })
+	flattenSlices     = envFn(func(e *env) interface{} { return e.flattenSlices })
+	zip               = envFn(func(e *env) interface{} { return e.zip })
+)
+
+// contextCheckInterval is the number of calls to checkContext between
+// each check of the env's context.
+const contextCheckInterval = 256
+
+// checkContext aborts the function fnName with the error returned by the
+// env's context, if that context has been cancelled.  It is called by
+// functions that iterate over their input.  To keep the overhead low, the
+// context is only consulted once every contextCheckInterval calls.
+func (e *env) checkContext(fnName string) {
+	if e.ctx == nil {
+		return
+	}
+	e.ticks++
+	if e.ticks%contextCheckInterval != 0 {
+		return
+	}
+	if err := e.ctx.Err(); err != nil {
+		panic(template.ExecError{Name: fnName, Err: err})
+	}
+}
+
+const defaultNilPlaceholder = "<nil>"
+
+func newEnv(cfg *Config) *env {
+	if cfg == nil {
+		return &env{
+			nilPlaceholder: defaultNilPlaceholder,
+			now:            time.Now,
+		}
+	}
+
+	e := &env{
+		nilPlaceholder: cfg.nilPlaceholder,
+		firstSeenKeys:  cfg.firstSeenKeys,
+		now:            time.Now,
+		limits:         cfg.limits,
+		sandbox:        cfg.sandbox,
+	}
+	if !cfg.now.IsZero() {
+		frozen := cfg.now
+		e.now = func() time.Time { return frozen }
+	}
+	return e
+}
 
 var funcMap = template.FuncMap{
 	"filter":          filterByField,
@@ -25,6 +146,8 @@ var funcMap = template.FuncMap{
 	"filterHasSuffix": filterByHasSuffix,
 	"filterFolded":    filterByFolded,
 	"filterRegexp":    filterByRegexp,
+	"filterNil":       filterByNil,
+	"filterNotNil":    filterByNotNil,
 	"tojson":          toJSON,
 	"tocsv":           toCSV,
 	"select":          selectField,
@@ -46,6 +169,64 @@ var funcMap = template.FuncMap{
 	"promote":         promote,
 	"sliceof":         sliceof,
 	"totable":         toTable,
+	"keys":            keys,
+	"values":          values,
+	"entries":         entries,
+	"formatCol":       formatCol,
+	"humanBytes":      humanBytes,
+	"humanDuration":   humanDuration,
+	"since":           since,
+	"ago":             ago,
+	"siNumber":        siNumber,
+	"thousands":       thousands,
+	"upper":           strings.ToUpper,
+	"lower":           strings.ToLower,
+	"trim":            trim,
+	"replace":         replace,
+	"split":           split,
+	"join":            join,
+	"padLeft":         padLeft,
+	"padRight":        padRight,
+	"truncate":        truncate,
+	"regexReplace":    regexReplace,
+	"add":             add,
+	"sub":             sub,
+	"mul":             mul,
+	"div":             div,
+	"mod":             mod,
+	"round":           round,
+	"floor":           floor,
+	"ceil":            ceil,
+	"abs":             abs,
+	"percent":         percent,
+	"now":             now,
+	"formatTime":      formatTime,
+	"parseTime":       parseTime,
+	"timeTrunc":       timeTrunc,
+	"addDuration":     addDuration,
+	"inZone":          inZone,
+	"list":            list,
+	"dict":            dict,
+	"seq":             seq,
+	"default":         defaultValue,
+	"coalesce":        coalesce,
+	"ternary":         ternary,
+	"union":           union,
+	"intersect":       intersect,
+	"difference":      difference,
+	"reverse":         reverse,
+	"skip":            skip,
+	"subslice":        subslice,
+	"chunk":           chunk,
+	"flattenSlices":   flattenSlices,
+	"zip":             zip,
+	"shuffle":         shuffle,
+	"sample":          sample,
+	"enumerate":       enumerate,
+	"rank":            rank,
+	"fromjson":        fromJSON,
+	"fromyaml":        fromYAML,
+	"fromcsv":         fromCSV,
 }
 
 var funcHelpSlice = []funcHelpInfo{
@@ -55,6 +236,8 @@ var funcHelpSlice = []funcHelpInfo{
 	{"filterHasSuffix", helpFilterHasSuffix, helpFilterHasSuffixIndex},
 	{"filterFolded", helpFilterFolded, helpFilterFoldedIndex},
 	{"filterRegexp", helpFilterRegexp, helpFilterRegexpIndex},
+	{"filterNil", helpFilterNil, helpFilterNilIndex},
+	{"filterNotNil", helpFilterNotNil, helpFilterNotNilIndex},
 	{"tojson", helpToJSON, helpToJSONIndex},
 	{"tocsv", helpToCSV, helpToCSVIndex},
 	{"select", helpSelect, helpSelectIndex},
@@ -76,14 +259,90 @@ var funcHelpSlice = []funcHelpInfo{
 	{"promote", helpPromote, helpPromoteIndex},
 	{"sliceof", helpSliceof, helpSliceofIndex},
 	{"totable", helpToTable, helpToTableIndex},
+	{"keys", helpKeys, helpKeysIndex},
+	{"values", helpValues, helpValuesIndex},
+	{"entries", helpEntries, helpEntriesIndex},
+	{"formatCol", helpFormatCol, helpFormatColIndex},
+	{"humanBytes", helpHumanBytes, helpHumanBytesIndex},
+	{"humanDuration", helpHumanDuration, helpHumanDurationIndex},
+	{"since", helpSince, helpSinceIndex},
+	{"ago", helpAgo, helpAgoIndex},
+	{"siNumber", helpSINumber, helpSINumberIndex},
+	{"thousands", helpThousands, helpThousandsIndex},
+	{"upper", helpUpper, helpUpperIndex},
+	{"lower", helpLower, helpLowerIndex},
+	{"trim", helpTrim, helpTrimIndex},
+	{"replace", helpReplace, helpReplaceIndex},
+	{"split", helpSplit, helpSplitIndex},
+	{"join", helpJoin, helpJoinIndex},
+	{"padLeft", helpPadLeft, helpPadLeftIndex},
+	{"padRight", helpPadRight, helpPadRightIndex},
+	{"truncate", helpTruncate, helpTruncateIndex},
+	{"regexReplace", helpRegexReplace, helpRegexReplaceIndex},
+	{"add", helpAdd, helpAddIndex},
+	{"sub", helpSub, helpSubIndex},
+	{"mul", helpMul, helpMulIndex},
+	{"div", helpDiv, helpDivIndex},
+	{"mod", helpMod, helpModIndex},
+	{"round", helpRound, helpRoundIndex},
+	{"floor", helpFloor, helpFloorIndex},
+	{"ceil", helpCeil, helpCeilIndex},
+	{"abs", helpAbs, helpAbsIndex},
+	{"percent", helpPercent, helpPercentIndex},
+	{"now", helpNow, helpNowIndex},
+	{"formatTime", helpFormatTime, helpFormatTimeIndex},
+	{"parseTime", helpParseTime, helpParseTimeIndex},
+	{"timeTrunc", helpTimeTrunc, helpTimeTruncIndex},
+	{"addDuration", helpAddDuration, helpAddDurationIndex},
+	{"inZone", helpInZone, helpInZoneIndex},
+	{"list", helpList, helpListIndex},
+	{"dict", helpDict, helpDictIndex},
+	{"seq", helpSeq, helpSeqIndex},
+	{"default", helpDefault, helpDefaultIndex},
+	{"coalesce", helpCoalesce, helpCoalesceIndex},
+	{"ternary", helpTernary, helpTernaryIndex},
+	{"union", helpUnion, helpUnionIndex},
+	{"intersect", helpIntersect, helpIntersectIndex},
+	{"difference", helpDifference, helpDifferenceIndex},
+	{"reverse", helpReverse, helpReverseIndex},
+	{"skip", helpSkip, helpSkipIndex},
+	{"subslice", helpSubslice, helpSubsliceIndex},
+	{"chunk", helpChunk, helpChunkIndex},
+	{"flattenSlices", helpFlattenSlices, helpFlattenSlicesIndex},
+	{"zip", helpZip, helpZipIndex},
+	{"shuffle", helpShuffle, helpShuffleIndex},
+	{"sample", helpSample, helpSampleIndex},
+	{"enumerate", helpEnumerate, helpEnumerateIndex},
+	{"rank", helpRank, helpRankIndex},
+	{"fromjson", helpFromJSON, helpFromJSONIndex},
+	{"fromyaml", helpFromYAML, helpFromYAMLIndex},
+	{"fromcsv", helpFromCSV, helpFromCSVIndex},
 }
 
 func getFuncMap(cfg *Config) template.FuncMap {
-	if cfg == nil {
-		return funcMap
+	return newEnv(cfg).bindFuncs(cfg)
+}
+
+// bindFuncs returns the functions enabled in cfg bound to e.  If a slice
+// length limit is set, the functions are wrapped so that the limit is
+// enforced.
+func (e *env) bindFuncs(cfg *Config) template.FuncMap {
+	fm := funcMap
+	if cfg != nil {
+		fm = cfg.funcMap
 	}
 
-	return cfg.funcMap
+	bound := make(template.FuncMap, len(fm))
+	for k, v := range fm {
+		if fn, ok := v.(envFn); ok {
+			v = fn(e)
+		}
+		if e.limits.sliceLen > 0 {
+			v = e.limitSliceLen(k, v)
+		}
+		bound[k] = v
+	}
+	return bound
 }
 
 func getHelpers(cfg *Config) []funcHelpInfo {
@@ -0,0 +1,194 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tfortools
+
+import (
+	"reflect"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+var intType = reflect.TypeOf(0)
+
+// columnAdder creates copies of rows to which a new integer column has been
+// added.  The new column appears before the existing columns of structs.
+type columnAdder struct {
+	fnName string
+	name   string
+	types  map[reflect.Type]reflect.Type
+}
+
+func newColumnAdder(fnName, name string) *columnAdder {
+	r, _ := utf8.DecodeRuneInString(name)
+	if !unicode.IsUpper(r) {
+		fatalFieldf(fnName, name, "%q is not a valid exported field name", name)
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			fatalFieldf(fnName, name, "%q is not a valid exported field name", name)
+		}
+	}
+	return &columnAdder{
+		fnName: fnName,
+		name:   name,
+		types:  make(map[reflect.Type]reflect.Type),
+	}
+}
+
+// structType returns a new struct type containing an int field followed
+// by the exported fields of styp.  As with cols, hidden fields and fields
+// of type channel are omitted.
+func (c *columnAdder) structType(styp reflect.Type) reflect.Type {
+	if t, ok := c.types[styp]; ok {
+		return t
+	}
+	if _, found := styp.FieldByName(c.name); found {
+		fatalFieldf(c.fnName, c.name, "%s already contains a field called %s", styp, c.name)
+	}
+
+	fields := []reflect.StructField{{Name: c.name, Type: intType}}
+	for i := 0; i < styp.NumField(); i++ {
+		field := styp.Field(i)
+		if field.PkgPath != "" || ignoreKind(field.Type.Kind()) {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	t := reflect.StructOf(fields)
+	c.types[styp] = t
+	return t
+}
+
+// row returns a copy of the struct or map el to which the new column,
+// holding n, has been added.  styp is the struct type of el, which is
+// needed if el is a nil pointer.
+func (c *columnAdder) row(el reflect.Value, styp reflect.Type, n int) reflect.Value {
+	if el.Kind() == reflect.Map {
+		newMap := make(map[string]interface{}, el.Len()+1)
+		for _, k := range el.MapKeys() {
+			if k.String() == c.name {
+				fatalFieldf(c.fnName, c.name, "row already contains a key called %s", c.name)
+			}
+			newMap[k.String()] = el.MapIndex(k).Interface()
+		}
+		newMap[c.name] = n
+		return reflect.ValueOf(newMap)
+	}
+
+	newTyp := c.structType(styp)
+	newEl := reflect.New(newTyp).Elem()
+	newEl.Field(0).SetInt(int64(n))
+	if el.IsValid() {
+		for i := 1; i < newTyp.NumField(); i++ {
+			newEl.Field(i).Set(el.FieldByName(newTyp.Field(i).Name))
+		}
+	}
+	return newEl
+}
+
+// addColumn returns a copy of the rows in val, each of which contains an
+// additional integer column whose value is taken from values.
+func (c *columnAdder) addColumn(val reflect.Value, values []int) interface{} {
+	typ := val.Type().Elem()
+	styp := derefType(typ)
+
+	var newTyp reflect.Type
+	switch styp.Kind() {
+	case reflect.Struct:
+		newTyp = c.structType(styp)
+	case reflect.Map:
+		newTyp = reflect.TypeOf(map[string]interface{}{})
+	default:
+		newTyp = typ
+	}
+
+	newVal := reflect.MakeSlice(reflect.SliceOf(newTyp), val.Len(), val.Len())
+	for i := 0; i < val.Len(); i++ {
+		el := derefValue(val.Index(i))
+		switch {
+		case styp.Kind() == reflect.Struct:
+			newVal.Index(i).Set(c.row(el, styp, values[i]))
+		case el.Kind() == reflect.Struct:
+			newVal.Index(i).Set(c.row(el, el.Type(), values[i]))
+		case el.Kind() == reflect.Map:
+			newVal.Index(i).Set(c.row(el, nil, values[i]))
+		}
+	}
+	return newVal.Interface()
+}
+
+func enumerate(obj interface{}, name string) interface{} {
+	val := getValue(obj)
+	assertCollectionOfRows("enumerate", val)
+
+	values := make([]int, val.Len())
+	for i := range values {
+		values[i] = i + 1
+	}
+	return newColumnAdder("enumerate", name).addColumn(val, values)
+}
+
+// parseRankOptions parses the optional direction and ranking method passed
+// to rank.  It returns true if the direction is ascending and true if dense
+// ranking is requested.
+func parseRankOptions(options []string) (bool, bool) {
+	ascending, dense := true, false
+	var direction []string
+	for _, opt := range options {
+		switch opt {
+		case "dense":
+			dense = true
+		case "competition":
+			dense = false
+		default:
+			direction = append(direction, opt)
+		}
+	}
+	if len(direction) > 0 {
+		ascending = parseDirection("rank", direction)
+	}
+	return ascending, dense
+}
+
+func (e *env) rank(obj interface{}, field string, options ...string) interface{} {
+	if len(options) > 2 {
+		fatalArity("rank", "accepts a maximum of four arguments")
+	}
+	ascending, dense := parseRankOptions(options)
+
+	val := getValue(obj)
+	assertCollectionOfRows("rank", val)
+
+	copy := copyRange(val, 0, val.Len())
+
+	vs := e.newValueSorter("rank", copy.Interface(), field, ascending)
+	sort.Stable(vs)
+
+	ranks := make([]int, copy.Len())
+	for i := range ranks {
+		switch {
+		case i > 0 && !vs.Less(i-1, i) && !vs.Less(i, i-1):
+			ranks[i] = ranks[i-1]
+		case dense && i > 0:
+			ranks[i] = ranks[i-1] + 1
+		default:
+			ranks[i] = i + 1
+		}
+	}
+	return newColumnAdder("rank", "Rank").addColumn(copy, ranks)
+}
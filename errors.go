@@ -0,0 +1,167 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tfortools
+
+import (
+	"fmt"
+	"regexp"
+	"text/template"
+)
+
+// ErrorKind classifies the errors reported by the functions provided by
+// tfortools.
+type ErrorKind int
+
+const (
+	// KindBadValue indicates that an argument passed to a function has
+	// an invalid value, e.g., a negative count or a malformed regular
+	// expression.
+	KindBadValue ErrorKind = iota
+
+	// KindBadField indicates that a field name passed to a function
+	// does not identify a field of the function's input.
+	KindBadField
+
+	// KindBadType indicates that an argument passed to a function has
+	// the wrong type, e.g., a map was passed to a function that expects
+	// a slice.
+	KindBadType
+
+	// KindBadArity indicates that a function was passed the wrong number
+	// of arguments.
+	KindBadArity
+
+	// KindInternal indicates an unexpected failure inside a function.
+	// Such errors are not caused by mistakes in the template.
+	KindInternal
+)
+
+var errorKindNames = []string{
+	"bad value",
+	"bad field",
+	"bad type",
+	"bad arity",
+	"internal error",
+}
+
+func (k ErrorKind) String() string {
+	if k < 0 || int(k) >= len(errorKindNames) {
+		return fmt.Sprintf("ErrorKind(%d)", int(k))
+	}
+	return errorKindNames[k]
+}
+
+// Error describes a failure of one of the functions provided by tfortools.
+// Errors returned by OutputToTemplate wrap an Error when the failure
+// occurred inside one of these functions.  The Error can be retrieved with
+// errors.As, e.g.,
+//
+//	var tErr *tfortools.Error
+//	if errors.As(err, &tErr) && tErr.Kind == tfortools.KindBadField {
+//		fmt.Printf("%s: unknown field %s\n", tErr.Location, tErr.Arg)
+//	}
+type Error struct {
+	// Func is the name of the function that failed.
+	Func string
+
+	// Arg is the offending argument, e.g., the name of an unknown field.
+	// It is empty if the failure cannot be attributed to a single
+	// argument.
+	Arg string
+
+	// Kind classifies the error.
+	Kind ErrorKind
+
+	// Location identifies the position of the failing call in the
+	// template, in the form name:line:column.  It is set by
+	// OutputToTemplate and is empty if the template was executed by
+	// other means.
+	Location string
+
+	// Err describes the failure.
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+var execLocationRegexp = regexp.MustCompile(`^template: (.*?:[0-9]+:[0-9]+): executing`)
+
+// raise aborts the execution of the function name, reporting an Error of
+// the given kind.
+func raise(kind ErrorKind, name, arg, format string, args ...interface{}) {
+	panic(template.ExecError{
+		Name: name,
+		Err: &Error{
+			Func: name,
+			Arg:  arg,
+			Kind: kind,
+			Err:  fmt.Errorf(format, args...),
+		},
+	})
+}
+
+func fatalf(name, format string, args ...interface{}) {
+	raise(KindBadValue, name, "", format, args...)
+}
+
+func fatalType(name, format string, args ...interface{}) {
+	raise(KindBadType, name, "", format, args...)
+}
+
+func fatalArity(name, format string, args ...interface{}) {
+	raise(KindBadArity, name, "", format, args...)
+}
+
+func fatalFieldf(name, field, format string, args ...interface{}) {
+	raise(KindBadField, name, field, format, args...)
+}
+
+// findError returns the Error wrapped by err, if any.
+func findError(err error) *Error {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			return e
+		}
+		u, ok := err.(interface {
+			Unwrap() error
+		})
+		if !ok {
+			return nil
+		}
+		err = u.Unwrap()
+	}
+	return nil
+}
+
+// setErrorLocation records the location reported by text/template in
+// the Error wrapped by err, if any.
+func setErrorLocation(err error) {
+	e := findError(err)
+	if e == nil {
+		return
+	}
+	if m := execLocationRegexp.FindStringSubmatch(err.Error()); m != nil {
+		e.Location = m[1]
+	}
+}
@@ -0,0 +1,42 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.13
+// +build go1.13
+
+package tfortools
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+func ExampleError() {
+	data := []struct {
+		Name   string
+		Volume int
+	}{}
+
+	err := OutputToTemplate(ioutil.Discard, "stocks", `{{sort . "Volme"}}`, data, nil)
+
+	var tErr *Error
+	if errors.As(err, &tErr) && tErr.Kind == KindBadField {
+		fmt.Printf("%s: %s: unknown field %s\n", tErr.Location, tErr.Func, tErr.Arg)
+	}
+	// output:
+	// stocks:1:2: sort: unknown field Volme
+}
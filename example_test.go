@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 )
 
 func ExampleGenerateUsageDecorated() {
@@ -43,7 +44,10 @@ func ExampleGenerateUsageDecorated() {
 	//   {{cols . "Name" "Address"}}
 	//
 	//   returns a new slice of structs, each element of which is a structure with only
-	//   two fields, 'Name' and 'Address'.
+	//   two fields, 'Name' and 'Address'.  When given a slice of maps, 'cols' returns
+	//   a new slice of maps containing only the requested keys.  When given a slice
+	//   of interfaces, each element of the new slice contains only the requested
+	//   fields of the corresponding input element.
 }
 
 func ExampleGenerateUsageUndecorated() {
@@ -104,7 +108,10 @@ func ExampleTemplateFunctionHelpSingle() {
 	//   {{cols . "Name" "Address"}}
 	//
 	//   returns a new slice of structs, each element of which is a structure with only
-	//   two fields, 'Name' and 'Address'.
+	//   two fields, 'Name' and 'Address'.  When given a slice of maps, 'cols' returns
+	//   a new slice of maps containing only the requested keys.  When given a slice
+	//   of interfaces, each element of the new slice contains only the requested
+	//   fields of the corresponding input element.
 	//
 	// - trim trims leading and trailing whitespace from string
 }
@@ -602,3 +609,496 @@ func ExampleConfig_AddCustomFn() {
 	// output:
 	// 55
 }
+
+func ExampleOptKeys() {
+	data := map[string]int{"Tullius": 3, "Julius": 1, "Licinius": 2}
+	script := `{{range keys . "dsc"}}{{println .}}{{end}}`
+	if err := OutputToTemplate(os.Stdout, "names", script, data, nil); err != nil {
+		panic(err)
+	}
+	// output:
+	// Tullius
+	// Licinius
+	// Julius
+}
+
+func ExampleOptValues() {
+	data := map[string]int{"Tullius": 3, "Julius": 1, "Licinius": 2}
+	script := `{{values .}} {{values . "dsc"}}`
+	if err := OutputToTemplate(os.Stdout, "names", script, data, nil); err != nil {
+		panic(err)
+	}
+	// output:
+	// [1 2 3] [3 2 1]
+}
+
+func ExampleOptEntries() {
+	data := struct {
+		Name   string
+		Labels map[string]string
+	}{
+		Name:   "web",
+		Labels: map[string]string{"tier": "frontend", "app": "shop", "env": "prod"},
+	}
+
+	// Output the labels sorted by value
+	script := `{{tablex (sort (entries .Labels) "Value") 6 8 0}}`
+	var b bytes.Buffer
+	if err := OutputToTemplate(&b, "labels", script, data, nil); err != nil {
+		panic(err)
+	}
+
+	scanner := bufio.NewScanner(&b)
+	for scanner.Scan() {
+		fmt.Println(strings.TrimSpace(scanner.Text()))
+	}
+	// output:
+	// Key   Value
+	// tier  frontend
+	// env   prod
+	// app   shop
+}
+
+func ExampleOptFilterNil() {
+	type owner struct{ Name string }
+	data := []struct {
+		Name  string
+		Owner *owner
+	}{
+		{"db", &owner{"Marcus"}},
+		{"web", nil},
+		{"cache", &owner{"Gaius"}},
+	}
+
+	// Print the names of the resources with and without owners
+	script := `{{select (filterNil . "Owner.Name") "Name"}}{{select (filterNotNil . "Owner") "Name"}}`
+	if err := OutputToTemplate(os.Stdout, "owners", script, data, nil); err != nil {
+		panic(err)
+	}
+	// output:
+	// web
+	// db
+	// cache
+}
+
+func ExampleOptNilPlaceholder() {
+	type owner struct{ Name string }
+	data := []struct {
+		Name  string
+		Owner *owner
+	}{
+		{"db", &owner{"Marcus"}},
+		{"web", nil},
+	}
+
+	cfg := NewConfig(OptAllFns, OptNilPlaceholder("-"))
+	script := `{{select . "Owner.Name"}}`
+	if err := OutputToTemplate(os.Stdout, "owners", script, data, cfg); err != nil {
+		panic(err)
+	}
+	// output:
+	// Marcus
+	// -
+}
+
+func ExampleOptFormatCol() {
+	data := []struct {
+		Name      string
+		LastTrade time.Time
+		Current   float64
+	}{
+		{"Big Company", time.Date(2017, time.March, 17, 11, 01, 00, 00, time.UTC), 120.23},
+		{"Small Company", time.Date(2017, time.March, 17, 10, 59, 00, 00, time.UTC), 1.06},
+		{"Medium Company", time.Date(2017, time.March, 17, 12, 23, 00, 00, time.UTC), 77.0},
+	}
+
+	// Output a table sorted by the current price of each stock.  The
+	// prices are shown with a single decimal place but are still sorted
+	// numerically.
+	script := `{{tablex (sort (formatCol . "Current" "%.1f" "LastTrade" "15:04") "Current" "dsc") 6 8 1}}`
+	var b bytes.Buffer
+	if err := OutputToTemplate(&b, "stocks", script, data, nil); err != nil {
+		panic(err)
+	}
+
+	scanner := bufio.NewScanner(&b)
+	for scanner.Scan() {
+		fmt.Println(strings.TrimSpace(scanner.Text()))
+	}
+	// output:
+	// Name           LastTrade Current
+	// Big Company    11:01     120.2
+	// Medium Company 12:23     77.0
+	// Small Company  10:59     1.1
+}
+
+func ExampleOptHumanBytes() {
+	script := `{{humanBytes 512}} {{humanBytes 1536}} {{humanBytes 6395624278}}`
+	if err := OutputToTemplate(os.Stdout, "bytes", script, nil, nil); err != nil {
+		panic(err)
+	}
+	// output:
+	// 512B 1.5KiB 6.0GiB
+}
+
+func ExampleOptHumanDuration() {
+	data := []time.Duration{90 * time.Minute, 36 * time.Hour, 1500 * time.Millisecond}
+	script := `{{range .}}{{println (humanDuration .)}}{{end}}{{humanDuration 125}}`
+	if err := OutputToTemplate(os.Stdout, "durations", script, data, nil); err != nil {
+		panic(err)
+	}
+	// output:
+	// 1h30m
+	// 1d12h
+	// 1.5s
+	// 2m5s
+}
+
+func ExampleOptSINumber() {
+	script := `{{siNumber 750}} {{siNumber 300122}} {{siNumber 6395624278}}`
+	if err := OutputToTemplate(os.Stdout, "si", script, nil, nil); err != nil {
+		panic(err)
+	}
+	// output:
+	// 750 300.1k 6.4G
+}
+
+func ExampleOptThousands() {
+	script := `{{thousands 6395624278}} {{thousands -1234.5}} {{thousands 999}}`
+	if err := OutputToTemplate(os.Stdout, "thousands", script, nil, nil); err != nil {
+		panic(err)
+	}
+	// output:
+	// 6,395,624,278 -1,234.5 999
+}
+
+func ExampleOptStrings() {
+	data := []struct{ FirstName, MiddleName, Surname string }{
+		{"Marcus", "Tullius", "Cicero"},
+		{"Gaius", "Julius", "Caesar"},
+		{"Marcus", "Licinius", "Crassus"},
+	}
+
+	cfg := NewConfig(OptStrings)
+	script := `{{range .}}{{padRight (upper .Surname) 8 "."}}{{truncate .FirstName 3}}{{println}}{{end}}`
+	if err := OutputToTemplate(os.Stdout, "names", script, data, cfg); err != nil {
+		panic(err)
+	}
+	// output:
+	// CICERO..Mar
+	// CAESAR..Gai
+	// CRASSUS.Mar
+}
+
+func ExampleOptMath() {
+	data := []struct {
+		Name      string
+		Low, High float64
+		Volume    int
+	}{
+		{"Widgets", 10.00, 12.50, 1500},
+		{"Gadgets", 4.00, 4.10, 250},
+	}
+
+	cfg := NewConfig(OptMath)
+	script := `{{range .}}{{.Name}} {{round (percent (sub .High .Low) .Low) 1}}% {{div .Volume 100}}{{println}}{{end}}`
+	if err := OutputToTemplate(os.Stdout, "math", script, data, cfg); err != nil {
+		panic(err)
+	}
+	// output:
+	// Widgets 25% 15
+	// Gadgets 2.5% 2
+}
+
+func ExampleOptTime() {
+	data := []struct {
+		Name      string
+		LastTrade time.Time
+	}{
+		{"Big Company", time.Date(2017, time.March, 17, 10, 59, 0, 0, time.UTC)},
+		{"Small Company", time.Date(2017, time.March, 16, 15, 30, 0, 0, time.UTC)},
+	}
+
+	now := time.Date(2017, time.March, 17, 12, 0, 0, 0, time.UTC)
+	cfg := NewConfig(OptTime, OptFrozenNow(now))
+	script := `{{range .}}{{.Name}}: {{formatTime .LastTrade "date"}} {{formatTime .LastTrade "kitchen"}} ({{ago .LastTrade}}){{println}}{{end}}`
+	if err := OutputToTemplate(os.Stdout, "times", script, data, cfg); err != nil {
+		panic(err)
+	}
+	// output:
+	// Big Company: 2017-03-17 10:59AM (1h1m ago)
+	// Small Company: 2017-03-16 3:30PM (20h30m ago)
+}
+
+func ExampleOptDict() {
+	cfg := NewConfig(OptList, OptDict, OptTableX)
+	script := `{{tablex (list (dict "Name" "Alice" "Age" 33) (dict "Name" "Bob" "Age" 28)) 6 8 0}}`
+	var b bytes.Buffer
+	if err := OutputToTemplate(&b, "dict", script, nil, cfg); err != nil {
+		panic(err)
+	}
+
+	scanner := bufio.NewScanner(&b)
+	for scanner.Scan() {
+		fmt.Println(strings.TrimSpace(scanner.Text()))
+	}
+	// output:
+	// Age   Name
+	// 33    Alice
+	// 28    Bob
+}
+
+func ExampleOptSeq() {
+	data := []string{"zero", "one", "two", "three", "four", "five"}
+	cfg := NewConfig(OptSeq, OptRows)
+	script := `{{range rows . (seq 1 6 2)}}{{println .}}{{end}}`
+	if err := OutputToTemplate(os.Stdout, "seq", script, data, cfg); err != nil {
+		panic(err)
+	}
+	// output:
+	// one
+	// three
+	// five
+}
+
+func ExampleOptDifference() {
+	data := struct {
+		Desired []struct{ Name, Image string }
+		Actual  []struct{ Name, Image string }
+	}{
+		Desired: []struct{ Name, Image string }{
+			{"web", "nginx"}, {"db", "postgres"}, {"cache", "redis"},
+		},
+		Actual: []struct{ Name, Image string }{
+			{"web", "nginx"}, {"cache", "redis"}, {"debug", "busybox"},
+		},
+	}
+
+	cfg := NewConfig(OptDifference, OptSelect)
+	script := `Missing:
+{{select (difference .Desired .Actual "Name") "Name"}}Unexpected:
+{{select (difference .Actual .Desired "Name") "Name"}}`
+	if err := OutputToTemplate(os.Stdout, "difference", script, data, cfg); err != nil {
+		panic(err)
+	}
+	// output:
+	// Missing:
+	// db
+	// Unexpected:
+	// debug
+}
+
+func ExampleOptChunk() {
+	data := []string{"Cicero", "Caesar", "Crassus", "Pompey", "Cato"}
+
+	cfg := NewConfig(OptChunk, OptJoin)
+	script := `{{range $i, $page := chunk . 2}}Page {{$i}}: {{join $page ", "}}{{println}}{{end}}`
+	if err := OutputToTemplate(os.Stdout, "chunk", script, data, cfg); err != nil {
+		panic(err)
+	}
+	// output:
+	// Page 0: Cicero, Caesar
+	// Page 1: Crassus, Pompey
+	// Page 2: Cato
+}
+
+func ExampleOptZip() {
+	data := struct {
+		Names []string
+		Ages  []int
+	}{
+		[]string{"Alice", "Bob", "Carol"},
+		[]int{33, 28, 41},
+	}
+
+	cfg := NewConfig(OptZip, OptSort, OptReverse)
+	script := `{{range reverse (sort (zip .Names .Ages) "Item2")}}{{.Item1}} {{.Item2}}{{println}}{{end}}`
+	if err := OutputToTemplate(os.Stdout, "zip", script, data, cfg); err != nil {
+		panic(err)
+	}
+	// output:
+	// Carol 41
+	// Alice 33
+	// Bob 28
+}
+
+func ExampleOptSample() {
+	data := []string{"Cicero", "Caesar", "Crassus", "Pompey", "Cato", "Brutus"}
+
+	cfg := NewConfig(OptSample, OptShuffle)
+	script := `{{sample . 3 42}}
+{{shuffle . 42}}`
+	if err := OutputToTemplate(os.Stdout, "sample", script, data, cfg); err != nil {
+		panic(err)
+	}
+	// output:
+	// [Caesar Pompey Cato]
+	// [Cato Caesar Pompey Cicero Crassus Brutus]
+}
+
+func ExampleOptRank() {
+	data := []struct {
+		Name   string
+		Volume int
+	}{
+		{"Big Company", 123456},
+		{"Small Company", 750},
+		{"Medium Company", 300122},
+		{"Tiny Company", 750},
+	}
+
+	cfg := NewConfig(OptRank, OptTableX)
+	script := `{{tablex (rank . "Volume" "dsc") 6 8 1}}`
+	var b bytes.Buffer
+	if err := OutputToTemplate(&b, "rank", script, data, cfg); err != nil {
+		panic(err)
+	}
+
+	scanner := bufio.NewScanner(&b)
+	for scanner.Scan() {
+		fmt.Println(strings.TrimSpace(scanner.Text()))
+	}
+	// output:
+	// Rank  Name           Volume
+	// 1     Medium Company 300122
+	// 2     Big Company    123456
+	// 3     Small Company  750
+	// 3     Tiny Company   750
+}
+
+func ExampleOptToTable_schema() {
+	data := [][]string{
+		{"Too many GOSUBs", "37", ""},
+		{"Too many REPEATs", "44", "0.15"},
+	}
+	script := `{{range totable . "noheader" "schema=Message,Code:string,Occurrence:*float"}}{{.Message}} {{printf "%q" .Code}} {{.Occurrence}}{{println}}{{end}}`
+	if err := OutputToTemplate(os.Stdout, "errors", script, data, nil); err != nil {
+		panic(err)
+	}
+	// output:
+	// Too many GOSUBs "37" <nil>
+	// Too many REPEATs "44" 0.15
+}
+
+func ExampleOptFromYAML() {
+	data := struct{ Metadata string }{
+		Metadata: `
+hosts:
+  - name: web1
+    cpus: 4
+  - name: db1
+    cpus: 16 # big
+`,
+	}
+
+	cfg := NewConfig(OptFromYAML, OptSort)
+	script := `{{range sort (fromyaml .Metadata).hosts "cpus" "dsc"}}{{.name}} {{.cpus}}{{println}}{{end}}`
+	if err := OutputToTemplate(os.Stdout, "yaml", script, data, cfg); err != nil {
+		panic(err)
+	}
+	// output:
+	// db1 16
+	// web1 4
+}
+
+func ExampleToTable() {
+	data := [][]string{
+		{"Name", "Age", "Joined"},
+		{"Alice", "33", "2017-03-17"},
+		{"Bob", "", "2016-11-02"},
+	}
+
+	table, err := ToTable(data)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(GenerateUsageUndecorated(table))
+	// output:
+	// []struct {
+	//	Name   string
+	//	Age    *int
+	//	Joined time.Time
+	// }
+}
+
+func ExampleDecodeYAML() {
+	v, err := DecodeYAML([]byte("hosts:\n  - name: web1\n    cpus: 4\n"))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%v\n", v)
+	// output:
+	// map[hosts:[map[cpus:4 name:web1]]]
+}
+
+func ExampleREPL() {
+	data := []struct {
+		Name string
+		Age  int
+	}{
+		{"Alice", 33},
+		{"Bob", 25},
+	}
+
+	input := strings.NewReader("len .\n{{range .}}{{.Name}};{{end}}\n:complete (head .) so\n:history\n")
+	if err := REPL(input, os.Stdout, data, nil); err != nil {
+		panic(err)
+	}
+	// output:
+	// > 2
+	// > Alice;Bob;
+	// > sort
+	// >    1  len .
+	//    2  {{range .}}{{.Name}};{{end}}
+	// >
+}
+
+func ExampleCheck() {
+	data := []struct {
+		Name   string
+		Volume int
+	}{}
+
+	err := Check(`{{range sort . "Volume"}}{{.Nmae}}{{end}}`, data, nil)
+	fmt.Println(err)
+	// output:
+	// 1:27: can't evaluate field Nmae in type struct { Name string; Volume int }, did you mean Name? Valid fields are: Name, Volume
+}
+
+type exampleAccount struct {
+	Owner   string
+	Balance int
+}
+
+func (a *exampleAccount) Close() string {
+	a.Balance = 0
+	return "closed"
+}
+
+func (a exampleAccount) Overdrawn() bool {
+	return a.Balance < 0
+}
+
+func ExampleOptAllowMethods() {
+	data := []exampleAccount{{"Mark", 10}, {"Alice", -5}}
+	cfg := NewConfig(OptAllFns, OptAllowMethods(exampleAccount{}, "Overdrawn"))
+
+	script := `{{range .}}{{.Owner}} {{.Overdrawn}}{{"\n"}}{{end}}`
+	if err := OutputToTemplate(os.Stdout, "accounts", script, data, cfg); err != nil {
+		panic(err)
+	}
+
+	script = `{{range .}}{{.Close}}{{end}}`
+	err := OutputToTemplate(os.Stdout, "accounts", script, data, cfg)
+	fmt.Println(err)
+	// output:
+	// Mark false
+	// Alice true
+	// 1:13: method Close of type tfortools.exampleAccount is not permitted
+}
+
+func ExampleFieldNames() {
+	fmt.Println(FieldNames([]string{"name", "first name", "1st", "Name", ""}))
+	// output:
+	// [Name First_name X1st Name_2 X]
+}
@@ -21,6 +21,8 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math/rand"
 	"reflect"
 	"regexp"
 	"sort"
@@ -28,13 +30,12 @@ import (
 	"strings"
 	"text/tabwriter"
 	"text/template"
-	"unicode"
-	"unicode/utf8"
+	"time"
 )
 
 type tableHeading struct {
-	name  string
-	index int
+	name string
+	key  string
 }
 
 var sortAscMap = map[reflect.Kind]func(interface{}, interface{}) bool{
@@ -122,34 +123,35 @@ var sortDscMap = map[reflect.Kind]func(interface{}, interface{}) bool{
 }
 
 type valueSorter struct {
-	val   reflect.Value
-	field int
-	less  func(v1, v2 interface{}) bool
+	val    reflect.Value
+	field  string
+	less   func(v1, v2 interface{}) bool
+	e      *env
+	fnName string
 }
 
 func (v *valueSorter) Len() int {
 	return v.val.Len()
 }
 
-func (v *valueSorter) index(i int) reflect.Value {
-	val := v.val.Index(i)
-	if val.Kind() == reflect.Ptr {
-		val = reflect.Indirect(val)
-	}
-	return val
-}
-
+// Less orders the elements by the value of the sort field.  Elements that
+// do not contain the field, e.g., maps that lack the key, are always placed
+// at the end of the slice.
 func (v *valueSorter) Less(i, j int) bool {
-	iVal := v.index(i)
-	jVal := v.index(j)
-	return v.less(iVal.Field(v.field).Interface(), jVal.Field(v.field).Interface())
+	v.e.checkContext(v.fnName)
+	iVal := fieldByName(v.val.Index(i), v.field)
+	jVal := fieldByName(v.val.Index(j), v.field)
+	if !iVal.IsValid() || !jVal.IsValid() {
+		return iVal.IsValid()
+	}
+	return v.less(iVal.Interface(), jVal.Interface())
 }
 
 func (v *valueSorter) Swap(i, j int) {
-	iVal := v.index(i).Interface()
-	jVal := v.index(j).Interface()
-	v.index(i).Set(reflect.ValueOf(jVal))
-	v.index(j).Set(reflect.ValueOf(iVal))
+	tmp := reflect.New(v.val.Type().Elem()).Elem()
+	tmp.Set(v.val.Index(i))
+	v.val.Index(i).Set(v.val.Index(j))
+	v.val.Index(j).Set(tmp)
 }
 
 func getValue(obj interface{}) reflect.Value {
@@ -160,33 +162,102 @@ func getValue(obj interface{}) reflect.Value {
 	return val
 }
 
-func fatalf(name, format string, args ...interface{}) {
-	panic(template.ExecError{
-		Name: name,
-		Err:  fmt.Errorf(format, args...),
-	})
+// derefValue follows pointers and unwraps interfaces until it reaches a
+// concrete, non pointer value.  The zero Value is returned if a nil pointer
+// or interface is encountered.
+func derefValue(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	return v
 }
 
-func newValueSorter(obj interface{}, field string, ascending bool) *valueSorter {
-	val := reflect.ValueOf(obj)
-	typ := reflect.TypeOf(obj)
-	sTyp := typ.Elem()
-	if sTyp.Kind() == reflect.Ptr {
-		sTyp = sTyp.Elem()
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
 	}
+	return t
+}
 
-	var index int
-	var fTyp reflect.StructField
-	for index = 0; index < sTyp.NumField(); index++ {
-		fTyp = sTyp.Field(index)
-		if fTyp.Name == field {
-			break
+// compareDynamic compares two values whose types are not known until
+// runtime, e.g., the values of a map[string]interface{}.  Numbers are
+// compared numerically, times chronologically, strings lexically and anything
+// else by its string representation.  Values wrapped by formatCol are
+// compared using their underlying values.  It returns -1, 0 or 1.
+func compareDynamic(v1, v2 interface{}) int {
+	if f, ok := v1.(formattedValue); ok {
+		v1 = f.value
+	}
+	if f, ok := v2.(formattedValue); ok {
+		v2 = f.value
+	}
+	if t1, ok := v1.(time.Time); ok {
+		if t2, ok := v2.(time.Time); ok {
+			switch {
+			case t1.Before(t2):
+				return -1
+			case t1.After(t2):
+				return 1
+			}
+			return 0
+		}
+	}
+	if n1, ok := toFloat(v1); ok {
+		if n2, ok := toFloat(v2); ok {
+			switch {
+			case n1 < n2:
+				return -1
+			case n1 > n2:
+				return 1
+			}
+			return 0
 		}
 	}
-	if index == sTyp.NumField() {
-		fatalf("sort", "%s is not a valid field name", field)
+	return strings.Compare(fmt.Sprintf("%v", v1), fmt.Sprintf("%v", v2))
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	val := reflect.ValueOf(v)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(val.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(val.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return val.Float(), true
+	}
+	return 0, false
+}
+
+func (e *env) newValueSorter(fnName string, obj interface{}, field string, ascending bool) *valueSorter {
+	val := reflect.ValueOf(obj)
+	sTyp := derefType(val.Type().Elem())
+
+	var fTyp reflect.Type
+	switch sTyp.Kind() {
+	case reflect.Map:
+		if !hasField(val, field) {
+			fatalField(fnName, field, unionKeys(val))
+		}
+		fTyp = sTyp.Elem()
+	case reflect.Interface:
+		if !hasField(val, field) {
+			fatalField(fnName, field, unionKeys(val))
+		}
+		fTyp = sTyp
+	default:
+		var index int
+		for index = 0; index < sTyp.NumField(); index++ {
+			if sTyp.Field(index).Name == field {
+				break
+			}
+		}
+		if index == sTyp.NumField() {
+			fatalField(fnName, field, exportedFieldNames(sTyp))
+		}
+		fTyp = sTyp.Field(index).Type
 	}
-	fKind := fTyp.Type.Kind()
+	fKind := fTyp.Kind()
 
 	var lessFn func(interface{}, interface{}) bool
 	if ascending {
@@ -194,39 +265,116 @@ func newValueSorter(obj interface{}, field string, ascending bool) *valueSorter
 	} else {
 		lessFn = sortDscMap[fKind]
 	}
+	if lessFn == nil && (fKind == reflect.Interface || fTyp == formattedValueType) {
+		lessFn = func(v1, v2 interface{}) bool {
+			if ascending {
+				return compareDynamic(v1, v2) < 0
+			}
+			return compareDynamic(v2, v1) < 0
+		}
+	}
 	if lessFn == nil {
 		var stringer *fmt.Stringer
-		if !fTyp.Type.Implements(reflect.TypeOf(stringer).Elem()) {
-			fatalf("sort", "cannot sort fields of type %s", fKind)
+		if !fTyp.Implements(reflect.TypeOf(stringer).Elem()) {
+			fatalType(fnName, "cannot sort fields of type %s", fKind)
 		}
 		lessFn = func(v1, v2 interface{}) bool {
 			return v1.(fmt.Stringer).String() < v2.(fmt.Stringer).String()
 		}
 	}
 	return &valueSorter{
-		val:   val,
-		field: index,
-		less:  lessFn,
+		val:    val,
+		field:  field,
+		less:   lessFn,
+		e:      e,
+		fnName: fnName,
+	}
+}
+
+// fieldByName returns the exported field called name of the struct v or, if
+// v is a map with string keys, the value associated with the key name.  The
+// zero Value is returned if the field or key does not exist.
+func fieldByName(v reflect.Value, name string) reflect.Value {
+	v = derefValue(v)
+	switch v.Kind() {
+	case reflect.Struct:
+		sf, found := v.Type().FieldByName(name)
+		if !found || sf.PkgPath != "" {
+			return reflect.Value{}
+		}
+		return v.FieldByIndex(sf.Index)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}
+		}
+		return v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+	}
+	return reflect.Value{}
+}
+
+// isNil returns true if v is a nil pointer or interface.
+func isNil(v reflect.Value) bool {
+	return (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil()
+}
+
+// derefPtr follows pointers until it reaches a non pointer value or a nil
+// pointer, which is returned.
+func derefPtr(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
 	}
+	return v
 }
 
+// findField follows the field path fieldPath from v.  The zero Value is
+// returned if a field or map key in the path does not exist.  If a nil
+// pointer or interface is encountered before the end of the path is
+// reached, the traversal stops and that nil value is returned.
 func findField(fieldPath []string, v reflect.Value) reflect.Value {
 	f := v
 	for _, seg := range fieldPath {
-		f = f.FieldByName(seg)
-		if f.Kind() == reflect.Ptr {
-			f = reflect.Indirect(f)
+		for (f.Kind() == reflect.Ptr || f.Kind() == reflect.Interface) && !f.IsNil() {
+			f = f.Elem()
+		}
+		if isNil(f) {
+			break
+		}
+		f = fieldByName(f, seg)
+		if !f.IsValid() {
+			break
 		}
 	}
 	return f
 }
 
+// checkFieldPath ensures that the structure fields named in fieldPath exist
+// in the type t.  Map keys and the dynamic types of interfaces are only known
+// at runtime, so any segments of the path that follow a map or an interface
+// are not checked.
+func checkFieldPath(fnName string, fieldPath []string, t reflect.Type) {
+	for _, seg := range fieldPath {
+		t = derefType(t)
+		switch t.Kind() {
+		case reflect.Map, reflect.Interface:
+			return
+		case reflect.Struct:
+			sf, found := t.FieldByName(seg)
+			if !found {
+				fatalField(fnName, seg, exportedFieldNames(t))
+			}
+			t = sf.Type
+		default:
+			fatalFieldf(fnName, seg, "%s is not a valid field name", seg)
+		}
+	}
+}
+
 func findFieldType(fnName string, fieldPath []string, t reflect.Type) reflect.Type {
 	f := t
 	for _, seg := range fieldPath {
 		sf, found := f.FieldByName(seg)
 		if !found {
-			fatalf(fnName, "Field %s not found", seg)
+			fatalFieldf(fnName, seg, "Field %s not found%s", seg, fieldHint(seg, exportedFieldNames(f)))
 		}
 		f = sf.Type
 		if f.Kind() == reflect.Ptr {
@@ -236,29 +384,55 @@ func findFieldType(fnName string, fieldPath []string, t reflect.Type) reflect.Ty
 	return f
 }
 
-func filterField(fnName string, obj interface{}, field, val string, cmp func(string, string) bool) interface{} {
-	defer func() {
-		err := recover()
-		if err != nil {
-			fatalf(fnName, "Invalid use of filter: %v", err)
+// formatCell formats the value of a single table cell.  Missing values,
+// e.g., keys that are not present in a map, are output as empty strings.
+// Nil pointers and interfaces are output using the env's nil placeholder.
+// Values wrapped by formatCol are output using their own format.
+func (e *env) formatCell(format string, v reflect.Value) string {
+	if !v.IsValid() {
+		return ""
+	}
+	if isNil(v) {
+		return e.nilPlaceholder
+	}
+	i := v.Interface()
+	if f, ok := i.(formattedValue); ok {
+		if f.value == nil {
+			return e.nilPlaceholder
 		}
-	}()
+		return f.String()
+	}
+	return fmt.Sprintf(format, i)
+}
 
-	list := getValue(obj)
+// recoverExecError converts a panic into an ExecError for the function
+// fnName.  ExecErrors raised by fatalf are passed through unmodified.
+func recoverExecError(fnName, format string) {
+	err := recover()
+	if err == nil {
+		return
+	}
+	if execErr, ok := err.(template.ExecError); ok {
+		panic(execErr)
+	}
+	raise(KindInternal, fnName, "", format, err)
+}
+
+// filterValues returns a new slice containing the elements of obj for which
+// match returns true.  match is passed the value identified by the field
+// path field.
+func (e *env) filterValues(fnName string, obj interface{}, field string, match func(reflect.Value) bool) interface{} {
+	defer recoverExecError(fnName, "Invalid use of filter: %v")
+
+	list := assertSlice(fnName, obj)
 	filtered := reflect.MakeSlice(list.Type(), 0, list.Len())
 
 	fieldPath := strings.Split(field, ".")
+	checkFieldPath(fnName, fieldPath, list.Type().Elem())
 
 	for i := 0; i < list.Len(); i++ {
-		v := list.Index(i)
-		if v.Kind() == reflect.Ptr {
-			v = reflect.Indirect(v)
-		}
-
-		f := findField(fieldPath, v)
-
-		strVal := fmt.Sprintf("%v", f.Interface())
-		if cmp(strVal, val) {
+		e.checkContext(fnName)
+		if match(derefPtr(findField(fieldPath, list.Index(i)))) {
 			filtered = reflect.Append(filtered, list.Index(i))
 		}
 	}
@@ -266,30 +440,53 @@ func filterField(fnName string, obj interface{}, field, val string, cmp func(str
 	return filtered.Interface()
 }
 
-func filterByField(obj interface{}, field, val string) interface{} {
-	return filterField("filter", obj, field, val, func(a, b string) bool {
+// filterField filters obj by comparing the string representation of the
+// field identified by field with val.  Missing and nil fields never match.
+func (e *env) filterField(fnName string, obj interface{}, field, val string, cmp func(string, string) bool) interface{} {
+	return e.filterValues(fnName, obj, field, func(f reflect.Value) bool {
+		if !f.IsValid() || isNil(f) {
+			return false
+		}
+		return cmp(fmt.Sprintf("%v", f.Interface()), val)
+	})
+}
+
+func (e *env) filterByNil(obj interface{}, field string) interface{} {
+	return e.filterValues("filterNil", obj, field, func(f reflect.Value) bool {
+		return !f.IsValid() || isNil(f)
+	})
+}
+
+func (e *env) filterByNotNil(obj interface{}, field string) interface{} {
+	return e.filterValues("filterNotNil", obj, field, func(f reflect.Value) bool {
+		return f.IsValid() && !isNil(f)
+	})
+}
+
+func (e *env) filterByField(obj interface{}, field, val string) interface{} {
+	return e.filterField("filter", obj, field, val, func(a, b string) bool {
 		return a == b
 	})
 }
 
-func filterByContains(obj interface{}, field, val string) interface{} {
-	return filterField("filterContains", obj, field, val, strings.Contains)
+func (e *env) filterByContains(obj interface{}, field, val string) interface{} {
+	return e.filterField("filterContains", obj, field, val, strings.Contains)
 }
 
-func filterByFolded(obj interface{}, field, val string) interface{} {
-	return filterField("filterFolded", obj, field, val, strings.EqualFold)
+func (e *env) filterByFolded(obj interface{}, field, val string) interface{} {
+	return e.filterField("filterFolded", obj, field, val, strings.EqualFold)
 }
 
-func filterByHasPrefix(obj interface{}, field, val string) interface{} {
-	return filterField("filterHasPrefix", obj, field, val, strings.HasPrefix)
+func (e *env) filterByHasPrefix(obj interface{}, field, val string) interface{} {
+	return e.filterField("filterHasPrefix", obj, field, val, strings.HasPrefix)
 }
 
-func filterByHasSuffix(obj interface{}, field, val string) interface{} {
-	return filterField("filterHasSuffix", obj, field, val, strings.HasSuffix)
+func (e *env) filterByHasSuffix(obj interface{}, field, val string) interface{} {
+	return e.filterField("filterHasSuffix", obj, field, val, strings.HasSuffix)
 }
 
-func filterByRegexp(obj interface{}, field, val string) interface{} {
-	return filterField("filterRegexp", obj, field, val, func(a, b string) bool {
+func (e *env) filterByRegexp(obj interface{}, field, val string) interface{} {
+	return e.filterField("filterRegexp", obj, field, val, func(a, b string) bool {
 		matched, err := regexp.MatchString(b, a)
 		if err != nil {
 			fatalf("filter", "Invalid regexp: %v", err)
@@ -298,39 +495,30 @@ func filterByRegexp(obj interface{}, field, val string) interface{} {
 	})
 }
 
-func selectFieldBase(obj interface{}, field, format string) string {
-	defer func() {
-		err := recover()
-		if err != nil {
-			fatalf("select", "Invalid use of select: %v", err)
-		}
-	}()
+func (e *env) selectFieldBase(obj interface{}, field, format string) string {
+	defer recoverExecError("select", "Invalid use of select: %v")
 
 	var b bytes.Buffer
-	list := getValue(obj)
+	list := assertSlice("select", obj)
 
 	fieldPath := strings.Split(field, ".")
+	checkFieldPath("select", fieldPath, list.Type().Elem())
 
 	for i := 0; i < list.Len(); i++ {
-		v := list.Index(i)
-		if v.Kind() == reflect.Ptr {
-			v = reflect.Indirect(v)
-		}
-
-		f := findField(fieldPath, v)
-
-		fmt.Fprintf(&b, format+"\n", f.Interface())
+		e.checkContext("select")
+		f := derefPtr(findField(fieldPath, list.Index(i)))
+		fmt.Fprintln(&b, e.formatCell(format, f))
 	}
 
 	return string(b.Bytes())
 }
 
-func selectField(obj interface{}, field string) string {
-	return selectFieldBase(obj, field, "%v")
+func (e *env) selectField(obj interface{}, field string) string {
+	return e.selectFieldBase(obj, field, "%v")
 }
 
-func selectFieldAlt(obj interface{}, field string) string {
-	return selectFieldBase(obj, field, "%#v")
+func (e *env) selectFieldAlt(obj interface{}, field string) string {
+	return e.selectFieldBase(obj, field, "%#v")
 }
 
 func toJSON(obj interface{}) string {
@@ -341,7 +529,7 @@ func toJSON(obj interface{}) string {
 	return string(b)
 }
 
-func toCSV(obj interface{}, skipHeader ...bool) string {
+func (e *env) toCSV(obj interface{}, skipHeader ...bool) string {
 	var data [][]string
 	var buf bytes.Buffer
 
@@ -351,36 +539,21 @@ func toCSV(obj interface{}, skipHeader ...bool) string {
 		return buf.String()
 	}
 
-	assertCollectionOfStructs("toCSV", reflect.ValueOf(obj))
-	v := reflect.ValueOf(obj)
+	v := getValue(obj)
+	headings := e.getTableHeadings("toCSV", v)
 	data = make([][]string, 0, v.Len()+1)
 	if len(skipHeader) == 0 || !skipHeader[0] {
-		styp := v.Type().Elem()
-		if styp.Kind() == reflect.Ptr {
-			styp = styp.Elem()
-		}
-		var row []string
-		for i := 0; i < styp.NumField(); i++ {
-			field := styp.Field(i)
-			if field.PkgPath != "" {
-				continue
-			}
-			row = append(row, field.Name)
+		row := make([]string, 0, len(headings))
+		for _, h := range headings {
+			row = append(row, h.name)
 		}
 		data = append(data, row)
 	}
 	for i := 0; i < v.Len(); i++ {
-		var row []string
-		s := v.Index(i)
-		if s.Kind() == reflect.Ptr {
-			s = s.Elem()
-		}
-		for j := 0; j < s.NumField(); j++ {
-			field := s.Field(j)
-			if s.Type().Field(j).PkgPath != "" {
-				continue
-			}
-			row = append(row, fmt.Sprintf("%v", field.Interface()))
+		e.checkContext("tocsv")
+		row := make([]string, 0, len(headings))
+		for _, h := range headings {
+			row = append(row, e.formatCell("%v", findField([]string{h.key}, v.Index(i))))
 		}
 		data = append(data, row)
 	}
@@ -393,41 +566,128 @@ func assertCollectionOfStructs(fnName string, v reflect.Value) {
 	typ := v.Type()
 	kind := typ.Kind()
 	if kind != reflect.Slice && kind != reflect.Array {
-		fatalf(fnName, "slice or an array of structs or pointers to structs expected")
+		fatalType(fnName, "slice or an array of structs or pointers to structs expected")
 	}
 	styp := typ.Elem()
 	if !(styp.Kind() == reflect.Struct ||
 		(styp.Kind() == reflect.Ptr && styp.Elem().Kind() == reflect.Struct)) {
-		fatalf(fnName, "slice or an array of structs or pointers to structs expected")
+		fatalType(fnName, "slice or an array of structs or pointers to structs expected")
 	}
 }
 
-func getTableHeadings(fnName string, v reflect.Value) []tableHeading {
-	assertCollectionOfStructs(fnName, v)
+func isStringMap(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String
+}
 
+// assertCollectionOfRows is similar to assertCollectionOfStructs but it
+// also accepts slices of maps with string keys and slices of interfaces.
+// Each map is treated as a row of a table whose columns are identified by
+// the map's keys.  The elements of a slice of interfaces may hold structs
+// or maps of different types.
+func assertCollectionOfRows(fnName string, v reflect.Value) {
 	typ := v.Type()
-	styp := typ.Elem()
-	if styp.Kind() == reflect.Ptr {
-		styp = styp.Elem()
+	kind := typ.Kind()
+	if kind != reflect.Slice && kind != reflect.Array {
+		fatalType(fnName, "slice or an array of structs or maps expected")
+	}
+	styp := derefType(typ.Elem())
+	if styp.Kind() != reflect.Struct && styp.Kind() != reflect.Interface &&
+		!isStringMap(styp) {
+		fatalType(fnName, "slice or an array of structs or maps expected")
+	}
+}
+
+// rowKeys returns the names of the columns provided by a single row, i.e.,
+// the exported non-channel fields of a struct in declaration order or the
+// sorted keys of a map.  Nil values and values of other types have no
+// columns.
+func rowKeys(el reflect.Value) []string {
+	el = derefValue(el)
+	var keys []string
+	switch {
+	case el.Kind() == reflect.Struct:
+		styp := el.Type()
+		for i := 0; i < styp.NumField(); i++ {
+			field := styp.Field(i)
+			if field.PkgPath != "" || ignoreKind(field.Type.Kind()) {
+				continue
+			}
+			keys = append(keys, field.Name)
+		}
+	case el.Kind() == reflect.Map && isStringMap(el.Type()):
+		for _, k := range el.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+	}
+	return keys
+}
+
+// unionKeys returns the union of the columns of all the rows in the slice
+// v.  The columns are returned in the order in which they are first seen.
+func unionKeys(v reflect.Value) []string {
+	seen := make(map[string]struct{})
+	var keys []string
+	for i := 0; i < v.Len(); i++ {
+		for _, key := range rowKeys(v.Index(i)) {
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// hasField returns true if at least one of the rows in the slice v
+// contains the field or key called name.
+func hasField(v reflect.Value, name string) bool {
+	for i := 0; i < v.Len(); i++ {
+		if fieldByName(v.Index(i), name).IsValid() {
+			return true
+		}
 	}
+	return false
+}
+
+func (e *env) getTableHeadings(fnName string, v reflect.Value) []tableHeading {
+	assertCollectionOfRows(fnName, v)
+
+	styp := derefType(v.Type().Elem())
 
 	var headings []tableHeading
+	if styp.Kind() != reflect.Struct {
+		keys := unionKeys(v)
+		if styp.Kind() == reflect.Map && !e.firstSeenKeys {
+			sort.Strings(keys)
+		}
+		for _, k := range keys {
+			headings = append(headings, tableHeading{name: k, key: k})
+		}
+		return headings
+	}
+
 	for i := 0; i < styp.NumField(); i++ {
 		field := styp.Field(i)
 		if field.PkgPath != "" || ignoreKind(field.Type.Kind()) {
 			continue
 		}
-		headings = append(headings, tableHeading{name: field.Name, index: i})
+		headings = append(headings, tableHeading{name: field.Name, key: field.Name})
 	}
 
 	if len(headings) == 0 {
-		fatalf(fnName, "structures must contain at least one exported non-channel field")
+		fatalType(fnName, "structures must contain at least one exported non-channel field")
 	}
 	return headings
 }
 
-func createTable(v reflect.Value, minWidth, tabWidth, padding int,
+func (e *env) createTable(fnName string, v reflect.Value, minWidth, tabWidth, padding int,
 	format string, headings []tableHeading) string {
+	if len(headings) == 0 {
+		return ""
+	}
+
 	var b bytes.Buffer
 	w := tabwriter.NewWriter(&b, minWidth, tabWidth, padding, ' ', 0)
 	for _, h := range headings {
@@ -436,12 +696,10 @@ func createTable(v reflect.Value, minWidth, tabWidth, padding int,
 	fmt.Fprintln(w)
 
 	for i := 0; i < v.Len(); i++ {
+		e.checkContext(fnName)
 		el := v.Index(i)
-		if el.Kind() == reflect.Ptr {
-			el = el.Elem()
-		}
 		for _, h := range headings {
-			fmt.Fprintf(w, format+"\t", el.Field(h.index).Interface())
+			fmt.Fprintf(w, "%s\t", e.formatCell(format, findField([]string{h.key}, el)))
 		}
 		fmt.Fprintln(w)
 	}
@@ -450,21 +708,22 @@ func createTable(v reflect.Value, minWidth, tabWidth, padding int,
 	return b.String()
 }
 
-func createHTable(v reflect.Value, minWidth, tabWidth, padding int,
+func (e *env) createHTable(fnName string, v reflect.Value, minWidth, tabWidth, padding int,
 	format string, headings []tableHeading) string {
+	if len(headings) == 0 {
+		return ""
+	}
+
 	var b bytes.Buffer
 	w := tabwriter.NewWriter(&b, minWidth, tabWidth, padding, ' ', 0)
 	for i := 0; i < v.Len(); i++ {
+		e.checkContext(fnName)
 		if i > 0 {
 			fmt.Fprintln(w)
 		}
 		for _, h := range headings {
 			fmt.Fprintf(w, "%s:\t", h.name)
-			el := v.Index(i)
-			if el.Kind() == reflect.Ptr {
-				el = el.Elem()
-			}
-			fmt.Fprintf(w, format+"\t", el.Field(h.index).Interface())
+			fmt.Fprintf(w, "%s\t", e.formatCell(format, findField([]string{h.key}, v.Index(i))))
 			fmt.Fprintln(w)
 		}
 	}
@@ -473,20 +732,20 @@ func createHTable(v reflect.Value, minWidth, tabWidth, padding int,
 	return b.String()
 }
 
-func table(obj interface{}) string {
+func (e *env) table(obj interface{}) string {
 	val := getValue(obj)
-	return createTable(val, 8, 8, 1, "%v", getTableHeadings("table", val))
+	return e.createTable("table", val, 8, 8, 1, "%v", e.getTableHeadings("table", val))
 }
 
-func tableAlt(obj interface{}) string {
+func (e *env) tableAlt(obj interface{}) string {
 	val := getValue(obj)
-	return createTable(val, 8, 8, 1, "%#v", getTableHeadings("table", val))
+	return e.createTable("tablealt", val, 8, 8, 1, "%#v", e.getTableHeadings("table", val))
 }
 
-func xHeadings(fnName string, val reflect.Value, userHeadings []string) []tableHeading {
-	headings := getTableHeadings(fnName, val)
+func (e *env) xHeadings(fnName string, val reflect.Value, userHeadings []string) []tableHeading {
+	headings := e.getTableHeadings(fnName, val)
 	if len(headings) < len(userHeadings) {
-		fatalf(fnName, "Too many headings specified.  Max permitted %d got %d",
+		fatalArity(fnName, "Too many headings specified.  Max permitted %d got %d",
 			len(headings), len(userHeadings))
 	}
 	for i := range userHeadings {
@@ -495,53 +754,59 @@ func xHeadings(fnName string, val reflect.Value, userHeadings []string) []tableH
 	return headings
 }
 
-func tablex(obj interface{}, minWidth, tabWidth, padding int, userHeadings ...string) string {
+func (e *env) tablex(obj interface{}, minWidth, tabWidth, padding int, userHeadings ...string) string {
 	val := getValue(obj)
-	headings := xHeadings("tablex", val, userHeadings)
-	return createTable(val, minWidth, tabWidth, padding, "%v", headings)
+	headings := e.xHeadings("tablex", val, userHeadings)
+	return e.createTable("tablex", val, minWidth, tabWidth, padding, "%v", headings)
 }
 
-func tablexAlt(obj interface{}, minWidth, tabWidth, padding int, userHeadings ...string) string {
+func (e *env) tablexAlt(obj interface{}, minWidth, tabWidth, padding int, userHeadings ...string) string {
 	val := getValue(obj)
-	headings := xHeadings("tablexalt", val, userHeadings)
-	return createTable(val, minWidth, tabWidth, padding, "%#v", headings)
+	headings := e.xHeadings("tablexalt", val, userHeadings)
+	return e.createTable("tablexalt", val, minWidth, tabWidth, padding, "%#v", headings)
 }
 
-func htable(obj interface{}) string {
+func (e *env) htable(obj interface{}) string {
 	val := getValue(obj)
-	return createHTable(val, 8, 8, 1, "%v", getTableHeadings("htable", val))
+	return e.createHTable("htable", val, 8, 8, 1, "%v", e.getTableHeadings("htable", val))
 }
 
-func htableAlt(obj interface{}) string {
+func (e *env) htableAlt(obj interface{}) string {
 	val := getValue(obj)
-	return createHTable(val, 8, 8, 1, "%#v", getTableHeadings("htablealt", val))
+	return e.createHTable("htablealt", val, 8, 8, 1, "%#v", e.getTableHeadings("htablealt", val))
 }
 
-func htablex(obj interface{}, minWidth, tabWidth, padding int, userHeadings ...string) string {
+func (e *env) htablex(obj interface{}, minWidth, tabWidth, padding int, userHeadings ...string) string {
 	val := getValue(obj)
-	headings := xHeadings("htablex", val, userHeadings)
-	return createHTable(val, minWidth, tabWidth, padding, "%v", headings)
+	headings := e.xHeadings("htablex", val, userHeadings)
+	return e.createHTable("htablex", val, minWidth, tabWidth, padding, "%v", headings)
 }
 
-func htablexAlt(obj interface{}, minWidth, tabWidth, padding int, userHeadings ...string) string {
+func (e *env) htablexAlt(obj interface{}, minWidth, tabWidth, padding int, userHeadings ...string) string {
 	val := getValue(obj)
-	headings := xHeadings("htablexalt", val, userHeadings)
-	return createHTable(val, minWidth, tabWidth, padding, "%#v", headings)
+	headings := e.xHeadings("htablexalt", val, userHeadings)
+	return e.createHTable("htablexalt", val, minWidth, tabWidth, padding, "%#v", headings)
 }
 
-func cols(obj interface{}, fields ...string) interface{} {
-	val := getValue(obj)
-	assertCollectionOfStructs("cols", val)
-	if len(fields) == 0 {
-		fatalf("cols", "at least one column name must be specified")
+// selectMapKeys returns a new map containing only those entries of m whose
+// keys are listed in fields.
+func selectMapKeys(m reflect.Value, fields []string) reflect.Value {
+	newMap := reflect.MakeMap(m.Type())
+	for _, f := range fields {
+		v := fieldByName(m, f)
+		if v.IsValid() {
+			newMap.SetMapIndex(reflect.ValueOf(f).Convert(m.Type().Key()), v)
+		}
 	}
+	return newMap
+}
 
+// structCols returns a new struct type containing only the exported
+// non-channel fields of styp that are named in fields, together with the
+// indices of those fields in styp.
+func structCols(styp reflect.Type, fields []string) (reflect.Type, []int) {
 	var newFields []reflect.StructField
 	var indicies []int
-	styp := val.Type().Elem()
-	if styp.Kind() == reflect.Ptr {
-		styp = styp.Elem()
-	}
 	for i := 0; i < styp.NumField(); i++ {
 		field := styp.Field(i)
 		if field.PkgPath != "" || ignoreKind(field.Type.Kind()) {
@@ -561,69 +826,177 @@ func cols(obj interface{}, fields ...string) interface{} {
 		indicies = append(indicies, i)
 		newFields = append(newFields, field)
 	}
+	return reflect.StructOf(newFields), indicies
+}
+
+func copyStructCols(sval reflect.Value, newStyp reflect.Type, indicies []int) reflect.Value {
+	newSval := reflect.New(newStyp).Elem()
+	for j, origIndex := range indicies {
+		newSval.Field(j).Set(sval.Field(origIndex))
+	}
+	return newSval
+}
+
+// colsMap is the implementation of cols for slices of maps.  It returns a
+// new slice of maps each of which contains only the requested keys.
+func (e *env) colsMap(val reflect.Value, fields []string) interface{} {
+	mTyp := derefType(val.Type().Elem())
+	newVal := reflect.MakeSlice(reflect.SliceOf(mTyp), val.Len(), val.Len())
+	for i := 0; i < val.Len(); i++ {
+		e.checkContext("cols")
+		m := derefValue(val.Index(i))
+		if m.IsValid() {
+			newVal.Index(i).Set(selectMapKeys(m, fields))
+		}
+	}
+
+	return newVal.Interface()
+}
+
+// colsDynamic is the implementation of cols for slices of interfaces.  Each
+// element of the new slice holds a value derived from the dynamic type of
+// the corresponding element in val; a new struct type for structs and a
+// new map for maps.
+func (e *env) colsDynamic(val reflect.Value, fields []string) interface{} {
+	type newType struct {
+		styp     reflect.Type
+		indicies []int
+	}
+	types := make(map[reflect.Type]newType)
+
+	newVal := reflect.MakeSlice(reflect.SliceOf(val.Type().Elem()), val.Len(), val.Len())
+	for i := 0; i < val.Len(); i++ {
+		e.checkContext("cols")
+		el := derefValue(val.Index(i))
+		switch {
+		case el.Kind() == reflect.Struct:
+			nt, ok := types[el.Type()]
+			if !ok {
+				nt.styp, nt.indicies = structCols(el.Type(), fields)
+				types[el.Type()] = nt
+			}
+			newVal.Index(i).Set(copyStructCols(el, nt.styp, nt.indicies))
+		case el.Kind() == reflect.Map && isStringMap(el.Type()):
+			newVal.Index(i).Set(selectMapKeys(el, fields))
+		}
+	}
 
+	return newVal.Interface()
+}
+
+func (e *env) cols(obj interface{}, fields ...string) interface{} {
+	val := getValue(obj)
+	assertCollectionOfRows("cols", val)
+	if len(fields) == 0 {
+		fatalArity("cols", "at least one column name must be specified")
+	}
+
+	styp := derefType(val.Type().Elem())
+	if styp.Kind() != reflect.Struct {
+		for _, f := range fields {
+			if !hasField(val, f) {
+				fatalField("cols", f, unionKeys(val))
+			}
+		}
+		if styp.Kind() == reflect.Map {
+			return e.colsMap(val, fields)
+		}
+		return e.colsDynamic(val, fields)
+	}
+
+	newStyp, indicies := structCols(styp, fields)
 	if len(indicies) != len(fields) {
-		fatalf("cols", "not all column names are valid")
+		for _, f := range fields {
+			if _, found := newStyp.FieldByName(f); !found {
+				fatalField("cols", f, exportedFieldNames(styp))
+			}
+		}
+		fatalFieldf("cols", "", "not all column names are valid")
 	}
 
-	newStyp := reflect.StructOf(newFields)
 	newVal := reflect.MakeSlice(reflect.SliceOf(newStyp), val.Len(), val.Len())
 	for i := 0; i < val.Len(); i++ {
+		e.checkContext("cols")
 		sval := val.Index(i)
 		if sval.Kind() == reflect.Ptr {
 			sval = sval.Elem()
 		}
-		newSval := reflect.New(newStyp).Elem()
-		for j, origIndex := range indicies {
-			newSval.Field(j).Set(sval.Field(origIndex))
-		}
-		newVal.Index(i).Set(newSval)
+		newVal.Index(i).Set(copyStructCols(sval, newStyp, indicies))
 	}
 
 	return newVal.Interface()
 }
 
-func sortSlice(obj interface{}, field string, direction ...string) interface{} {
+// parseDirection checks the optional direction parameter accepted by the
+// sorting functions, returning true if the direction is ascending.
+func parseDirection(fnName string, direction []string) bool {
 	ascending := true
 	if len(direction) > 1 {
-		fatalf("sort", "Too many parameters passed to sort")
+		fatalArity(fnName, "Too many parameters passed to %s", fnName)
 	} else if len(direction) == 1 {
 		if direction[0] == "dsc" {
 			ascending = false
 		} else if direction[0] != "asc" {
-			fatalf("sort", "direction parameter must be \"asc\" or \"dsc\"")
+			fatalf(fnName, "direction parameter must be \"asc\" or \"dsc\"")
 		}
 	}
+	return ascending
+}
+
+func (e *env) sortSlice(obj interface{}, field string, direction ...string) interface{} {
+	ascending := parseDirection("sort", direction)
 
 	val := getValue(obj)
-	assertCollectionOfStructs("sort", val)
+	assertCollectionOfRows("sort", val)
 
-	copy := reflect.MakeSlice(reflect.SliceOf(val.Type().Elem()), 0, val.Len())
-	for i := 0; i < val.Len(); i++ {
-		copy = reflect.Append(copy, val.Index(i))
-	}
+	copy := copyRange(val, 0, val.Len())
 
 	newobj := copy.Interface()
-	vs := newValueSorter(newobj, field, ascending)
+	vs := e.newValueSorter("sort", newobj, field, ascending)
 	sort.Sort(vs)
 	return newobj
 }
 
-func rows(obj interface{}, rows ...int) interface{} {
+// rowIndices converts the arguments passed to rows into a slice of
+// integers.  Each argument may be an integer or a slice or array of
+// integers, such as the one returned by seq.
+func rowIndices(args []interface{}) []int {
+	var indices []int
+	for _, arg := range args {
+		val := derefValue(reflect.ValueOf(arg))
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			indices = append(indices, int(val.Int()))
+			continue
+		case reflect.Slice, reflect.Array:
+			if kind := val.Type().Elem().Kind(); kind >= reflect.Int && kind <= reflect.Int64 {
+				for i := 0; i < val.Len(); i++ {
+					indices = append(indices, int(val.Index(i).Int()))
+				}
+				continue
+			}
+		}
+		fatalType("rows", "row indices must be integers or slices of integers, found %T", arg)
+	}
+	return indices
+}
+
+func rows(obj interface{}, args ...interface{}) interface{} {
 	val := getValue(obj)
 	typ := val.Type()
 	kind := typ.Kind()
 	if kind != reflect.Slice && kind != reflect.Array {
-		fatalf("rows", "slice or an array of expected")
+		fatalType("rows", "slice or an array expected")
 	}
 
+	rows := rowIndices(args)
 	if len(rows) == 0 {
-		fatalf("rows", "at least one row index must be specified")
+		fatalArity("rows", "at least one row index must be specified")
 	}
 
 	copy := reflect.MakeSlice(reflect.SliceOf(val.Type().Elem()), 0, len(rows))
 	for _, row := range rows {
-		if row < val.Len() {
+		if row >= 0 && row < val.Len() {
 			copy = reflect.Append(copy, val.Index(row))
 		}
 	}
@@ -636,50 +1009,114 @@ func assertSliceAndRetrieveCount(fnName string, obj interface{}, count ...int) (
 	typ := val.Type()
 	kind := typ.Kind()
 	if kind != reflect.Slice && kind != reflect.Array {
-		fatalf(fnName, "slice or an array of expected")
+		fatalType(fnName, "slice or an array expected")
 	}
 
 	rows := 1
 	if len(count) == 1 {
 		rows = count[0]
 	} else if len(count) > 1 {
-		fatalf(fnName, "accepts a maximum of two arguments expected")
+		fatalArity(fnName, "accepts a maximum of two arguments expected")
 	}
 
 	return val, rows
 }
 
+// copyElements returns a new slice of n elements of type typ.  The value
+// of the ith element is returned by elem(i).
+func copyElements(typ reflect.Type, n int, elem func(i int) reflect.Value) reflect.Value {
+	copy := reflect.MakeSlice(reflect.SliceOf(typ), 0, n)
+	for i := 0; i < n; i++ {
+		copy = reflect.Append(copy, elem(i))
+	}
+	return copy
+}
+
+// copyRange returns a new slice containing the elements of val from lo up
+// to, but not including, hi.
+func copyRange(val reflect.Value, lo, hi int) reflect.Value {
+	return copyElements(val.Type().Elem(), hi-lo, func(i int) reflect.Value {
+		return val.Index(lo + i)
+	})
+}
+
 func head(obj interface{}, count ...int) interface{} {
 	val, rows := assertSliceAndRetrieveCount("head", obj, count...)
-	copy := reflect.MakeSlice(reflect.SliceOf(val.Type().Elem()), 0, rows)
-	for i := 0; i < rows && i < val.Len(); i++ {
-		copy = reflect.Append(copy, val.Index(i))
+	if rows > val.Len() {
+		rows = val.Len()
 	}
-
-	return copy.Interface()
+	return copyRange(val, 0, rows).Interface()
 }
 
 func tail(obj interface{}, count ...int) interface{} {
 	val, rows := assertSliceAndRetrieveCount("tail", obj, count...)
-	copy := reflect.MakeSlice(reflect.SliceOf(val.Type().Elem()), 0, rows)
 	start := val.Len() - rows
 	if start < 0 {
 		start = 0
 	}
-	for i := start; i < val.Len(); i++ {
-		copy = reflect.Append(copy, val.Index(i))
+	return copyRange(val, start, val.Len()).Interface()
+}
+
+// selectIndices returns a new slice containing the elements of val
+// identified by indices, in the order in which they appear in indices.
+func selectIndices(val reflect.Value, indices []int) reflect.Value {
+	return copyElements(val.Type().Elem(), len(indices), func(i int) reflect.Value {
+		return val.Index(indices[i])
+	})
+}
+
+// newRand returns a random number generator initialised with the optional
+// seed.  If no seed is provided, the generator is seeded with the current
+// time.
+func newRand(fnName string, seed []int64) *rand.Rand {
+	switch len(seed) {
+	case 0:
+		return rand.New(rand.NewSource(time.Now().UnixNano()))
+	case 1:
+		return rand.New(rand.NewSource(seed[0]))
+	}
+	fatalArity(fnName, "accepts a maximum of one seed")
+	return nil
+}
+
+// permutation returns a pseudo random permutation of the integers [0,n).
+// The permutation is computed here rather than by rand.Perm so that the
+// output of shuffle and sample for a given seed is defined by this package
+// alone.
+func permutation(r *rand.Rand, n int) []int {
+	p := make([]int, n)
+	for i := range p {
+		p[i] = i
 	}
+	for i := n - 1; i > 0; i-- {
+		j := r.Intn(i + 1)
+		p[i], p[j] = p[j], p[i]
+	}
+	return p
+}
 
-	return copy.Interface()
+func shuffle(obj interface{}, seed ...int64) interface{} {
+	val := assertSlice("shuffle", obj)
+	r := newRand("shuffle", seed)
+	return selectIndices(val, permutation(r, val.Len())).Interface()
+}
+
+func sample(obj interface{}, count int, seed ...int64) interface{} {
+	val := assertSlice("sample", obj)
+	if count < 0 {
+		fatalf("sample", "sample size must not be negative")
+	}
+	r := newRand("sample", seed)
+	indices := permutation(r, val.Len())
+	if count < len(indices) {
+		indices = indices[:count]
+	}
+	sort.Ints(indices)
+	return selectIndices(val, indices).Interface()
 }
 
 func promote(obj interface{}, field string) interface{} {
-	defer func() {
-		err := recover()
-		if err != nil {
-			fatalf("promote", "Invalid use of promote: %v", err)
-		}
-	}()
+	defer recoverExecError("promote", "Invalid use of promote: %v")
 
 	if len(strings.TrimSpace(field)) == 0 {
 		fatalf("promote", "Empty field specifier given")
@@ -703,7 +1140,10 @@ func promote(obj interface{}, field string) interface{} {
 		if el.Kind() == reflect.Ptr {
 			el = reflect.Indirect(el)
 		}
-		el = findField(fieldPath, el)
+		el = derefPtr(findField(fieldPath, el))
+		if !el.IsValid() || isNil(el) {
+			el = reflect.Zero(ftype)
+		}
 		copy = reflect.Append(copy, el)
 	}
 
@@ -717,95 +1157,278 @@ func sliceof(obj interface{}) interface{} {
 	return sl.Interface()
 }
 
-func describe(obj interface{}) string {
+func (e *env) describe(obj interface{}) string {
 	var buf bytes.Buffer
-	generateIndentedUsage(&buf, obj)
+	generateIndentedUsage(&buf, obj, e.sandbox)
 	return buf.String()
 }
 
-func sanitizeName(name string) string {
-	var buf bytes.Buffer
+func assertMap(fnName string, obj interface{}) reflect.Value {
+	val := getValue(obj)
+	if val.Kind() != reflect.Map {
+		fatalType(fnName, "map expected")
+	}
+	return val
+}
 
-	name = strings.TrimSpace(name)
-	if name == "" {
-		return name
+// dynamicSorter sorts a slice of values, whose types may not be known until
+// runtime, using compareDynamic.
+type dynamicSorter struct {
+	vals      []reflect.Value
+	ascending bool
+}
+
+func (d *dynamicSorter) Len() int      { return len(d.vals) }
+func (d *dynamicSorter) Swap(i, j int) { d.vals[i], d.vals[j] = d.vals[j], d.vals[i] }
+func (d *dynamicSorter) Less(i, j int) bool {
+	if d.ascending {
+		return compareDynamic(d.vals[i].Interface(), d.vals[j].Interface()) < 0
 	}
+	return compareDynamic(d.vals[j].Interface(), d.vals[i].Interface()) < 0
+}
 
-	rune, len := utf8.DecodeRuneInString(name)
-	if !unicode.IsLetter(rune) {
-		_, _ = buf.WriteRune('X')
-	} else {
-		_, _ = buf.WriteRune(unicode.ToTitle(rune))
-		name = name[len:]
+// sortedMapKeys returns the keys of the map val in ascending or descending
+// order.
+func sortedMapKeys(val reflect.Value, ascending bool) []reflect.Value {
+	keys := val.MapKeys()
+	sort.Sort(&dynamicSorter{keys, ascending})
+	return keys
+}
+
+func valuesToSlice(typ reflect.Type, vals []reflect.Value) interface{} {
+	return copyElements(typ, len(vals), func(i int) reflect.Value {
+		return vals[i]
+	}).Interface()
+}
+
+func keys(obj interface{}, direction ...string) interface{} {
+	ascending := parseDirection("keys", direction)
+	val := assertMap("keys", obj)
+	return valuesToSlice(val.Type().Key(), sortedMapKeys(val, ascending))
+}
+
+func values(obj interface{}, direction ...string) interface{} {
+	val := assertMap("values", obj)
+
+	keys := sortedMapKeys(val, true)
+	vals := make([]reflect.Value, len(keys))
+	for i, k := range keys {
+		vals[i] = val.MapIndex(k)
+	}
+
+	if len(direction) > 0 {
+		ascending := parseDirection("values", direction)
+		sort.Stable(&dynamicSorter{vals, ascending})
+	}
+
+	return valuesToSlice(val.Type().Elem(), vals)
+}
+
+func entries(obj interface{}, direction ...string) interface{} {
+	ascending := parseDirection("entries", direction)
+	val := assertMap("entries", obj)
+
+	eTyp := reflect.StructOf([]reflect.StructField{
+		{Name: "Key", Type: val.Type().Key()},
+		{Name: "Value", Type: val.Type().Elem()},
+	})
+
+	keys := sortedMapKeys(val, ascending)
+	copy := reflect.MakeSlice(reflect.SliceOf(eTyp), 0, len(keys))
+	for _, k := range keys {
+		e := reflect.New(eTyp).Elem()
+		e.Field(0).Set(k)
+		e.Field(1).Set(val.MapIndex(k))
+		copy = reflect.Append(copy, e)
 	}
 
-	for _, rune := range name {
-		if !unicode.IsLetter(rune) && !unicode.IsDigit(rune) {
-			rune = '_'
+	return copy.Interface()
+}
+
+// formattedValue associates a value with the format used to output it.  It
+// is created by formatCol.  The format is either the name of one of the
+// namedFormatters, a printf style format or, if the value is a time.Time, a
+// time layout.  e provides the named formatters with access to the settings
+// of the Config object that created the template.
+type formattedValue struct {
+	value  interface{}
+	format string
+	e      *env
+}
+
+var formattedValueType = reflect.TypeOf(formattedValue{})
+var timeType = reflect.TypeOf(time.Time{})
+
+func isTimeLayout(format string) bool {
+	_, named := namedFormatters[format]
+	return !named && !strings.Contains(format, "%")
+}
+
+func (f formattedValue) String() string {
+	if fn, ok := namedFormatters[f.format]; ok {
+		return fn(f.e, f.value)
+	}
+	if isTimeLayout(f.format) {
+		if t, ok := f.value.(time.Time); ok {
+			return t.Format(timeLayout(f.format))
 		}
-		_, _ = buf.WriteRune(rune)
+		return fmt.Sprintf("%v", f.value)
 	}
+	return fmt.Sprintf(f.format, f.value)
+}
 
-	return buf.String()
+// Format outputs the formatted value when the value is printed with %v or
+// %s.  Other verbs are applied to the underlying value.
+func (f formattedValue) Format(s fmt.State, verb rune) {
+	if verb == 'v' || verb == 's' {
+		_, _ = io.WriteString(s, f.String())
+		return
+	}
+
+	format := "%"
+	for _, flag := range "+-# 0" {
+		if s.Flag(int(flag)) {
+			format += string(flag)
+		}
+	}
+	if w, ok := s.Width(); ok {
+		format += strconv.Itoa(w)
+	}
+	if p, ok := s.Precision(); ok {
+		format += "." + strconv.Itoa(p)
+	}
+	fmt.Fprintf(s, format+string(verb), f.value)
 }
 
-func guessType(v string) reflect.Type {
-	if p, err := strconv.Atoi(v); err == nil {
-		return reflect.TypeOf(p)
+// MarshalJSON ensures that the underlying value is output by tojson.
+func (f formattedValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.value)
+}
+
+func (e *env) newFormattedValue(v reflect.Value, format string) formattedValue {
+	v = derefPtr(v)
+	if !v.IsValid() || isNil(v) {
+		return formattedValue{format: format, e: e}
 	}
-	if p, err := strconv.ParseFloat(v, 64); err == nil {
-		return reflect.TypeOf(p)
+	return formattedValue{value: v.Interface(), format: format, e: e}
+}
+
+// formatStruct returns a new struct type, based on styp, in which the
+// types of the fields that appear in formats are replaced by
+// formattedValue.  As with cols, hidden fields and fields of type channel
+// are omitted from the new type.
+func formatStruct(styp reflect.Type, formats map[string]string) reflect.Type {
+	fields := make([]reflect.StructField, 0, styp.NumField())
+	for i := 0; i < styp.NumField(); i++ {
+		field := styp.Field(i)
+		if field.PkgPath != "" || ignoreKind(field.Type.Kind()) {
+			continue
+		}
+		if format, ok := formats[field.Name]; ok {
+			if isTimeLayout(format) && derefType(field.Type) != timeType {
+				fatalType("formatCol", "%s is not a time.Time, a printf format is required",
+					field.Name)
+			}
+			field.Type = formattedValueType
+		}
+		fields = append(fields, field)
 	}
-	return reflect.TypeOf("")
+	return reflect.StructOf(fields)
 }
 
-func toTable(data [][]string) interface{} {
-	defer func() {
-		err := recover()
-		if err != nil {
-			fatalf("promote", "Invalid use of toTable: %v", err)
+// formatRow returns a copy of the struct or map el, whose fields or keys
+// listed in formats are wrapped in formattedValues.  Maps are converted into
+// map[string]interface{}.
+func (e *env) formatRow(el reflect.Value, newStyp reflect.Type, formats map[string]string) reflect.Value {
+	if el.Kind() == reflect.Struct {
+		newEl := reflect.New(newStyp).Elem()
+		for i := 0; i < newStyp.NumField(); i++ {
+			name := newStyp.Field(i).Name
+			if format, ok := formats[name]; ok {
+				newEl.Field(i).Set(reflect.ValueOf(e.newFormattedValue(el.FieldByName(name), format)))
+			} else {
+				newEl.Field(i).Set(el.FieldByName(name))
+			}
 		}
-	}()
+		return newEl
+	}
 
-	if len(data) < 2 {
-		panic("Expected at least two rows")
+	newMap := make(map[string]interface{}, el.Len())
+	for _, k := range el.MapKeys() {
+		v := el.MapIndex(k)
+		if format, ok := formats[k.String()]; ok {
+			newMap[k.String()] = e.newFormattedValue(v, format)
+		} else {
+			newMap[k.String()] = v.Interface()
+		}
 	}
+	return reflect.ValueOf(newMap)
+}
 
-	var fields []reflect.StructField
-	for _, f := range data[0] {
-		fields = append(fields, reflect.StructField{
-			Name: sanitizeName(f),
-		})
+func (e *env) formatCol(obj interface{}, fieldFormats ...string) interface{} {
+	val := getValue(obj)
+	assertCollectionOfRows("formatCol", val)
+	if len(fieldFormats) == 0 || len(fieldFormats)%2 != 0 {
+		fatalArity("formatCol", "one or more field and format pairs expected")
 	}
 
-	for i, v := range data[1] {
-		fields[i].Type = guessType(v)
+	formats := make(map[string]string)
+	for i := 0; i < len(fieldFormats); i += 2 {
+		formats[fieldFormats[i]] = fieldFormats[i+1]
 	}
 
-	sTyp := reflect.StructOf(fields)
-	newVal := reflect.MakeSlice(reflect.SliceOf(sTyp), len(data)-1, len(data)-1)
-	for i, row := range data[1:] {
-		sVal := reflect.New(sTyp)
-		for j, v := range row {
-			f := sVal.Elem().Field(j)
-			switch f.Kind() {
-			case reflect.Int:
-				num, err := strconv.Atoi(v)
-				if err != nil {
-					panic(fmt.Sprintf("integer expected found %s at (%d, %d)", v, j, i))
-				}
-				f.Set(reflect.ValueOf(num))
-			case reflect.Float64:
-				num, err := strconv.ParseFloat(v, 64)
-				if err != nil {
-					panic(fmt.Sprintf("float expected found %s at (%d, %d)", v, j, i))
+	typ := val.Type().Elem()
+	styp := derefType(typ)
+	if styp.Kind() == reflect.Struct {
+		for field := range formats {
+			if sf, ok := styp.FieldByName(field); !ok || sf.PkgPath != "" {
+				fatalField("formatCol", field, exportedFieldNames(styp))
+			}
+		}
+	} else {
+		for field := range formats {
+			if !hasField(val, field) {
+				fatalField("formatCol", field, unionKeys(val))
+			}
+		}
+	}
+
+	var newTyp, newStyp reflect.Type
+	switch styp.Kind() {
+	case reflect.Struct:
+		newStyp = formatStruct(styp, formats)
+		newTyp = newStyp
+		if typ.Kind() == reflect.Ptr {
+			newTyp = reflect.PtrTo(newStyp)
+		}
+	case reflect.Map:
+		newTyp = reflect.TypeOf(map[string]interface{}{})
+	default:
+		newTyp = typ
+	}
+
+	types := make(map[reflect.Type]reflect.Type)
+	newVal := reflect.MakeSlice(reflect.SliceOf(newTyp), val.Len(), val.Len())
+	for i := 0; i < val.Len(); i++ {
+		el := derefValue(val.Index(i))
+		switch {
+		case el.Kind() == reflect.Struct:
+			rowTyp := newStyp
+			if rowTyp == nil {
+				var ok bool
+				if rowTyp, ok = types[el.Type()]; !ok {
+					rowTyp = formatStruct(el.Type(), formats)
+					types[el.Type()] = rowTyp
 				}
-				f.Set(reflect.ValueOf(num))
-			default:
-				f.Set(reflect.ValueOf(v))
 			}
+			row := e.formatRow(el, rowTyp, formats)
+			if newTyp.Kind() == reflect.Ptr {
+				row = row.Addr()
+			}
+			newVal.Index(i).Set(row)
+		case el.Kind() == reflect.Map && isStringMap(el.Type()):
+			newVal.Index(i).Set(e.formatRow(el, nil, formats))
 		}
-		newVal.Index(i).Set(sVal.Elem())
 	}
 
 	return newVal.Interface()
@@ -0,0 +1,186 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tfortools
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// namedFormatters contains the functions that can be passed by name to
+// formatCol in place of a printf format or a time layout.
+var namedFormatters = map[string]func(*env, interface{}) string{
+	"humanBytes":    func(_ *env, obj interface{}) string { return humanBytes(obj) },
+	"humanDuration": func(_ *env, obj interface{}) string { return humanDuration(obj) },
+	"siNumber":      func(_ *env, obj interface{}) string { return siNumber(obj) },
+	"thousands":     func(_ *env, obj interface{}) string { return thousands(obj) },
+	"ago":           (*env).ago,
+}
+
+func assertNumber(fnName string, obj interface{}) float64 {
+	if f, ok := obj.(formattedValue); ok {
+		obj = f.value
+	}
+	val := derefPtr(reflect.ValueOf(obj))
+	if !val.IsValid() || isNil(val) {
+		fatalType(fnName, "number expected, found nil")
+	}
+	n, ok := toFloat(val.Interface())
+	if !ok {
+		fatalType(fnName, "number expected, found %T", obj)
+	}
+	return n
+}
+
+// scaleNumber divides n by base until it is less than base, returning the
+// result formatted with one decimal place followed by the appropriate
+// suffix.
+func scaleNumber(n, base float64, suffixes []string) string {
+	sign := ""
+	if n < 0 {
+		sign = "-"
+		n = -n
+	}
+	i := 0
+	for ; n >= base && i < len(suffixes)-1; i++ {
+		n /= base
+	}
+	if i == 0 {
+		return sign + strconv.FormatFloat(n, 'f', -1, 64) + suffixes[0]
+	}
+	return fmt.Sprintf("%s%.1f%s", sign, n, suffixes[i])
+}
+
+func humanBytes(obj interface{}) string {
+	n := assertNumber("humanBytes", obj)
+	return scaleNumber(math.Trunc(n), 1024,
+		[]string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"})
+}
+
+func siNumber(obj interface{}) string {
+	n := assertNumber("siNumber", obj)
+	return scaleNumber(n, 1000, []string{"", "k", "M", "G", "T", "P", "E"})
+}
+
+func thousands(obj interface{}) string {
+	// Integers are formatted directly as converting them to float64
+	// would lose precision for values greater than 2^53.
+
+	val := reflect.ValueOf(obj)
+	if f, ok := obj.(formattedValue); ok {
+		val = reflect.ValueOf(f.value)
+	}
+
+	var str string
+	switch val = derefPtr(val); val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		str = strconv.FormatInt(val.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Uintptr:
+		str = strconv.FormatUint(val.Uint(), 10)
+	default:
+		str = strconv.FormatFloat(assertNumber("thousands", obj), 'f', -1, 64)
+	}
+
+	var b bytes.Buffer
+	if strings.HasPrefix(str, "-") {
+		b.WriteByte('-')
+		str = str[1:]
+	}
+	intPart, fracPart := str, ""
+	if i := strings.IndexByte(str, '.'); i != -1 {
+		intPart, fracPart = str[:i], str[i:]
+	}
+
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteString(fracPart)
+	return b.String()
+}
+
+func toDuration(fnName string, obj interface{}) time.Duration {
+	if f, ok := obj.(formattedValue); ok {
+		obj = f.value
+	}
+	val := derefPtr(reflect.ValueOf(obj))
+	if val.IsValid() && val.Type() == durationType {
+		return val.Interface().(time.Duration)
+	}
+	return time.Duration(assertNumber(fnName, obj) * float64(time.Second))
+}
+
+// formatDuration outputs d using its two most significant units, e.g.,
+// 3d4h, 2h5m or 3m10s.  Durations of less than a minute are rounded to
+// the nearest tenth of a second.
+func formatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+
+	const day = 24 * time.Hour
+	switch {
+	case d >= day:
+		return fmt.Sprintf("%s%dd%dh", sign, d/day, (d%day)/time.Hour)
+	case d >= time.Hour:
+		return fmt.Sprintf("%s%dh%dm", sign, d/time.Hour, (d%time.Hour)/time.Minute)
+	case d >= time.Minute:
+		return fmt.Sprintf("%s%dm%ds", sign, d/time.Minute, (d%time.Minute)/time.Second)
+	case d >= time.Second:
+		return sign + strconv.FormatFloat(d.Seconds(), 'f', 1, 64) + "s"
+	}
+	return sign + d.String()
+}
+
+func humanDuration(obj interface{}) string {
+	return formatDuration(toDuration("humanDuration", obj))
+}
+
+func toTime(fnName string, obj interface{}) time.Time {
+	if f, ok := obj.(formattedValue); ok {
+		obj = f.value
+	}
+	val := derefPtr(reflect.ValueOf(obj))
+	if !val.IsValid() || val.Type() != timeType {
+		fatalType(fnName, "time.Time expected, found %T", obj)
+	}
+	return val.Interface().(time.Time)
+}
+
+func (e *env) since(obj interface{}) time.Duration {
+	return e.now().Sub(toTime("since", obj))
+}
+
+func (e *env) ago(obj interface{}) string {
+	d := e.now().Sub(toTime("ago", obj))
+	if d < 0 {
+		return "in " + formatDuration(-d)
+	}
+	return formatDuration(d) + " ago"
+}
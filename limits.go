@@ -0,0 +1,311 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tfortools
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"text/template"
+	"text/template/parse"
+)
+
+// Resource identifies a resource whose use by a template can be limited.
+type Resource int
+
+const (
+	// ResourceOutput is the number of bytes written by a template.
+	ResourceOutput Resource = iota
+
+	// ResourceIterations is the total number of iterations performed by
+	// the range actions of a template.
+	ResourceIterations
+
+	// ResourceSliceLen is the length of the slices returned by the
+	// template functions and the width of the strings built by padLeft
+	// and padRight.
+	ResourceSliceLen
+
+	// ResourceDepth is the depth to which templates invoke other
+	// templates.
+	ResourceDepth
+)
+
+var resourceNames = []string{
+	"output",
+	"iteration",
+	"slice length",
+	"template depth",
+}
+
+func (r Resource) String() string {
+	if r < 0 || int(r) >= len(resourceNames) {
+		return fmt.Sprintf("Resource(%d)", int(r))
+	}
+	return resourceNames[r]
+}
+
+// LimitError is returned by OutputToTemplate and OutputToTemplateContext
+// when the execution of a template exceeds one of the limits set in its
+// Config object.
+type LimitError struct {
+	// Resource identifies the limit that was exceeded.
+	Resource Resource
+
+	// Limit is the value of the limit.
+	Limit int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s limit of %d exceeded", e.Resource, e.Limit)
+}
+
+const maxInt = int(^uint(0) >> 1)
+
+// limits holds the resource limits set in a Config object.  A limit of 0
+// means that the resource is not limited.
+type limits struct {
+	output     int64
+	iterations int64
+	sliceLen   int
+	depth      int
+}
+
+// Names of the functions added to templates to enforce limits.  They are
+// added after the template has been parsed, so they cannot be invoked by
+// the template's source.
+const (
+	limitRangeFn = "tfortoolsRange"
+	limitEnterFn = "tfortoolsEnter"
+	limitLeaveFn = "tfortoolsLeave"
+)
+
+// exceeded aborts the function fnName, or the template if fnName is
+// empty, reporting that the limit for r has been exceeded.
+func exceeded(fnName string, r Resource, limit int64) {
+	if fnName == "" {
+		fnName = r.String()
+	}
+	panic(template.ExecError{
+		Name: fnName,
+		Err:  &LimitError{Resource: r, Limit: limit},
+	})
+}
+
+// findLimitError returns the LimitError wrapped by err, if any.
+func findLimitError(err error) *LimitError {
+	for err != nil {
+		if e, ok := err.(*LimitError); ok {
+			return e
+		}
+		u, ok := err.(interface {
+			Unwrap() error
+		})
+		if !ok {
+			return nil
+		}
+		err = u.Unwrap()
+	}
+	return nil
+}
+
+// limitWriter writes at most limit bytes to w.  Writes that would exceed
+// the limit write as many bytes as are permitted and fail.
+type limitWriter struct {
+	w         io.Writer
+	limit     int64
+	remaining int64
+}
+
+func (l *limitWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) <= l.remaining {
+		n, err := l.w.Write(p)
+		l.remaining -= int64(n)
+		return n, err
+	}
+
+	n, err := l.w.Write(p[:l.remaining])
+	l.remaining -= int64(n)
+	if err != nil {
+		return n, err
+	}
+	return n, &LimitError{Resource: ResourceOutput, Limit: l.limit}
+}
+
+// checkSliceLen aborts the function fnName if n exceeds the slice length
+// limit.
+func (e *env) checkSliceLen(fnName string, n int) {
+	if e.limits.sliceLen > 0 && n > e.limits.sliceLen {
+		exceeded(fnName, ResourceSliceLen, int64(e.limits.sliceLen))
+	}
+}
+
+// checkWidth aborts the function fnName if a string of width runes would
+// exceed the output limit or the slice length limit.  It is used by
+// functions that build strings whose lengths are specified by the template.
+func (e *env) checkWidth(fnName string, width int) {
+	if e.limits.output > 0 && int64(width) > e.limits.output {
+		exceeded(fnName, ResourceOutput, e.limits.output)
+	}
+	e.checkSliceLen(fnName, width)
+}
+
+// limitSliceLen returns a function that behaves like fn, the template
+// function called fnName, but which aborts if any of the slices or arrays
+// that fn returns are longer than the slice length limit.
+func (e *env) limitSliceLen(fnName string, fn interface{}) interface{} {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		return fn
+	}
+
+	return reflect.MakeFunc(fv.Type(), func(args []reflect.Value) []reflect.Value {
+		var results []reflect.Value
+		if fv.Type().IsVariadic() {
+			results = fv.CallSlice(args)
+		} else {
+			results = fv.Call(args)
+		}
+		for _, r := range results {
+			if r.Kind() == reflect.Interface && !r.IsNil() {
+				r = r.Elem()
+			}
+			if r.Kind() == reflect.Slice || r.Kind() == reflect.Array {
+				e.checkSliceLen(fnName, r.Len())
+			}
+		}
+		return results
+	}).Interface()
+}
+
+// countIterations is appended to the pipeline of each range action.  It
+// adds the number of iterations that the range action will perform on v to
+// the total and returns v unmodified.
+func (e *env) countIterations(v interface{}) interface{} {
+	val := derefValue(reflect.ValueOf(v))
+	var n int64
+	switch val.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.String:
+		n = int64(val.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = val.Int()
+	}
+
+	e.iterations += n
+	if e.iterations > e.limits.iterations {
+		exceeded("", ResourceIterations, e.limits.iterations)
+	}
+	return v
+}
+
+func (e *env) enterTemplate() string {
+	e.depth++
+	if e.depth > e.limits.depth {
+		exceeded("", ResourceDepth, int64(e.limits.depth))
+	}
+	return ""
+}
+
+func (e *env) leaveTemplate() string {
+	e.depth--
+	return ""
+}
+
+// newCallAction returns an action that calls the function fn, assigning its
+// result to a variable rather than outputting it.
+func newCallAction(fn string) *parse.ActionNode {
+	return &parse.ActionNode{
+		NodeType: parse.NodeAction,
+		Pipe: &parse.PipeNode{
+			NodeType: parse.NodePipe,
+			Decl: []*parse.VariableNode{{
+				NodeType: parse.NodeVariable,
+				Ident:    []string{"$" + fn},
+			}},
+			Cmds: []*parse.CommandNode{newCallCommand(fn)},
+		},
+	}
+}
+
+func newCallCommand(fn string) *parse.CommandNode {
+	return &parse.CommandNode{
+		NodeType: parse.NodeCommand,
+		Args:     []parse.Node{parse.NewIdentifier(fn)},
+	}
+}
+
+// limitRanges appends a call to countIterations to the pipelines of the
+// range actions found in n.
+func limitRanges(n parse.Node) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, node := range n.Nodes {
+			limitRanges(node)
+		}
+	case *parse.IfNode:
+		limitRanges(n.List)
+		limitRanges(n.ElseList)
+	case *parse.WithNode:
+		limitRanges(n.List)
+		limitRanges(n.ElseList)
+	case *parse.RangeNode:
+		n.Pipe.Cmds = append(n.Pipe.Cmds, newCallCommand(limitRangeFn))
+		limitRanges(n.List)
+		limitRanges(n.ElseList)
+	}
+}
+
+// applyLimits instruments the parsed template t so that the iteration and
+// depth limits of the env are enforced when it is executed.  It returns a
+// writer that enforces the output limit on w.
+func (e *env) applyLimits(t *template.Template, w io.Writer) io.Writer {
+	fm := template.FuncMap{}
+	if e.limits.iterations > 0 {
+		fm[limitRangeFn] = e.countIterations
+	}
+	if e.limits.depth > 0 {
+		fm[limitEnterFn] = e.enterTemplate
+		fm[limitLeaveFn] = e.leaveTemplate
+
+		// The template being executed is entered at depth 0.
+		e.depth = -1
+	}
+	t.Funcs(fm)
+
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree == nil || tmpl.Tree.Root == nil {
+			continue
+		}
+		root := tmpl.Tree.Root
+		if e.limits.iterations > 0 {
+			limitRanges(root)
+		}
+		if e.limits.depth > 0 {
+			nodes := []parse.Node{newCallAction(limitEnterFn)}
+			nodes = append(nodes, root.Nodes...)
+			root.Nodes = append(nodes, newCallAction(limitLeaveFn))
+		}
+	}
+
+	if e.limits.output > 0 {
+		w = &limitWriter{w: w, limit: e.limits.output, remaining: e.limits.output}
+	}
+	return w
+}
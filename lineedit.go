@@ -0,0 +1,244 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tfortools
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// lineEditor reads lines from a terminal in raw mode.  It echoes the
+// characters it reads and supports a small set of emacs style editing
+// keys, the arrow keys, history navigation and tab completion.
+type lineEditor struct {
+	r        *bufio.Reader
+	w        io.Writer
+	prompt   string
+	history  func() []string
+	complete func(text string) []string
+
+	buf   []rune
+	pos   int
+	hist  []string
+	idx   int
+	saved []rune
+}
+
+func ctrl(r rune) rune {
+	return r & 0x1f
+}
+
+// refresh redraws the prompt and the line being edited and positions the
+// cursor.
+func (e *lineEditor) refresh() {
+	fmt.Fprintf(e.w, "\r%s%s\x1b[K", e.prompt, string(e.buf))
+	if n := len(e.buf) - e.pos; n > 0 {
+		fmt.Fprintf(e.w, "\x1b[%dD", n)
+	}
+}
+
+func (e *lineEditor) insert(s []rune) {
+	buf := make([]rune, 0, len(e.buf)+len(s))
+	buf = append(buf, e.buf[:e.pos]...)
+	buf = append(buf, s...)
+	e.buf = append(buf, e.buf[e.pos:]...)
+	e.pos += len(s)
+}
+
+func (e *lineEditor) deleteRange(from, to int) {
+	e.buf = append(e.buf[:from], e.buf[to:]...)
+	e.pos = from
+}
+
+// move replaces the line being edited with the history entry d entries
+// away from the current one.  Moving past the most recent entry restores
+// the line that was being edited before the history was browsed.
+func (e *lineEditor) move(d int) {
+	n := e.idx + d
+	if n < 0 || n > len(e.hist) {
+		return
+	}
+	if e.idx == len(e.hist) {
+		e.saved = append([]rune(nil), e.buf...)
+	}
+	e.idx = n
+	if n == len(e.hist) {
+		e.buf = append([]rune(nil), e.saved...)
+	} else {
+		e.buf = []rune(e.hist[n])
+	}
+	e.pos = len(e.buf)
+}
+
+// commonPrefix returns the longest prefix shared by all of the strings in
+// a, which must not be empty.
+func commonPrefix(a []string) []rune {
+	prefix := []rune(a[0])
+	for _, s := range a[1:] {
+		r := []rune(s)
+		i := 0
+		for i < len(prefix) && i < len(r) && prefix[i] == r[i] {
+			i++
+		}
+		prefix = prefix[:i]
+	}
+	return prefix
+}
+
+// tab completes the word before the cursor.  If there are several
+// completions, the word is extended to their common prefix.  If it cannot
+// be extended, the completions are listed below the line.
+func (e *lineEditor) tab() {
+	text := string(e.buf[:e.pos])
+	completions := e.complete(text)
+	if len(completions) == 0 {
+		return
+	}
+
+	word := []rune(lastWord(text))
+	prefix := commonPrefix(completions)
+	if len(prefix) > len(word) {
+		e.deleteRange(e.pos-len(word), e.pos)
+		e.insert(prefix)
+		return
+	}
+	if len(completions) > 1 {
+		fmt.Fprintf(e.w, "\n%s\n", strings.Join(completions, "  "))
+	}
+}
+
+// escape handles the escape sequences sent by the arrow, home, end and
+// delete keys.  Unrecognised sequences are ignored.
+func (e *lineEditor) escape() error {
+	r, _, err := e.r.ReadRune()
+	if err != nil {
+		return err
+	}
+	if r != '[' && r != 'O' {
+		return nil
+	}
+
+	var seq []rune
+	for {
+		r, _, err = e.r.ReadRune()
+		if err != nil {
+			return err
+		}
+		seq = append(seq, r)
+		if r >= 0x40 && r <= 0x7e {
+			break
+		}
+	}
+
+	switch string(seq) {
+	case "A":
+		e.move(-1)
+	case "B":
+		e.move(1)
+	case "C":
+		if e.pos < len(e.buf) {
+			e.pos++
+		}
+	case "D":
+		if e.pos > 0 {
+			e.pos--
+		}
+	case "H", "1~", "7~":
+		e.pos = 0
+	case "F", "4~", "8~":
+		e.pos = len(e.buf)
+	case "3~":
+		if e.pos < len(e.buf) {
+			e.deleteRange(e.pos, e.pos+1)
+		}
+	}
+	return nil
+}
+
+// readLine displays the prompt and reads a single line.  io.EOF is
+// returned if the input ends, or if Ctrl-D is pressed, on an empty line.
+func (e *lineEditor) readLine() (string, error) {
+	e.buf, e.pos = nil, 0
+	e.hist = e.history()
+	e.idx = len(e.hist)
+	e.refresh()
+
+	for {
+		r, _, err := e.r.ReadRune()
+		if err == io.EOF && len(e.buf) > 0 {
+			fmt.Fprintln(e.w)
+			return string(e.buf), nil
+		} else if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprintln(e.w)
+			return string(e.buf), nil
+		case ctrl('A'):
+			e.pos = 0
+		case ctrl('E'):
+			e.pos = len(e.buf)
+		case ctrl('B'):
+			if e.pos > 0 {
+				e.pos--
+			}
+		case ctrl('F'):
+			if e.pos < len(e.buf) {
+				e.pos++
+			}
+		case ctrl('C'):
+			fmt.Fprintln(e.w, "^C")
+			e.buf, e.pos = nil, 0
+			e.idx = len(e.hist)
+		case ctrl('D'):
+			if len(e.buf) == 0 {
+				return "", io.EOF
+			}
+			if e.pos < len(e.buf) {
+				e.deleteRange(e.pos, e.pos+1)
+			}
+		case ctrl('H'), 0x7f:
+			if e.pos > 0 {
+				e.deleteRange(e.pos-1, e.pos)
+			}
+		case ctrl('K'):
+			e.buf = e.buf[:e.pos]
+		case ctrl('U'):
+			e.deleteRange(0, e.pos)
+		case ctrl('P'):
+			e.move(-1)
+		case ctrl('N'):
+			e.move(1)
+		case '\t':
+			e.tab()
+		case 0x1b:
+			if err := e.escape(); err != nil {
+				return "", err
+			}
+		default:
+			if unicode.IsPrint(r) {
+				e.insert([]rune{r})
+			}
+		}
+		e.refresh()
+	}
+}
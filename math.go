@@ -0,0 +1,248 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tfortools
+
+import (
+	"math"
+	"reflect"
+)
+
+// number holds the value of an operand passed to one of the arithmetic
+// functions.  kind is reflect.Int, reflect.Uint or reflect.Float64 and
+// identifies which of the other fields holds the value.
+type number struct {
+	kind reflect.Kind
+	i    int64
+	u    uint64
+	f    float64
+}
+
+func toNumber(fnName string, obj interface{}) number {
+	if f, ok := obj.(formattedValue); ok {
+		obj = f.value
+	}
+	val := derefPtr(reflect.ValueOf(obj))
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return number{kind: reflect.Int, i: val.Int()}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return number{kind: reflect.Uint, u: val.Uint()}
+	case reflect.Float32, reflect.Float64:
+		return number{kind: reflect.Float64, f: val.Float()}
+	}
+	fatalType(fnName, "number expected, found %T", obj)
+	return number{}
+}
+
+func (n number) float() float64 {
+	switch n.kind {
+	case reflect.Int:
+		return float64(n.i)
+	case reflect.Uint:
+		return float64(n.u)
+	}
+	return n.f
+}
+
+func (n number) value() interface{} {
+	switch n.kind {
+	case reflect.Int:
+		return int(n.i)
+	case reflect.Uint:
+		return uint(n.u)
+	}
+	return n.f
+}
+
+// unifyNumbers converts a and b to a common kind using the following rules.
+// If either number is a float, both are converted to float64.  Otherwise, if
+// both numbers are unsigned they are left unchanged.  Otherwise both are
+// converted to signed integers.  An error is raised if an unsigned integer
+// is too large to be represented as a signed integer.
+func unifyNumbers(fnName string, a, b number) (number, number) {
+	switch {
+	case a.kind == b.kind:
+		return a, b
+	case a.kind == reflect.Float64 || b.kind == reflect.Float64:
+		return number{kind: reflect.Float64, f: a.float()},
+			number{kind: reflect.Float64, f: b.float()}
+	}
+
+	toInt := func(n number) number {
+		if n.kind == reflect.Uint {
+			if n.u > math.MaxInt64 {
+				fatalf(fnName, "%d is too large to be converted to a signed integer", n.u)
+			}
+			return number{kind: reflect.Int, i: int64(n.u)}
+		}
+		return n
+	}
+	return toInt(a), toInt(b)
+}
+
+func overflow(fnName string) {
+	fatalf(fnName, "integer overflow")
+}
+
+func add(x, y interface{}) interface{} {
+	a, b := unifyNumbers("add", toNumber("add", x), toNumber("add", y))
+	switch a.kind {
+	case reflect.Int:
+		r := a.i + b.i
+		if (r > a.i) != (b.i > 0) {
+			overflow("add")
+		}
+		return int(r)
+	case reflect.Uint:
+		r := a.u + b.u
+		if r < a.u {
+			overflow("add")
+		}
+		return uint(r)
+	}
+	return a.f + b.f
+}
+
+func sub(x, y interface{}) interface{} {
+	a, b := unifyNumbers("sub", toNumber("sub", x), toNumber("sub", y))
+	switch a.kind {
+	case reflect.Int:
+		r := a.i - b.i
+		if (r < a.i) != (b.i > 0) {
+			overflow("sub")
+		}
+		return int(r)
+	case reflect.Uint:
+		if b.u > a.u {
+			fatalf("sub", "unsigned integer underflow")
+		}
+		return uint(a.u - b.u)
+	}
+	return a.f - b.f
+}
+
+func mul(x, y interface{}) interface{} {
+	a, b := unifyNumbers("mul", toNumber("mul", x), toNumber("mul", y))
+	switch a.kind {
+	case reflect.Int:
+		r := a.i * b.i
+		if a.i != 0 && (r/a.i != b.i || (a.i == -1 && b.i == math.MinInt64)) {
+			overflow("mul")
+		}
+		return int(r)
+	case reflect.Uint:
+		r := a.u * b.u
+		if a.u != 0 && r/a.u != b.u {
+			overflow("mul")
+		}
+		return uint(r)
+	}
+	return a.f * b.f
+}
+
+func isZero(n number) bool {
+	return n.i == 0 && n.u == 0 && n.f == 0
+}
+
+func div(x, y interface{}) interface{} {
+	a, b := unifyNumbers("div", toNumber("div", x), toNumber("div", y))
+	if isZero(b) {
+		fatalf("div", "divide by zero")
+	}
+	switch a.kind {
+	case reflect.Int:
+		if a.i == math.MinInt64 && b.i == -1 {
+			overflow("div")
+		}
+		return int(a.i / b.i)
+	case reflect.Uint:
+		return uint(a.u / b.u)
+	}
+	return a.f / b.f
+}
+
+func mod(x, y interface{}) interface{} {
+	a, b := unifyNumbers("mod", toNumber("mod", x), toNumber("mod", y))
+	if isZero(b) {
+		fatalf("mod", "divide by zero")
+	}
+	switch a.kind {
+	case reflect.Int:
+		if b.i == -1 {
+			return 0
+		}
+		return int(a.i % b.i)
+	case reflect.Uint:
+		return uint(a.u % b.u)
+	}
+	return math.Mod(a.f, b.f)
+}
+
+// roundHalfAway rounds f to the nearest integer, rounding half way cases away
+// from zero.  It is equivalent to math.Round which is not available in the
+// versions of Go that we support.
+func roundHalfAway(f float64) float64 {
+	if f < 0 {
+		return -math.Floor(-f + 0.5)
+	}
+	return math.Floor(f + 0.5)
+}
+
+func round(x interface{}, places ...int) float64 {
+	if len(places) > 1 {
+		fatalArity("round", "accepts a maximum of two arguments")
+	}
+	f := toNumber("round", x).float()
+	if len(places) == 0 || places[0] == 0 {
+		return roundHalfAway(f)
+	}
+	scale := math.Pow(10, float64(places[0]))
+	return roundHalfAway(f*scale) / scale
+}
+
+func floor(x interface{}) float64 {
+	return math.Floor(toNumber("floor", x).float())
+}
+
+func ceil(x interface{}) float64 {
+	return math.Ceil(toNumber("ceil", x).float())
+}
+
+func abs(x interface{}) interface{} {
+	n := toNumber("abs", x)
+	switch n.kind {
+	case reflect.Int:
+		if n.i == math.MinInt64 {
+			overflow("abs")
+		}
+		if n.i < 0 {
+			n.i = -n.i
+		}
+	case reflect.Float64:
+		n.f = math.Abs(n.f)
+	}
+	return n.value()
+}
+
+func percent(x, y interface{}) float64 {
+	part := toNumber("percent", x).float()
+	total := toNumber("percent", y).float()
+	if total == 0 {
+		fatalf("percent", "divide by zero")
+	}
+	return part / total * 100
+}
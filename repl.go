@@ -0,0 +1,506 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tfortools
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const replPrompt = "> "
+
+// builtinNames contains the names of the functions and actions provided by
+// text/template itself.  They are offered as completions alongside the
+// functions enabled in the Config object.
+var builtinNames = []string{
+	"and", "block", "call", "define", "else", "end", "eq", "ge", "gt",
+	"html", "if", "index", "js", "le", "len", "lt", "ne", "not", "or",
+	"print", "printf", "println", "range", "template", "urlquery", "with",
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// lastWord returns the portion of text following the last space,
+// parenthesis or opening brace.
+func lastWord(text string) string {
+	i := strings.LastIndexAny(text, " \t()|{")
+	return text[i+1:]
+}
+
+// indirectValue dereferences pointers and interfaces.  If v is not valid
+// or is nil, the type t is dereferenced instead so that completion can
+// continue on the static type of the data.
+func indirectValue(v reflect.Value, t reflect.Type) (reflect.Value, reflect.Type) {
+	for {
+		if v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+			if v.IsNil() {
+				t = v.Type()
+				v = reflect.Value{}
+				continue
+			}
+			v = v.Elem()
+			t = v.Type()
+			continue
+		}
+		if !v.IsValid() && t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+			continue
+		}
+		return v, t
+	}
+}
+
+// templateMethod returns the method of t called name if it can be invoked
+// from inside a template.
+func templateMethod(t reflect.Type, name string) (reflect.Method, bool) {
+	m, ok := t.MethodByName(name)
+	if !ok || m.PkgPath != "" || m.Type.NumIn() != 1 {
+		return m, false
+	}
+	switch m.Type.NumOut() {
+	case 1:
+		return m, true
+	case 2:
+		return m, m.Type.Out(1) == errorType
+	}
+	return m, false
+}
+
+// fieldStep resolves the field, method or map key called name of the value
+// v, whose type is t.  Methods are not invoked, so the value returned for
+// a method is always invalid.
+func fieldStep(v reflect.Value, t reflect.Type, name string) (reflect.Value, reflect.Type, bool) {
+	if t != nil {
+		if m, ok := templateMethod(t, name); ok {
+			return reflect.Value{}, m.Type.Out(0), true
+		}
+	}
+
+	v, t = indirectValue(v, t)
+	if t == nil {
+		return v, t, false
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if m, ok := templateMethod(reflect.PtrTo(t), name); ok {
+			return reflect.Value{}, m.Type.Out(0), true
+		}
+		f, ok := t.FieldByName(name)
+		if !ok || f.PkgPath != "" {
+			return v, t, false
+		}
+		if v.IsValid() {
+			v = v.FieldByIndex(f.Index)
+		}
+		return v, f.Type, true
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return v, t, false
+		}
+		et := t.Elem()
+		if !v.IsValid() {
+			return v, et, true
+		}
+		return v.MapIndex(reflect.ValueOf(name).Convert(t.Key())), et, true
+	}
+	return v, t, false
+}
+
+// pathNames returns the names of the fields, methods and map keys that
+// can follow a value v, of type t, in a field path.
+func pathNames(v reflect.Value, t reflect.Type) []string {
+	var names []string
+
+	seen := make(map[string]bool)
+	addMethods := func(t reflect.Type) {
+		for i := 0; i < t.NumMethod(); i++ {
+			name := t.Method(i).Name
+			if _, ok := templateMethod(t, name); ok && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	if t != nil && t.Kind() != reflect.Interface {
+		addMethods(t)
+	}
+
+	v, t = indirectValue(v, t)
+	if t == nil {
+		return names
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		addMethods(reflect.PtrTo(t))
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.PkgPath == "" {
+				names = append(names, f.Name)
+			}
+		}
+	case reflect.Map:
+		if v.IsValid() && t.Key().Kind() == reflect.String {
+			for _, k := range v.MapKeys() {
+				names = append(names, k.String())
+			}
+		}
+	}
+	return names
+}
+
+// fieldCompletions returns the field paths that complete path, which must
+// start with a '.', when evaluated against obj.
+func fieldCompletions(path string, obj interface{}) []string {
+	v := reflect.ValueOf(obj)
+	var t reflect.Type
+	if v.IsValid() {
+		t = v.Type()
+	}
+
+	parts := strings.Split(path[1:], ".")
+	prefix := "."
+	for _, p := range parts[:len(parts)-1] {
+		var ok bool
+		v, t, ok = fieldStep(v, t, p)
+		if !ok {
+			return nil
+		}
+		prefix += p + "."
+	}
+
+	partial := parts[len(parts)-1]
+	var completions []string
+	for _, n := range pathNames(v, t) {
+		if strings.HasPrefix(n, partial) {
+			completions = append(completions, prefix+n)
+		}
+	}
+	return completions
+}
+
+// Complete returns the possible completions for the last word of text, a
+// fragment of template source code.  If the last word starts with a '.' or a
+// '$.', it is completed as a field path, using the fields, methods and map
+// keys of obj.  Otherwise, it is completed as a function name, using the
+// names of the template functions enabled in cfg and the functions and
+// actions built into Go's template language.  If cfg is nil, all the
+// functions provided by tfortools are considered.  Each completion replaces
+// the last word of text.  The completions are sorted and contain no
+// duplicates.
+//
+// Field paths are always resolved relative to obj, even if the last word
+// appears inside a range or with action that changes the value of dot.
+func Complete(text string, obj interface{}, cfg *Config) []string {
+	word := lastWord(text)
+
+	var completions []string
+	switch {
+	case strings.HasPrefix(word, "$."):
+		for _, c := range fieldCompletions(word[1:], obj) {
+			completions = append(completions, "$"+c)
+		}
+	case strings.HasPrefix(word, "."):
+		completions = fieldCompletions(word, obj)
+	default:
+		names := append(TemplateFunctionNames(cfg), builtinNames...)
+		for _, n := range names {
+			if strings.HasPrefix(n, word) {
+				completions = append(completions, n)
+			}
+		}
+	}
+
+	sort.Strings(completions)
+	j := 0
+	for i := range completions {
+		if i == 0 || completions[i] != completions[j-1] {
+			completions[j] = completions[i]
+			j++
+		}
+	}
+	return completions[:j]
+}
+
+// OptREPLLineEditing indicates that the input passed to REPL is a terminal
+// in raw mode, i.e., a terminal on which echo and canonical input
+// processing have been disabled.  REPL then echoes the input itself and
+// provides line editing, tab completion of function names and field paths,
+// and access to the history using the up and down arrow keys.  Output
+// processing should remain enabled so that newlines written by REPL are
+// translated by the terminal.
+func OptREPLLineEditing(c *Config) {
+	c.lineEditing = true
+}
+
+// OptREPLHistory returns an option that causes REPL to load its history
+// from the file at path when it starts, and to append each line that it
+// executes to that file, so that the history persists between sessions.
+func OptREPLHistory(path string) func(*Config) {
+	return func(c *Config) {
+		c.historyFile = path
+	}
+}
+
+type repl struct {
+	w           io.Writer
+	obj         interface{}
+	cfg         *Config
+	history     []string
+	historyFile string
+}
+
+// loadHistory reads the history saved by previous sessions.  It is not an
+// error for the history file not to exist.
+func (r *repl) loadHistory() error {
+	if r.historyFile == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(r.historyFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to read history: %v", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			r.history = append(r.history, line)
+		}
+	}
+	return nil
+}
+
+// addHistory appends line to the history and, if the history is
+// persistent, to the history file.
+func (r *repl) addHistory(line string) {
+	r.history = append(r.history, line)
+	if r.historyFile == "" {
+		return
+	}
+
+	f, err := os.OpenFile(r.historyFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err == nil {
+		_, err = fmt.Fprintln(f, line)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(r.w, "error: unable to save history: %v\n", err)
+	}
+}
+
+func (r *repl) help(name string) {
+	if name == "" {
+		fmt.Fprint(r.w, TemplateFunctionHelp(r.cfg))
+		return
+	}
+
+	help, err := TemplateFunctionHelpSingle(name, r.cfg)
+	if err != nil {
+		fmt.Fprintf(r.w, "error: %v\n", err)
+		return
+	}
+	fmt.Fprint(r.w, help)
+}
+
+func (r *repl) complete(text string) {
+	for _, c := range Complete(text, r.obj, r.cfg) {
+		fmt.Fprintln(r.w, c)
+	}
+}
+
+func (r *repl) showHistory() {
+	for i, h := range r.history {
+		fmt.Fprintf(r.w, "%4d  %s\n", i+1, h)
+	}
+}
+
+// recall returns the history entry referred to by line, which is either
+// "!!", denoting the last entry, or "!n", denoting the nth entry.
+func (r *repl) recall(line string) (string, error) {
+	n := len(r.history)
+	if line != "!!" {
+		var err error
+		n, err = strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("invalid history reference %s", line)
+		}
+	}
+	if n < 1 || n > len(r.history) {
+		return "", fmt.Errorf("no history entry %s", line)
+	}
+	return r.history[n-1], nil
+}
+
+func (r *repl) execute(src string) {
+	if !strings.Contains(src, "{{") {
+		src = "{{" + src + "}}"
+	}
+
+	var buf bytes.Buffer
+	if err := OutputToTemplate(&buf, "repl", src, r.obj, r.cfg); err != nil {
+		fmt.Fprintf(r.w, "error: %v\n", err)
+		return
+	}
+	if buf.Len() > 0 && buf.Bytes()[buf.Len()-1] != '\n' {
+		_ = buf.WriteByte('\n')
+	}
+	_, _ = r.w.Write(buf.Bytes())
+}
+
+// command processes a single line of input.  It returns false if the
+// REPL should exit.
+func (r *repl) command(line string) bool {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "!") {
+		var err error
+		if line, err = r.recall(line); err != nil {
+			fmt.Fprintf(r.w, "error: %v\n", err)
+			return true
+		}
+		fmt.Fprintln(r.w, line)
+	}
+
+	cmd, arg := line, ""
+	if i := strings.IndexAny(line, " \t"); i != -1 {
+		cmd, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+
+	switch cmd {
+	case "":
+		return true
+	case ":quit", ":q":
+		return false
+	case ":help", ":h":
+		r.help(arg)
+	case ":complete", ":c":
+		r.complete(arg)
+	case ":describe", ":d":
+		var buf bytes.Buffer
+		generateIndentedUsage(&buf, r.obj, configSandbox(r.cfg))
+		fmt.Fprint(r.w, buf.String())
+	case ":history":
+		r.showHistory()
+	default:
+		if strings.HasPrefix(cmd, ":") {
+			fmt.Fprintf(r.w, "error: unknown command %s\n", cmd)
+			return true
+		}
+		r.addHistory(line)
+		r.execute(line)
+	}
+	return true
+}
+
+// REPL implements an interactive read-eval-print loop that can be used to
+// develop template scripts.  Lines of template source code are read from
+// in, executed on obj, and their output is written to out.  Lines that do
+// not contain an action are treated as a pipeline, so
+//
+//	len .
+//
+// is equivalent to
+//
+//	{{len .}}
+//
+// The functions enabled in the cfg parameter are made available to the
+// template source code.  If cfg is nil, all the additional functions
+// provided by tfortools are enabled.  Errors are written to out and do
+// not terminate the loop.
+//
+// In addition to template source, REPL accepts the following commands
+//
+//	:help [function]   show help for all functions or for a single function
+//	:describe          show the type of obj
+//	:complete text     list the completions for the last word of text
+//	:history           list the previously executed lines
+//	!n                 execute line n from the history
+//	!!                 execute the last line in the history
+//	:quit              exit the REPL
+//
+// By default, REPL reads whole lines from in.  If the OptREPLLineEditing
+// option is set in cfg, in is expected to be a terminal in raw mode, and
+// REPL provides line editing, tab completion and history navigation.  The
+// arrow, Home, End, Delete and Backspace keys are supported, as are the
+// emacs keys Ctrl-A, Ctrl-E, Ctrl-B, Ctrl-F, Ctrl-K, Ctrl-U, Ctrl-P and
+// Ctrl-N.  Ctrl-C discards the line being edited.  The tab key completes function names and field paths, as described in
+// Complete, and lists the completions if there are several.  The history
+// is kept in memory and discarded when REPL returns unless a history file
+// is specified with the OptREPLHistory option.
+//
+// REPL returns when in reaches EOF, when Ctrl-D is pressed on an empty
+// line, or when the :quit command is entered.
+func REPL(in io.Reader, out io.Writer, obj interface{}, cfg *Config) error {
+	r := &repl{w: out, obj: obj, cfg: cfg}
+	if cfg != nil {
+		r.historyFile = cfg.historyFile
+	}
+	if err := r.loadHistory(); err != nil {
+		return err
+	}
+
+	var readLine func() (string, error)
+	if cfg != nil && cfg.lineEditing {
+		e := &lineEditor{
+			r:       bufio.NewReader(in),
+			w:       out,
+			prompt:  replPrompt,
+			history: func() []string { return r.history },
+			complete: func(text string) []string {
+				return Complete(text, obj, cfg)
+			},
+		}
+		readLine = e.readLine
+	} else {
+		scanner := bufio.NewScanner(in)
+		readLine = func() (string, error) {
+			fmt.Fprint(out, replPrompt)
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return "", err
+				}
+				return "", io.EOF
+			}
+			return scanner.Text(), nil
+		}
+	}
+
+	for {
+		line, err := readLine()
+		if err != nil {
+			fmt.Fprintln(out)
+			if err == io.EOF {
+				err = nil
+			}
+			return err
+		}
+		if !r.command(line) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,196 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tfortools
+
+import (
+	"reflect"
+	"strconv"
+)
+
+func assertSlice(fnName string, obj interface{}) reflect.Value {
+	val := getValue(obj)
+	if kind := val.Kind(); kind != reflect.Slice && kind != reflect.Array {
+		fatalType(fnName, "slice or an array expected")
+	}
+	return val
+}
+
+func reverse(obj interface{}) interface{} {
+	val := assertSlice("reverse", obj)
+	n := val.Len()
+	return copyElements(val.Type().Elem(), n, func(i int) reflect.Value {
+		return val.Index(n - 1 - i)
+	}).Interface()
+}
+
+func skip(obj interface{}, count ...int) interface{} {
+	val, rows := assertSliceAndRetrieveCount("skip", obj, count...)
+	if rows < 0 {
+		fatalf("skip", "number of elements to skip must not be negative")
+	}
+	if rows > val.Len() {
+		rows = val.Len()
+	}
+	return copyRange(val, rows, val.Len()).Interface()
+}
+
+// sliceIndex converts the index i, which may be negative, into an offset
+// from the start of a sequence of length n.  Indices that fall outside the
+// sequence are clamped to 0 or n.
+func sliceIndex(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > n {
+		return n
+	}
+	return i
+}
+
+// sliceBounds converts the indices passed to subslice into a pair of offsets
+// from the start of a sequence of length n.
+func sliceBounds(bounds []int, n int) (int, int) {
+	if len(bounds) == 0 || len(bounds) > 2 {
+		fatalArity("subslice", "one or two indices expected")
+	}
+
+	lo, hi := sliceIndex(bounds[0], n), n
+	if len(bounds) == 2 {
+		hi = sliceIndex(bounds[1], n)
+	}
+	if hi < lo {
+		hi = lo
+	}
+	return lo, hi
+}
+
+func subslice(obj interface{}, bounds ...int) interface{} {
+	if val := getValue(obj); val.Kind() == reflect.String {
+		lo, hi := sliceBounds(bounds, val.Len())
+		return val.String()[lo:hi]
+	}
+
+	val := assertSlice("subslice", obj)
+	lo, hi := sliceBounds(bounds, val.Len())
+	return copyRange(val, lo, hi).Interface()
+}
+
+func (e *env) chunk(obj interface{}, size int) interface{} {
+	val := assertSlice("chunk", obj)
+	if size <= 0 {
+		fatalf("chunk", "chunk size must be greater than 0")
+	}
+
+	n := val.Len() / size
+	if val.Len()%size != 0 {
+		n++
+	}
+	e.checkSliceLen("chunk", n)
+
+	sliceType := reflect.SliceOf(val.Type().Elem())
+	chunks := reflect.MakeSlice(reflect.SliceOf(sliceType), 0, n)
+	for lo := 0; lo < val.Len(); lo += size {
+		hi := lo + size
+		if hi > val.Len() || hi < lo {
+			hi = val.Len()
+		}
+		chunks = reflect.Append(chunks, copyRange(val, lo, hi))
+	}
+	return chunks.Interface()
+}
+
+func (e *env) flattenSlices(obj interface{}) interface{} {
+	val := assertSlice("flattenSlices", obj)
+	elemType := val.Type().Elem()
+
+	var flatType reflect.Type
+	switch elemType.Kind() {
+	case reflect.Slice, reflect.Array:
+		flatType = reflect.SliceOf(elemType.Elem())
+	case reflect.Interface:
+		flatType = reflect.TypeOf([]interface{}{})
+	default:
+		fatalType("flattenSlices", "slice of slices expected")
+	}
+
+	// The inner slices are validated, and the length of the result
+	// computed, before the result is allocated.
+
+	inner := make([]reflect.Value, 0, val.Len())
+	n := 0
+	for i := 0; i < val.Len(); i++ {
+		in := val.Index(i)
+		if elemType.Kind() == reflect.Interface {
+			in = derefValue(in)
+			if !in.IsValid() {
+				continue
+			}
+			if kind := in.Kind(); kind != reflect.Slice && kind != reflect.Array {
+				fatalType("flattenSlices", "element %d is not a slice", i)
+			}
+		}
+		if in.Len() > maxInt-n {
+			fatalf("flattenSlices", "result is too long")
+		}
+		n += in.Len()
+		inner = append(inner, in)
+	}
+	e.checkSliceLen("flattenSlices", n)
+
+	flat := reflect.MakeSlice(flatType, 0, n)
+	for _, in := range inner {
+		for j := 0; j < in.Len(); j++ {
+			flat = reflect.Append(flat, in.Index(j))
+		}
+	}
+	return flat.Interface()
+}
+
+func (e *env) zip(objs ...interface{}) interface{} {
+	if len(objs) < 2 {
+		fatalArity("zip", "at least two slices expected")
+	}
+
+	vals := make([]reflect.Value, len(objs))
+	fields := make([]reflect.StructField, len(objs))
+	length := -1
+	for i, obj := range objs {
+		vals[i] = assertSlice("zip", obj)
+		fields[i] = reflect.StructField{
+			Name: "Item" + strconv.Itoa(i+1),
+			Type: vals[i].Type().Elem(),
+		}
+		if length == -1 || vals[i].Len() < length {
+			length = vals[i].Len()
+		}
+	}
+
+	e.checkSliceLen("zip", length)
+
+	typ := reflect.StructOf(fields)
+	zipped := reflect.MakeSlice(reflect.SliceOf(typ), length, length)
+	for i := 0; i < length; i++ {
+		el := zipped.Index(i)
+		for j, val := range vals {
+			el.Field(j).Set(val.Index(i))
+		}
+	}
+	return zipped.Interface()
+}
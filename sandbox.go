@@ -0,0 +1,297 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tfortools
+
+import (
+	"reflect"
+	"strconv"
+	"text/template"
+	"text/template/parse"
+)
+
+// sandbox records the methods of the data object that templates are
+// permitted to call.  A method is permitted if it appears in the allow
+// list for its receiver type or if getters is true and the method takes no
+// arguments and returns a value other than an error.
+type sandbox struct {
+	allowed map[reflect.Type]map[string]bool
+	getters bool
+}
+
+// OptSandbox enables sandbox mode.  By default, text/template allows a
+// template to call any exported method of the data object, or of any value
+// reachable from it, including methods that have side effects.  In sandbox
+// mode a template may only call methods that have been explicitly permitted
+// by the OptAllowMethods and OptAllowGetters options.  Templates that
+// call methods that are not permitted are rejected by OutputToTemplate,
+// OutputToTemplateContext and Check before they are executed.  The usage
+// information generated by GenerateUsageDecorated and the describe
+// function lists only the permitted methods.
+//
+// Calls are checked using the static types of the values on which they are
+// made when possible.  Fields and methods of values whose types are only
+// known when the template is executed, e.g., the elements of a
+// []interface{} or the results of functions that return interface{}
+// values, are checked as the template executes.  Templates created with
+// CreateTemplate are always checked as they execute, as the type of the
+// data object is not known.  The String and Error methods of the values
+// output by a template are called by the fmt package and are not
+// restricted.
+func OptSandbox(c *Config) {
+	if c.sandbox == nil {
+		c.sandbox = &sandbox{
+			allowed: make(map[reflect.Type]map[string]bool),
+		}
+	}
+}
+
+// OptAllowMethods returns an option that enables sandbox mode and permits
+// templates to call the methods called names of the type of sample.  sample
+// can be a value of the type or its reflect.Type.  The methods of a type
+// and of a pointer to that type are treated identically, e.g.,
+//
+//	cfg := tfortools.NewConfig(tfortools.OptAllFns,
+//		tfortools.OptAllowMethods(user{}, "FullName", "Age"))
+func OptAllowMethods(sample interface{}, names ...string) func(*Config) {
+	return func(c *Config) {
+		OptSandbox(c)
+		t, ok := sample.(reflect.Type)
+		if !ok {
+			t = reflect.TypeOf(sample)
+		}
+		t = derefType(t)
+		if c.sandbox.allowed[t] == nil {
+			c.sandbox.allowed[t] = make(map[string]bool)
+		}
+		for _, name := range names {
+			c.sandbox.allowed[t][name] = true
+		}
+	}
+}
+
+// OptAllowGetters enables sandbox mode and permits templates to call any
+// method that takes no arguments and returns either a single value or a
+// value and an error.  Methods whose only result is an error, such as Close,
+// are assumed to be called for their side effects and are not treated as
+// getters.  Methods permitted by OptAllowMethods can still be called.
+func OptAllowGetters(c *Config) {
+	OptSandbox(c)
+	c.sandbox.getters = true
+}
+
+func configSandbox(cfg *Config) *sandbox {
+	if cfg == nil {
+		return nil
+	}
+	return cfg.sandbox
+}
+
+// permits returns true if the method m of the type t can be called from a
+// template.  All methods are permitted if s is nil.
+func (s *sandbox) permits(t reflect.Type, m reflect.Method) bool {
+	if s == nil {
+		return true
+	}
+	if s.allowed[derefType(t)][m.Name] {
+		return true
+	}
+	if !s.getters {
+		return false
+	}
+
+	// The type of a method obtained from an interface type does not
+	// include the receiver.
+
+	in := m.Type.NumIn()
+	if t.Kind() != reflect.Interface {
+		in--
+	}
+	if in != 0 || m.Type.IsVariadic() {
+		return false
+	}
+	switch m.Type.NumOut() {
+	case 1:
+		return m.Type.Out(0) != errorType
+	case 2:
+		return m.Type.Out(1) == errorType
+	}
+	return false
+}
+
+// denied returns true if name identifies an exported method of t, or of a
+// pointer to t, that templates are not permitted to call.
+func (s *sandbox) denied(t reflect.Type, name string) bool {
+	if s == nil || t == nil {
+		return false
+	}
+
+	types := []reflect.Type{t}
+	if t.Kind() != reflect.Interface {
+		t = derefType(t)
+		types = []reflect.Type{t, reflect.PtrTo(t)}
+	}
+	for _, t := range types {
+		if m, ok := t.MethodByName(name); ok && m.PkgPath == "" {
+			return !s.permits(t, m)
+		}
+	}
+	return false
+}
+
+// sandboxFn is the name of the function that checks field and method
+// accesses on values whose types are not known until a template is
+// executed.  It is added after the template has been parsed, so it cannot
+// be invoked by the template's source.
+const sandboxFn = "tfortoolsSandbox"
+
+// check aborts the template if name identifies a method of v that templates
+// are not permitted to call.  Otherwise, it returns v unmodified.
+func (s *sandbox) check(name string, v interface{}) interface{} {
+	if v != nil && s.denied(reflect.TypeOf(v), name) {
+		raise(KindBadField, sandboxFn, name, "method %s of type %T is not permitted",
+			name, v)
+	}
+	return v
+}
+
+// newSandboxChain returns a node that evaluates the fields in idents on
+// the result of the node base, passing the value on which each field is
+// evaluated to sandboxFn first, e.g., .A.B becomes
+// ((. | tfortoolsSandbox "A").A | tfortoolsSandbox "B").B.
+func newSandboxChain(pos parse.Pos, base parse.Node, idents []string) parse.Node {
+	for _, id := range idents {
+		pipe := &parse.PipeNode{
+			NodeType: parse.NodePipe,
+			Pos:      pos,
+			Cmds: []*parse.CommandNode{
+				{NodeType: parse.NodeCommand, Pos: pos, Args: []parse.Node{base}},
+				{
+					NodeType: parse.NodeCommand,
+					Pos:      pos,
+					Args: []parse.Node{
+						parse.NewIdentifier(sandboxFn).SetPos(pos),
+						&parse.StringNode{
+							NodeType: parse.NodeString,
+							Pos:      pos,
+							Quoted:   strconv.Quote(id),
+							Text:     id,
+						},
+					},
+				},
+			},
+		}
+		base = &parse.ChainNode{
+			NodeType: parse.NodeChain,
+			Pos:      pos,
+			Node:     pipe,
+			Field:    []string{id},
+		}
+	}
+	return base
+}
+
+// sandboxNode returns the node that should replace n, given that the
+// fields of n from the ith onwards must be checked at execution time.
+func sandboxNode(n parse.Node, i int) parse.Node {
+	switch n := n.(type) {
+	case *parse.FieldNode:
+		var base parse.Node = &parse.DotNode{NodeType: parse.NodeDot, Pos: n.Pos}
+		if i > 0 {
+			base = &parse.FieldNode{NodeType: parse.NodeField, Pos: n.Pos, Ident: n.Ident[:i]}
+		}
+		return newSandboxChain(n.Pos, base, n.Ident[i:])
+	case *parse.VariableNode:
+		base := &parse.VariableNode{NodeType: parse.NodeVariable, Pos: n.Pos,
+			Ident: n.Ident[:i+1]}
+		return newSandboxChain(n.Pos, base, n.Ident[i+1:])
+	case *parse.ChainNode:
+		base := n.Node
+		if i > 0 {
+			base = &parse.ChainNode{NodeType: parse.NodeChain, Pos: n.Pos, Node: n.Node,
+				Field: n.Field[:i]}
+		}
+		return newSandboxChain(n.Pos, base, n.Field[i:])
+	}
+	return n
+}
+
+// sandboxPipe replaces the arguments of the commands in p, and in the
+// pipelines nested within them, that are found in guards.
+func sandboxPipe(p *parse.PipeNode, guards map[parse.Node]int) {
+	if p == nil {
+		return
+	}
+	for _, cmd := range p.Cmds {
+		for j, arg := range cmd.Args {
+			switch arg := arg.(type) {
+			case *parse.PipeNode:
+				sandboxPipe(arg, guards)
+			case *parse.ChainNode:
+				if pipe, ok := arg.Node.(*parse.PipeNode); ok {
+					sandboxPipe(pipe, guards)
+				}
+			}
+			if i, ok := guards[arg]; ok {
+				cmd.Args[j] = sandboxNode(arg, i)
+			}
+		}
+	}
+}
+
+// sandboxList applies sandboxPipe to the pipelines of the actions in n.
+func sandboxList(n parse.Node, guards map[parse.Node]int) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, node := range n.Nodes {
+			sandboxList(node, guards)
+		}
+	case *parse.ActionNode:
+		sandboxPipe(n.Pipe, guards)
+	case *parse.IfNode:
+		sandboxBranch(&n.BranchNode, guards)
+	case *parse.WithNode:
+		sandboxBranch(&n.BranchNode, guards)
+	case *parse.RangeNode:
+		sandboxBranch(&n.BranchNode, guards)
+	case *parse.TemplateNode:
+		sandboxPipe(n.Pipe, guards)
+	}
+}
+
+func sandboxBranch(b *parse.BranchNode, guards map[parse.Node]int) {
+	sandboxPipe(b.Pipe, guards)
+	sandboxList(b.List, guards)
+	sandboxList(b.ElseList, guards)
+}
+
+// apply instruments the parsed template t so that the fields of the nodes
+// in guards, whose types could not be determined by the checker, are
+// checked as t executes.
+func (s *sandbox) apply(t *template.Template, guards map[parse.Node]int) {
+	t.Funcs(template.FuncMap{sandboxFn: s.check})
+	if len(guards) == 0 {
+		return
+	}
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil {
+			sandboxList(tmpl.Tree.Root, guards)
+		}
+	}
+}
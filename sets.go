@@ -0,0 +1,146 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tfortools
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// unhashableKey is the key used for values that cannot be used as map keys
+// and for nil values.  It includes the type of the value so that it cannot
+// be equal to the key of a value of a different type, e.g., the key of the
+// slice [a] differs from the key of the string "[a]".
+type unhashableKey struct {
+	typ reflect.Type
+	s   string
+}
+
+// setKey returns the value of the field identified by fieldPath in v in a
+// form that can be used as a map key.  Values that cannot be used as map
+// keys are represented by an unhashableKey.  All elements that do not
+// contain the field share the same key, nil.
+func setKey(fieldPath []string, v reflect.Value) interface{} {
+	f := findField(fieldPath, v)
+	for (f.Kind() == reflect.Ptr || f.Kind() == reflect.Interface) && !f.IsNil() {
+		f = f.Elem()
+	}
+	if !f.IsValid() {
+		return nil
+	}
+	if isNil(f) || !f.Type().Comparable() || !hashable(f.Interface()) {
+		return unhashableKey{f.Type(), fmt.Sprintf("%v", f.Interface())}
+	}
+	return f.Interface()
+}
+
+// hashable returns true if key can be used as a map key.  Structures and
+// arrays are comparable even if they contain interface values, but using
+// them as a map key panics if the dynamic type of one of these values is
+// not comparable, e.g., a slice.
+func hashable(key interface{}) (ok bool) {
+	switch reflect.TypeOf(key).Kind() {
+	case reflect.Struct, reflect.Array:
+	default:
+		return true
+	}
+
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	_ = map[interface{}]struct{}{key: {}}
+	return true
+}
+
+// assertSetOperands checks that obj1 and obj2 are slices or arrays of the
+// same type of element and that field identifies a valid field of that type.
+// It returns the values of obj1 and obj2 and the path to the field.
+func assertSetOperands(fnName string, obj1, obj2 interface{}, field string) (reflect.Value, reflect.Value, []string) {
+	val1 := assertSlice(fnName, obj1)
+	val2 := assertSlice(fnName, obj2)
+	if val1.Type().Elem() != val2.Type().Elem() {
+		fatalType(fnName, "slices of the same type expected, found %s and %s",
+			val1.Type(), val2.Type())
+	}
+
+	fieldPath := strings.Split(field, ".")
+	checkFieldPath(fnName, fieldPath, val1.Type().Elem())
+	return val1, val2, fieldPath
+}
+
+func (e *env) keySet(fnName string, fieldPath []string, val reflect.Value) map[interface{}]struct{} {
+	keys := make(map[interface{}]struct{}, val.Len())
+	for i := 0; i < val.Len(); i++ {
+		e.checkContext(fnName)
+		keys[setKey(fieldPath, val.Index(i))] = struct{}{}
+	}
+	return keys
+}
+
+// selectElements returns a new slice containing the elements of val for
+// which keep returns true.  Only the first element with a given key is
+// retained.  seen is updated with the keys of the elements added.
+func (e *env) selectElements(fnName string, copy, val reflect.Value, fieldPath []string,
+	seen map[interface{}]struct{}, keep func(key interface{}) bool) reflect.Value {
+	for i := 0; i < val.Len(); i++ {
+		e.checkContext(fnName)
+		key := setKey(fieldPath, val.Index(i))
+		if _, ok := seen[key]; ok || !keep(key) {
+			continue
+		}
+		seen[key] = struct{}{}
+		copy = reflect.Append(copy, val.Index(i))
+	}
+	return copy
+}
+
+func (e *env) union(obj1, obj2 interface{}, field string) interface{} {
+	val1, val2, fieldPath := assertSetOperands("union", obj1, obj2, field)
+	copy := reflect.MakeSlice(reflect.SliceOf(val1.Type().Elem()), 0, val1.Len()+val2.Len())
+	seen := make(map[interface{}]struct{})
+	all := func(interface{}) bool { return true }
+	copy = e.selectElements("union", copy, val1, fieldPath, seen, all)
+	copy = e.selectElements("union", copy, val2, fieldPath, seen, all)
+	return copy.Interface()
+}
+
+func (e *env) intersect(obj1, obj2 interface{}, field string) interface{} {
+	val1, val2, fieldPath := assertSetOperands("intersect", obj1, obj2, field)
+	copy := reflect.MakeSlice(reflect.SliceOf(val1.Type().Elem()), 0, val1.Len())
+	keys := e.keySet("intersect", fieldPath, val2)
+	copy = e.selectElements("intersect", copy, val1, fieldPath, make(map[interface{}]struct{}),
+		func(key interface{}) bool {
+			_, ok := keys[key]
+			return ok
+		})
+	return copy.Interface()
+}
+
+func (e *env) difference(obj1, obj2 interface{}, field string) interface{} {
+	val1, val2, fieldPath := assertSetOperands("difference", obj1, obj2, field)
+	copy := reflect.MakeSlice(reflect.SliceOf(val1.Type().Elem()), 0, val1.Len())
+	keys := e.keySet("difference", fieldPath, val2)
+	copy = e.selectElements("difference", copy, val1, fieldPath, make(map[interface{}]struct{}),
+		func(key interface{}) bool {
+			_, ok := keys[key]
+			return !ok
+		})
+	return copy.Interface()
+}
@@ -0,0 +1,119 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tfortools
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+func trim(s string, cutset ...string) string {
+	if len(cutset) > 1 {
+		fatalArity("trim", "accepts a maximum of two arguments")
+	} else if len(cutset) == 1 {
+		return strings.Trim(s, cutset[0])
+	}
+	return strings.TrimSpace(s)
+}
+
+func replace(s, old, new string) string {
+	return strings.Replace(s, old, new, -1)
+}
+
+func split(s, sep string) []string {
+	return strings.Split(s, sep)
+}
+
+func join(obj interface{}, sep string) string {
+	if strs, ok := obj.([]string); ok {
+		return strings.Join(strs, sep)
+	}
+
+	val := getValue(obj)
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		fatalType("join", "slice or an array expected")
+	}
+	strs := make([]string, val.Len())
+	for i := range strs {
+		strs[i] = fmt.Sprintf("%v", val.Index(i).Interface())
+	}
+	return strings.Join(strs, sep)
+}
+
+// padding returns the string needed to pad s to width runes.
+func (e *env) padding(fnName, s string, width int, pad []string) string {
+	p := " "
+	if len(pad) > 1 {
+		fatalArity(fnName, "accepts a maximum of three arguments")
+	} else if len(pad) == 1 {
+		if utf8.RuneCountInString(pad[0]) != 1 {
+			fatalf(fnName, "padding must be a single character")
+		}
+		p = pad[0]
+	}
+	if width < 0 {
+		fatalf(fnName, "width must be positive")
+	}
+	e.checkWidth(fnName, width)
+
+	n := width - utf8.RuneCountInString(s)
+	if n <= 0 {
+		return ""
+	}
+	return strings.Repeat(p, n)
+}
+
+func (e *env) padLeft(s string, width int, pad ...string) string {
+	return e.padding("padLeft", s, width, pad) + s
+}
+
+func (e *env) padRight(s string, width int, pad ...string) string {
+	return s + e.padding("padRight", s, width, pad)
+}
+
+func truncate(s string, length int, suffix ...string) string {
+	if length < 0 {
+		fatalf("truncate", "length must be positive")
+	}
+	if len(suffix) > 1 {
+		fatalArity("truncate", "accepts a maximum of three arguments")
+	}
+
+	runes := []rune(s)
+	if len(runes) <= length {
+		return s
+	}
+
+	if len(suffix) == 1 {
+		suffixLen := utf8.RuneCountInString(suffix[0])
+		if suffixLen <= length {
+			return string(runes[:length-suffixLen]) + suffix[0]
+		}
+	}
+	return string(runes[:length])
+}
+
+func regexReplace(s, expr, repl string) string {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		fatalf("regexReplace", "Invalid regexp: %v", err)
+	}
+	return re.ReplaceAllString(s, repl)
+}
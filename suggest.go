@@ -0,0 +1,169 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tfortools
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const maxSuggestions = 3
+
+var undefinedFnRegexp = regexp.MustCompile(`function "([^"]*)" not defined`)
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// editDistance returns the edit distance between a and b, counting
+// insertions, deletions, substitutions and transpositions of adjacent
+// characters as single edits.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j-1]+cost, minInt(d[i-1][j], d[i][j-1])+1)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+type suggestion struct {
+	name     string
+	distance int
+}
+
+type byDistance []suggestion
+
+func (s byDistance) Len() int           { return len(s) }
+func (s byDistance) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byDistance) Less(i, j int) bool { return s[i].distance < s[j].distance }
+
+// closestNames returns up to maxSuggestions of the candidates that are
+// closest to name, ignoring case.  Candidates that differ too much from
+// name to be plausible misspellings are not returned.
+func closestNames(name string, candidates []string) []string {
+	max := len(name) / 3
+	if max < 1 {
+		max = 1
+	}
+
+	var matches []suggestion
+	seen := make(map[string]bool)
+	lower := strings.ToLower(name)
+	for _, c := range candidates {
+		if c == name || seen[c] {
+			continue
+		}
+		seen[c] = true
+		if d := editDistance(lower, strings.ToLower(c)); d <= max {
+			matches = append(matches, suggestion{c, d})
+		}
+	}
+	sort.Stable(byDistance(matches))
+
+	var names []string
+	for i := 0; i < len(matches) && i < maxSuggestions; i++ {
+		names = append(names, matches[i].name)
+	}
+	return names
+}
+
+// didYouMean returns a suffix for an error message about the unknown name
+// that lists the closest matches from candidates.  An empty string is
+// returned if there are no close matches.
+func didYouMean(name string, candidates []string) string {
+	closest := closestNames(name, candidates)
+	if len(closest) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %s?", strings.Join(closest, " or "))
+}
+
+// fieldHint returns a suffix for an error message about the unknown field
+// name that lists the closest matches and all the available fields.
+func fieldHint(name string, available []string) string {
+	if len(available) == 0 {
+		return ""
+	}
+	hint := didYouMean(name, available)
+	if hint == "" {
+		hint = "."
+	}
+	return fmt.Sprintf("%s Valid fields are: %s", hint, strings.Join(available, ", "))
+}
+
+// exportedFieldNames returns the names of the exported fields of the struct
+// type t, or nil if t is not a struct.
+func exportedFieldNames(t reflect.Type) []string {
+	t = derefType(t)
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.PkgPath == "" {
+			names = append(names, f.Name)
+		}
+	}
+	return names
+}
+
+// fatalField raises an error for the function fnName reporting that field
+// is not a valid field name.  The error lists the closest matches in
+// available and all the available fields.
+func fatalField(fnName, field string, available []string) {
+	fatalFieldf(fnName, field, "%s is not a valid field name%s", field, fieldHint(field, available))
+}
+
+// suggestFunction adds suggestions to err, if err is a parse error caused
+// by the use of an unknown function.  The suggestions are taken from the
+// functions enabled in cfg and the functions built into Go's template
+// language.
+func suggestFunction(err error, cfg *Config) error {
+	m := undefinedFnRegexp.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	names := append(TemplateFunctionNames(cfg), builtinNames...)
+	hint := didYouMean(m[1], names)
+	if hint == "" {
+		return err
+	}
+	return fmt.Errorf("%v%s", err, hint)
+}
@@ -63,16 +63,17 @@ package tfortools
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"reflect"
 	"sort"
 	"strings"
 	"text/template"
+	"time"
 	"unicode"
 )
 
-// BUG(markdryan): Map to slice
-
 // These constants are used to ensure that all the help text
 // for functions provided by this package are always presented
 // in the same order.
@@ -83,6 +84,8 @@ const (
 	helpFilterHasSuffixIndex
 	helpFilterFoldedIndex
 	helpFilterRegexpIndex
+	helpFilterNilIndex
+	helpFilterNotNilIndex
 	helpToJSONIndex
 	helpToCSVIndex
 	helpSelectIndex
@@ -104,6 +107,64 @@ const (
 	helpPromoteIndex
 	helpSliceofIndex
 	helpToTableIndex
+	helpKeysIndex
+	helpValuesIndex
+	helpEntriesIndex
+	helpFormatColIndex
+	helpHumanBytesIndex
+	helpHumanDurationIndex
+	helpSinceIndex
+	helpAgoIndex
+	helpSINumberIndex
+	helpThousandsIndex
+	helpUpperIndex
+	helpLowerIndex
+	helpTrimIndex
+	helpReplaceIndex
+	helpSplitIndex
+	helpJoinIndex
+	helpPadLeftIndex
+	helpPadRightIndex
+	helpTruncateIndex
+	helpRegexReplaceIndex
+	helpAddIndex
+	helpSubIndex
+	helpMulIndex
+	helpDivIndex
+	helpModIndex
+	helpRoundIndex
+	helpFloorIndex
+	helpCeilIndex
+	helpAbsIndex
+	helpPercentIndex
+	helpNowIndex
+	helpFormatTimeIndex
+	helpParseTimeIndex
+	helpTimeTruncIndex
+	helpAddDurationIndex
+	helpInZoneIndex
+	helpListIndex
+	helpDictIndex
+	helpSeqIndex
+	helpDefaultIndex
+	helpCoalesceIndex
+	helpTernaryIndex
+	helpUnionIndex
+	helpIntersectIndex
+	helpDifferenceIndex
+	helpReverseIndex
+	helpSkipIndex
+	helpSubsliceIndex
+	helpChunkIndex
+	helpFlattenSlicesIndex
+	helpZipIndex
+	helpShuffleIndex
+	helpSampleIndex
+	helpEnumerateIndex
+	helpRankIndex
+	helpFromJSONIndex
+	helpFromYAMLIndex
+	helpFromCSVIndex
 	helpIndexCount
 )
 
@@ -123,8 +184,15 @@ type funcHelpInfo struct {
 //
 // All members of Config are private.
 type Config struct {
-	funcMap  template.FuncMap
-	funcHelp []funcHelpInfo
+	funcMap        template.FuncMap
+	funcHelp       []funcHelpInfo
+	nilPlaceholder string
+	firstSeenKeys  bool
+	now            time.Time
+	limits         limits
+	sandbox        *sandbox
+	lineEditing    bool
+	historyFile    string
 }
 
 func (c *Config) Len() int           { return len(c.funcHelp) }
@@ -136,7 +204,8 @@ func (c *Config) Less(i, j int) bool { return c.funcHelp[i].index < c.funcHelp[j
 // implementation is provided by fn, its name, i.e., the name used to invoke the
 // function in a program, is provided by name and the help for the function is
 // provided by helpText.  An error will be returned if a function with the same
-// name is already associated with this Config object.
+// name is already associated with this Config object, including functions
+// enabled by OptAllFns.
 func (c *Config) AddCustomFn(fn interface{}, name, helpText string) error {
 	if _, found := c.funcMap[name]; found {
 		return fmt.Errorf("%s already exists", name)
@@ -151,7 +220,11 @@ func (c *Config) AddCustomFn(fn interface{}, name, helpText string) error {
 	return nil
 }
 
-// OptAllFns enables all template extension functions provided by this package
+// OptAllFns enables all template extension functions provided by this package.
+// Programs that add custom functions whose names clash with those of
+// functions provided by this package should instead enable only the
+// functions or groups of functions that they need, e.g., OptMath or
+// OptStrings, as AddCustomFn will not replace an existing function.
 func OptAllFns(c *Config) {
 	c.funcMap = make(template.FuncMap)
 	for k, v := range funcMap {
@@ -164,25 +237,27 @@ func OptAllFns(c *Config) {
 	}
 }
 
-const helpFilter = `- 'filter' operates on an slice or array of structures.  It allows the caller
-  to filter the input array based on the value of a single field.
+const helpFilter = `- 'filter' operates on an slice or array of structures or maps.  It allows the
+  caller to filter the input array based on the value of a single field.
   The function returns a slice containing only the objects that satisfy the
   filter, e.g.
 
   {{len (filter . "Protected" "true")}}
 
   outputs the number of elements whose "Protected" field is equal to "true".
+  Maps that do not contain the requested key never satisfy the filter.
 `
 
 // OptFilter indicates that the filter function should be enabled.
-// 'filter' operates on an slice or array of structures.  It allows the caller
-// to filter the input array based on the value of a single field.
+// 'filter' operates on an slice or array of structures or maps.  It allows the
+// caller to filter the input array based on the value of a single field.
 // The function returns a slice containing only the objects that satisfy the
 // filter, e.g.
 //
 //  {{len (filter . "Protected" "true")}}
 //
 // outputs the number of elements whose "Protected" field is equal to "true".
+// Maps that do not contain the requested key never satisfy the filter.
 func OptFilter(c *Config) {
 	if _, ok := c.funcMap["filter"]; ok {
 		return
@@ -285,9 +360,53 @@ func OptFilterRegexp(c *Config) {
 		funcHelpInfo{"filterRegexp", helpFilterRegexp, helpFilterRegexpIndex})
 }
 
+const helpFilterNil = `- 'filterNil' returns the elements of a slice or array of structures or maps for
+  which the given field is nil.  A field is considered to be nil if it is a
+  nil pointer or interface, if a nil pointer is encountered while following
+  the field path, or if a map in the path does not contain the requested key.
+
+  {{len (filterNil . "Spec.Owner")}}
+
+  outputs the number of elements that have no owner.
+`
+
+// OptFilterNil indicates that the filterNil function should be enabled.
+// 'filterNil' returns the elements of a slice or array of structures or maps for
+// which the given field is nil.  A field is considered to be nil if it is a
+// nil pointer or interface, if a nil pointer is encountered while following
+// the field path, or if a map in the path does not contain the requested key.
+//
+//  {{len (filterNil . "Spec.Owner")}}
+//
+// outputs the number of elements that have no owner.
+func OptFilterNil(c *Config) {
+	if _, ok := c.funcMap["filterNil"]; ok {
+		return
+	}
+	c.funcMap["filterNil"] = filterByNil
+	c.funcHelp = append(c.funcHelp,
+		funcHelpInfo{"filterNil", helpFilterNil, helpFilterNilIndex})
+}
+
+const helpFilterNotNil = `- 'filterNotNil' is the inverse of filterNil.  It returns the elements whose
+  given field is not nil.
+`
+
+// OptFilterNotNil indicates that the filterNotNil function should be enabled.
+// 'filterNotNil' is the inverse of filterNil.  It returns the elements whose
+// given field is not nil.
+func OptFilterNotNil(c *Config) {
+	if _, ok := c.funcMap["filterNotNil"]; ok {
+		return
+	}
+	c.funcMap["filterNotNil"] = filterByNotNil
+	c.funcHelp = append(c.funcHelp,
+		funcHelpInfo{"filterNotNil", helpFilterNotNil, helpFilterNotNilIndex})
+}
+
 // OptAllFilters is a convenience function that enables the following functions;
 // 'filter', 'filterContains', 'filterHasPrefix', 'filterHasSuffix', 'filterFolded',
-// and 'filterRegexp'
+// 'filterRegexp', 'filterNil' and 'filterNotNil'
 func OptAllFilters(c *Config) {
 	OptFilter(c)
 	OptFilterContains(c)
@@ -295,6 +414,100 @@ func OptAllFilters(c *Config) {
 	OptFilterHasSuffix(c)
 	OptFilterFolded(c)
 	OptFilterRegexp(c)
+	OptFilterNil(c)
+	OptFilterNotNil(c)
+}
+
+// OptNilPlaceholder returns an option that sets the text output by the
+// select and table functions, and their variants, in place of nil pointers
+// and interfaces.  Nil values are also output using this placeholder when
+// a nil pointer is encountered while following a field path.  The default
+// placeholder is "<nil>".  For example,
+//
+//  cfg := tfortools.NewConfig(tfortools.OptAllFns, tfortools.OptNilPlaceholder("-"))
+//
+// creates a Config object that enables all functions and outputs nil values
+// as "-".
+func OptNilPlaceholder(placeholder string) func(*Config) {
+	return func(c *Config) {
+		c.nilPlaceholder = placeholder
+	}
+}
+
+// OptFirstSeenKeys indicates that the headings of the tables and CSV
+// generated from slices of maps should be output in the order in which
+// the keys are first seen, rather than sorted.  The keys of each map are
+// visited in sorted order, so the headings start with the sorted keys of
+// the first map and keys that are not present in the first map follow in
+// the order of the maps that contain them.  For example,
+//
+//  cfg := tfortools.NewConfig(tfortools.OptAllFns, tfortools.OptFirstSeenKeys)
+func OptFirstSeenKeys(c *Config) {
+	c.firstSeenKeys = true
+}
+
+// OptFrozenNow returns an option that freezes the current time, as seen by
+// the 'now', 'since' and 'ago' functions and by formatCol's "ago" format, at
+// t.  This is useful when the output of a template needs to be reproducible,
+// e.g., in tests.  For example,
+//
+//  cfg := tfortools.NewConfig(tfortools.OptTime, tfortools.OptFrozenNow(t))
+//
+// creates a Config object in which 'now' always returns t.
+func OptFrozenNow(t time.Time) func(*Config) {
+	return func(c *Config) {
+		c.now = t
+	}
+}
+
+// OptMaxOutput returns an option that limits the number of bytes that a
+// template can write to n.  If a template attempts to write more than n
+// bytes, the first n bytes are written and OutputToTemplate returns a
+// *LimitError.  This option, and the other limits, are intended to make it
+// safe to execute templates supplied by untrusted users, e.g.,
+//
+//  cfg := tfortools.NewConfig(tfortools.OptAllFns, tfortools.OptMaxOutput(1<<20),
+//          tfortools.OptMaxIterations(100000), tfortools.OptMaxSliceLen(10000),
+//          tfortools.OptMaxDepth(10))
+//
+// The output, iteration and depth limits are only enforced by
+// OutputToTemplate and OutputToTemplateContext.  The slice length limit, and
+// the checks that the template functions make against the output limit
+// before building a string, are enforced by the functions themselves, so
+// they also apply to templates created by CreateTemplate.  A limit of 0
+// disables the limit.
+func OptMaxOutput(n int64) func(*Config) {
+	return func(c *Config) {
+		c.limits.output = n
+	}
+}
+
+// OptMaxIterations returns an option that limits the total number of
+// iterations performed by all the range actions in a template to n.
+func OptMaxIterations(n int64) func(*Config) {
+	return func(c *Config) {
+		c.limits.iterations = n
+	}
+}
+
+// OptMaxSliceLen returns an option that limits the length of the slices
+// returned by template functions, such as cols, rows and seq, to n.
+// Functions whose results can be much larger than their inputs, such as
+// seq, chunk, zip and flattenSlices, check the length of their result
+// before allocating it.  The width passed to padLeft and padRight is also
+// limited to n, and to the output limit.
+func OptMaxSliceLen(n int) func(*Config) {
+	return func(c *Config) {
+		c.limits.sliceLen = n
+	}
+}
+
+// OptMaxDepth returns an option that limits the depth to which templates
+// can invoke other templates, using the template action, to n.
+func OptMaxDepth(n int) func(*Config) {
+	return func(c *Config) {
+		c.limits.depth = n
+	}
 }
 
 const helpToJSON = `- 'tojson' outputs the target object in json format, e.g., {{tojson .}}
@@ -310,7 +523,8 @@ func OptToJSON(c *Config) {
 	c.funcHelp = append(c.funcHelp, funcHelpInfo{"tojson", helpToJSON, helpToJSONIndex})
 }
 
-const helpToCSV = `- 'tocsv' converts a [][]string or a slice of structs to csv format, e.g.,
+const helpToCSV = `- 'tocsv' converts a [][]string or a slice of structs or maps to csv format,
+  e.g.,
   {{tocsv .}}
 
   'tocsv' takes an optional boolean parameter, which if true, omits the
@@ -320,7 +534,8 @@ const helpToCSV = `- 'tocsv' converts a [][]string or a slice of structs to csv
 `
 
 // OptToCSV indicates that the 'tocsv' function should be enabled.
-// 'tocsv' converts a [][]string or a slice of structs to csv format, e.g.,
+// 'tocsv' converts a [][]string or a slice of structs or maps to csv format,
+// e.g.,
 // {{tocsv .}}
 //
 // 'tocsv' takes an optional boolean parameter, which if true, omits the
@@ -378,6 +593,14 @@ const helpTable = `- 'table' outputs a table given an array or a slice of struct
   are hardcoded to 8.  An example of table's usage is
 
   {{table .}}
+
+  'table' also accepts a slice of maps with string keys.  In this case the
+  headings are the sorted union of the keys of all the maps, or the union
+  in first-seen order if OptFirstSeenKeys is used.  Cells for keys missing
+  from a map are left blank.  Slices of interfaces whose values are
+  structs or maps of differing types are also accepted.  The headings of such
+  tables are the union of the fields of all the elements, in the order in
+  which they are first encountered.
 `
 
 // OptTable indicates that the 'table' function should be enabled.
@@ -387,6 +610,14 @@ const helpTable = `- 'table' outputs a table given an array or a slice of struct
 // are hardcoded to 8.  An example of table's usage is
 //
 //  {{table .}}
+//
+// 'table' also accepts a slice of maps with string keys.  In this case the
+// headings are the sorted union of the keys of all the maps, or the union
+// in first-seen order if OptFirstSeenKeys is used.  Cells for keys missing
+// from a map are left blank.  Slices of interfaces whose values are
+// structs or maps of differing types are also accepted.  The headings of such
+// tables are the union of the fields of all the elements, in the order in
+// which they are first encountered.
 func OptTable(c *Config) {
 	if _, ok := c.funcMap["table"]; ok {
 		return
@@ -463,6 +694,8 @@ const helpHTable = `- 'htable' outputs each element of an array or a slice of st
   column width are hardcoded to 8.  An example of htable's usage is
 
   {{htable .}}
+
+  Like 'table', 'htable' also accepts slices of maps and slices of interfaces.
 `
 
 // OptHTable indicates that the 'htable' function should be enabled.
@@ -473,6 +706,8 @@ const helpHTable = `- 'htable' outputs each element of an array or a slice of st
 // column width are hardcoded to 8.  An example of htable's usage is
 //
 //  {{htable .}}
+//
+// Like 'table', 'htable' also accepts slices of maps and slices of interfaces.
 func OptHTable(c *Config) {
 	if _, ok := c.funcMap["htable"]; ok {
 		return
@@ -549,7 +784,10 @@ const helpCols = `- 'cols' can be used to extract certain columns from a table c
   {{cols . "Name" "Address"}}
 
   returns a new slice of structs, each element of which is a structure with only
-  two fields, 'Name' and 'Address'.
+  two fields, 'Name' and 'Address'.  When given a slice of maps, 'cols' returns
+  a new slice of maps containing only the requested keys.  When given a slice
+  of interfaces, each element of the new slice contains only the requested
+  fields of the corresponding input element.
 `
 
 // OptCols indicates that the 'cols' function should be enabled.
@@ -560,7 +798,10 @@ const helpCols = `- 'cols' can be used to extract certain columns from a table c
 //  {{cols . "Name" "Address"}}
 //
 // returns a new slice of structs, each element of which is a structure with only
-// two fields, 'Name' and 'Address'.
+// two fields, 'Name' and 'Address'.  When given a slice of maps, 'cols' returns
+// a new slice of maps containing only the requested keys.  When given a slice
+// of interfaces, each element of the new slice contains only the requested
+// fields of the corresponding input element.
 func OptCols(c *Config) {
 	if _, ok := c.funcMap["cols"]; ok {
 		return
@@ -579,6 +820,10 @@ const helpSort = `- 'sort' sorts a slice or an array of structs.  It takes three
   The following example sorts a slice in ascending order by the Name field.
   
   {{sort . "Name"}}
+
+  'sort' can also sort a slice of maps by the values associated with a given
+  key, or a slice of interfaces holding different types of structs.  Elements
+  that do not contain the field are placed at the end of the slice.
 `
 
 // OptSort indicates that the 'sort' function should be enabled.
@@ -592,6 +837,10 @@ const helpSort = `- 'sort' sorts a slice or an array of structs.  It takes three
 // The following example sorts a slice in ascending order by the Name field.
 //
 //  {{sort . "Name"}}
+//
+// 'sort' can also sort a slice of maps by the values associated with a given
+// key, or a slice of interfaces holding different types of structs.  Elements
+// that do not contain the field are placed at the end of the slice.
 func OptSort(c *Config) {
 	if _, ok := c.funcMap["sort"]; ok {
 		return
@@ -609,6 +858,9 @@ const helpRows = `- 'rows' is used to extract a set of given rows from a slice o
   {{rows . 1 2}}
 
   extracts the 2nd and 3rd rows from the slice represented by '.'.
+
+  Slices of integers, such as those returned by 'seq', may be passed in place
+  of individual indices, e.g., {{rows . (seq 0 10 2)}}.
 `
 
 // OptRows indicates that the 'rows' function should be enabled.
@@ -621,6 +873,9 @@ const helpRows = `- 'rows' is used to extract a set of given rows from a slice o
 //  {{rows . 1 2}}
 //
 // extracts the 2nd and 3rd rows from the slice represented by '.'.
+//
+// Slices of integers, such as those returned by 'seq', may be passed in place
+// of individual indices, e.g., {{rows . (seq 0 10 2)}}.
 func OptRows(c *Config) {
 	if _, ok := c.funcMap["rows"]; ok {
 		return
@@ -787,23 +1042,63 @@ func OptSliceof(c *Config) {
 
 const helpToTable = `- 'totable' converts a slice of a slice of strings into a slice of
   structures.  The field names of the structures are taken from the values of
-  the first row in the slice.  The types of the fields are derived from the
-  values specified in the second row.  The input slice should be of length 2
-  or greater.  Data in columns in the second and subsequent rows should be
-  homogenous.  The elements of the first row should be unique and ideally be
-  valid exported variable names.  'totable' will try to sanitize the field
-  names, if they are not valid go identifiers.
+  the first row in the slice.  The types of the fields are inferred from the
+  values in the remaining rows, choosing the narrowest of int, float64, bool,
+  time.Time and string that can hold all the values of a column.  Times must
+  be in RFC3339 or ISO 8601 date format, e.g., 2017-03-17.  Columns other
+  than string columns that contain empty cells are stored in pointer fields
+  which are nil for the empty cells.  The input slice should be of length 2
+  or greater.  The elements of the first row should ideally be valid exported
+  variable names.  'totable' will try to sanitize the field names, if they
+  are not valid go identifiers, and will append suffixes, e.g., _2, to names
+  that are not unique.  All the rows must have the same number of columns.
+
+  'totable' accepts the following options as additional parameters.
+
+    "noheader" indicates that the first row contains data.  The fields are
+    named Col1, Col2, etc.
+    "sample=n" limits the number of rows used to infer the types of the
+    fields to n.  An error is returned if a value outside the sample does not
+    match the type of its column.
+    "schema=..." specifies the names and types of the fields, overriding
+    those in the header row, e.g., "schema=Name:string,Age:*int".  The types
+    may be int, float, bool, time or string.  Types prefixed with '*' are
+    stored in pointer fields.  If a type is omitted it is inferred.
+
+  For example,
+
+  {{totable . "noheader" "schema=Name,Age:int"}}
 `
 
 // OptToTable indicates that the 'totable' function should be enabled. 'totable'
 // takes a slice of a slice of strings as an argument and returns a slice of
 // structures.  The field names of the structures are taken from the values of
-// the first row in the slice.  The types of the fields are derived from the
-// values specified in the second row.  The input slice should be of length 2
-// or greater.  Data in columns in the second and subsequent rows should be
-// homogenous.  The elements of the first row should be unique and ideally
-// be valid exported variable names.  'totable' will try to sanitize the field
-// names, if they are not valid go identifiers.
+// the first row in the slice.  The types of the fields are inferred from the
+// values in the remaining rows, choosing the narrowest of int, float64, bool,
+// time.Time and string that can hold all the values of a column.  Times must
+// be in RFC3339 or ISO 8601 date format, e.g., 2017-03-17.  Columns other
+// than string columns that contain empty cells are stored in pointer fields
+// which are nil for the empty cells.  The input slice should be of length 2
+// or greater.  The elements of the first row should ideally be valid exported
+// variable names.  'totable' will try to sanitize the field names, if they
+// are not valid go identifiers, and will append suffixes, e.g., _2, to names
+// that are not unique.  All the rows must have the same number of columns.
+//
+// 'totable' accepts the following options as additional parameters.
+//
+//   "noheader" indicates that the first row contains data.  The fields are
+//   named Col1, Col2, etc.
+//   "sample=n" limits the number of rows used to infer the types of the
+//   fields to n.  An error is returned if a value outside the sample does not
+//   match the type of its column.
+//   "schema=..." specifies the names and types of the fields, overriding
+//   those in the header row, e.g., "schema=Name:string,Age:*int".  The types
+//   may be int, float, bool, time or string.  Types prefixed with '*' are
+//   stored in pointer fields.  If a type is omitted it is inferred.
+//
+// For example,
+//
+//  {{totable . "noheader" "schema=Name,Age:int"}}
 func OptToTable(c *Config) {
 	if _, ok := c.funcMap["totable"]; ok {
 		return
@@ -812,96 +1107,1582 @@ func OptToTable(c *Config) {
 	c.funcHelp = append(c.funcHelp, funcHelpInfo{"totable", helpToTable, helpToTableIndex})
 }
 
-// NewConfig creates a new Config object that can be passed to other functions
-// in this package.  The Config option keeps track of which new functions are
-// added to Go's template libray.  If this function is called without arguments,
-// none of the functions defined in this package are enabled in the resulting Config
-// object.  To control which functions get added specify some options, e.g.,
+const helpKeys = `- 'keys' returns a slice containing the keys of a map.  The keys are sorted in
+  ascending order.  'keys' takes an optional second parameter which specifies
+  the sort direction.  It must be either "asc" or "dsc".  For example,
+
+  {{range keys .Labels "dsc"}}{{println .}}{{end}}
+
+  prints the keys of the Labels map in descending order.
+`
+
+// OptKeys indicates that the 'keys' function should be enabled.
+// 'keys' returns a slice containing the keys of a map.  The keys are sorted in
+// ascending order.  'keys' takes an optional second parameter which specifies
+// the sort direction.  It must be either "asc" or "dsc".  For example,
 //
-//  ctx := tfortools.NewConfig(tfortools.OptHead, tfortools.OptTail)
+//  {{range keys .Labels "dsc"}}{{println .}}{{end}}
 //
-// creates a new Config object that enables the 'head' and 'tail' functions only.
+// prints the keys of the Labels map in descending order.
+func OptKeys(c *Config) {
+	if _, ok := c.funcMap["keys"]; ok {
+		return
+	}
+	c.funcMap["keys"] = keys
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"keys", helpKeys, helpKeysIndex})
+}
+
+const helpValues = `- 'values' returns a slice containing the values of a map.  By default the
+  values are ordered by their keys.  'values' takes an optional second
+  parameter, either "asc" or "dsc".  If present the values themselves are
+  sorted in the requested direction.  For example,
+
+  {{values .Annotations "asc"}}
+
+  returns the values of the Annotations map sorted in ascending order.
+`
+
+// OptValues indicates that the 'values' function should be enabled.
+// 'values' returns a slice containing the values of a map.  By default the
+// values are ordered by their keys.  'values' takes an optional second
+// parameter, either "asc" or "dsc".  If present the values themselves are
+// sorted in the requested direction.  For example,
 //
-// To add all the functions, use the OptAllFNs options, e.g.,
+//  {{values .Annotations "asc"}}
 //
-//    ctx := tfortools.NewConfig(tfortools.OptAllFNs)
-func NewConfig(options ...func(*Config)) *Config {
-	c := &Config{
-		funcMap: make(template.FuncMap),
+// returns the values of the Annotations map sorted in ascending order.
+func OptValues(c *Config) {
+	if _, ok := c.funcMap["values"]; ok {
+		return
 	}
-	for _, f := range options {
-		f(c)
+	c.funcMap["values"] = values
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"values", helpValues, helpValuesIndex})
+}
+
+const helpEntries = `- 'entries' converts a map into a slice of structures, one for each entry in
+  the map.  Each structure has two fields, Key and Value.  The slice is sorted
+  by key, in ascending order by default.  The sort direction can be specified
+  using an optional second parameter which must be either "asc" or "dsc".  The
+  slice returned by 'entries' can be passed to other functions such as table,
+  sort and filter, e.g.,
+
+  {{table (entries .Labels)}}
+
+  outputs the contents of the Labels map as a two column table.
+`
+
+// OptEntries indicates that the 'entries' function should be enabled.
+// 'entries' converts a map into a slice of structures, one for each entry in
+// the map.  Each structure has two fields, Key and Value.  The slice is sorted
+// by key, in ascending order by default.  The sort direction can be specified
+// using an optional second parameter which must be either "asc" or "dsc".  The
+// slice returned by 'entries' can be passed to other functions such as table,
+// sort and filter, e.g.,
+//
+//  {{table (entries .Labels)}}
+//
+// outputs the contents of the Labels map as a two column table.
+func OptEntries(c *Config) {
+	if _, ok := c.funcMap["entries"]; ok {
+		return
 	}
-	sort.Sort(c)
+	c.funcMap["entries"] = entries
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"entries", helpEntries, helpEntriesIndex})
+}
 
-	return c
+const helpFormatCol = `- 'formatCol' specifies how individual columns of a slice of structs or maps
+  should be formatted.  It takes a slice followed by one or more pairs of
+  parameters.  The first parameter in each pair is the name of a field and the
+  second is the format to use for that field.  The format is either a printf
+  style format, a time layout for fields of type time.Time, or the name of one
+  of the functions humanBytes, humanDuration, siNumber, thousands or ago.
+  'formatCol' returns a new slice which can be passed to table, tocsv, select and their
+  variants, which output the chosen fields using the requested formats.  The
+  original values are retained, so the new slice can still be sorted
+  numerically.  For example,
+
+  {{table (sort (formatCol . "Current" "%.2f" "LastTrade" "2006-01-02 15:04") "Current")}}
+
+  outputs a table in which the Current field is shown with two decimal places
+  and the LastTrade field shows only the date and time, and
+
+  {{table (formatCol . "Volume" "siNumber")}}
+
+  outputs the Volume field using SI prefixes, e.g., 6.4G.
+`
+
+// OptFormatCol indicates that the 'formatCol' function should be enabled.
+// 'formatCol' specifies how individual columns of a slice of structs or maps
+// should be formatted.  It takes a slice followed by one or more pairs of
+// parameters.  The first parameter in each pair is the name of a field and the
+// second is the format to use for that field.  The format is either a printf
+// style format, a time layout for fields of type time.Time, or the name of one
+// of the functions humanBytes, humanDuration, siNumber, thousands or ago.
+// 'formatCol' returns a new slice which can be passed to table, tocsv, select and their
+// variants, which output the chosen fields using the requested formats.  The
+// original values are retained, so the new slice can still be sorted
+// numerically.  For example,
+//
+//  {{table (sort (formatCol . "Current" "%.2f" "LastTrade" "2006-01-02 15:04") "Current")}}
+//
+// outputs a table in which the Current field is shown with two decimal places
+// and the LastTrade field shows only the date and time, and
+//
+//  {{table (formatCol . "Volume" "siNumber")}}
+//
+// outputs the Volume field using SI prefixes, e.g., 6.4G.
+func OptFormatCol(c *Config) {
+	if _, ok := c.funcMap["formatCol"]; ok {
+		return
+	}
+	c.funcMap["formatCol"] = formatCol
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"formatCol", helpFormatCol, helpFormatColIndex})
 }
 
-// TemplateFunctionHelp generates formatted documentation that describes the
-// additional functions that the Config object c adds to Go's templating language.
-// If c is nil, documentation is generated for all functions provided by
-// tfortools.
-func TemplateFunctionHelp(c *Config) string {
-	b := &bytes.Buffer{}
-	_, _ = b.WriteString("Some new functions have been added to Go's template language\n\n")
+const helpHumanBytes = `- 'humanBytes' formats a number of bytes using binary units, e.g.,
 
-	for _, h := range getHelpers(c) {
-		_, _ = b.WriteString(h.help)
+  {{humanBytes 6395624278}}
+
+  outputs 6.0GiB.
+`
+
+// OptHumanBytes indicates that the 'humanBytes' function should be enabled.
+// 'humanBytes' formats a number of bytes using binary units, e.g.,
+//
+//  {{humanBytes 6395624278}}
+//
+// outputs 6.0GiB.
+func OptHumanBytes(c *Config) {
+	if _, ok := c.funcMap["humanBytes"]; ok {
+		return
 	}
-	return b.String()
+	c.funcMap["humanBytes"] = humanBytes
+	c.funcHelp = append(c.funcHelp,
+		funcHelpInfo{"humanBytes", helpHumanBytes, helpHumanBytesIndex})
 }
 
-// TemplateFunctionNames returns a slice of all the functions enabled in the
-// supplied Config object.
-func TemplateFunctionNames(c *Config) []string {
-	helpers := getHelpers(c)
-	names := make([]string, len(helpers))
-	for i := range helpers {
-		names[i] = helpers[i].name
+const helpHumanDuration = `- 'humanDuration' formats a time.Duration, or a number of seconds, using its
+  two most significant units, e.g., 3d4h, 2h5m or 12.5s.
+`
+
+// OptHumanDuration indicates that the 'humanDuration' function should be
+// enabled.  'humanDuration' formats a time.Duration, or a number of seconds,
+// using its two most significant units, e.g., 3d4h, 2h5m or 12.5s.
+func OptHumanDuration(c *Config) {
+	if _, ok := c.funcMap["humanDuration"]; ok {
+		return
 	}
-	return names
+	c.funcMap["humanDuration"] = humanDuration
+	c.funcHelp = append(c.funcHelp,
+		funcHelpInfo{"humanDuration", helpHumanDuration, helpHumanDurationIndex})
 }
 
-// TemplateFunctionHelpSingle returns help for a single function specified by
-// name.  An error is returned if the function cannot be found.
-func TemplateFunctionHelpSingle(name string, c *Config) (string, error) {
-	helpers := getHelpers(c)
-	for i := range helpers {
-		if helpers[i].name == name {
-			return helpers[i].help, nil
-		}
+const helpSince = `- 'since' returns the time.Duration that has elapsed since a given time.Time,
+  e.g.,
+
+  {{humanDuration (since .LastTrade)}}
+`
+
+// OptSince indicates that the 'since' function should be enabled.
+// 'since' returns the time.Duration that has elapsed since a given time.Time,
+// e.g.,
+//
+//  {{humanDuration (since .LastTrade)}}
+func OptSince(c *Config) {
+	if _, ok := c.funcMap["since"]; ok {
+		return
 	}
-	return "", fmt.Errorf("%s is not defined", name)
+	c.funcMap["since"] = since
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"since", helpSince, helpSinceIndex})
 }
 
-// OutputToTemplate executes the template, whose source is contained within the
-// tmplSrc parameter, on the object obj.  The name of the template is given by
-// the name parameter.  The results of the execution are output to w.
-// The functions enabled in the cfg parameter will be made available to the
-// template source code specified in tmplSrc.  If cfg is nil, all the
-// additional functions provided by tfortools will be enabled.
-func OutputToTemplate(w io.Writer, name, tmplSrc string, obj interface{}, cfg *Config) (err error) {
-	t, err := template.New(name).Funcs(getFuncMap(cfg)).Parse(tmplSrc)
-	if err != nil {
-		return err
+const helpAgo = `- 'ago' outputs the time that has elapsed since a given time.Time in a human
+  readable form, e.g., {{ago .LastTrade}} might output 3h10m ago.
+`
+
+// OptAgo indicates that the 'ago' function should be enabled.
+// 'ago' outputs the time that has elapsed since a given time.Time in a human
+// readable form, e.g., {{ago .LastTrade}} might output 3h10m ago.
+func OptAgo(c *Config) {
+	if _, ok := c.funcMap["ago"]; ok {
+		return
 	}
-	if err = t.Execute(w, obj); err != nil {
-		return err
+	c.funcMap["ago"] = ago
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"ago", helpAgo, helpAgoIndex})
+}
+
+const helpSINumber = `- 'siNumber' formats a number using SI prefixes, e.g.,
+
+  {{siNumber 6395624278}}
+
+  outputs 6.4G.
+`
+
+// OptSINumber indicates that the 'siNumber' function should be enabled.
+// 'siNumber' formats a number using SI prefixes, e.g.,
+//
+//  {{siNumber 6395624278}}
+//
+// outputs 6.4G.
+func OptSINumber(c *Config) {
+	if _, ok := c.funcMap["siNumber"]; ok {
+		return
 	}
-	return nil
+	c.funcMap["siNumber"] = siNumber
+	c.funcHelp = append(c.funcHelp,
+		funcHelpInfo{"siNumber", helpSINumber, helpSINumberIndex})
 }
 
-// CreateTemplate creates a new template, whose source is contained within the
-// tmplSrc parameter and whose name is given by the name parameter. The functions
-// enabled in the cfg parameter will be made available to the template source code
-// specified in tmplSrc.  If cfg is nil, all the additional functions provided by
-// tfortools will be enabled.
-func CreateTemplate(name, tmplSrc string, cfg *Config) (*template.Template, error) {
-	if tmplSrc == "" {
-		return nil, fmt.Errorf("template %s contains no source", name)
+const helpThousands = `- 'thousands' formats a number using commas as thousands separators, e.g.,
+
+  {{thousands 6395624278}}
+
+  outputs 6,395,624,278.
+`
+
+// OptThousands indicates that the 'thousands' function should be enabled.
+// 'thousands' formats a number using commas as thousands separators, e.g.,
+//
+//  {{thousands 6395624278}}
+//
+// outputs 6,395,624,278.
+func OptThousands(c *Config) {
+	if _, ok := c.funcMap["thousands"]; ok {
+		return
+	}
+	c.funcMap["thousands"] = thousands
+	c.funcHelp = append(c.funcHelp,
+		funcHelpInfo{"thousands", helpThousands, helpThousandsIndex})
+}
+
+// OptAllHumanUnits is a convenience function that enables the following
+// functions; 'humanBytes', 'humanDuration', 'since', 'ago', 'siNumber' and
+// 'thousands'
+func OptAllHumanUnits(c *Config) {
+	OptHumanBytes(c)
+	OptHumanDuration(c)
+	OptSince(c)
+	OptAgo(c)
+	OptSINumber(c)
+	OptThousands(c)
+}
+
+const helpUpper = `- 'upper' converts a string to upper case, e.g., {{upper .Name}}
+`
+
+// OptUpper indicates that the 'upper' function should be enabled.
+// 'upper' converts a string to upper case, e.g., {{upper .Name}}
+func OptUpper(c *Config) {
+	if _, ok := c.funcMap["upper"]; ok {
+		return
+	}
+	c.funcMap["upper"] = strings.ToUpper
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"upper", helpUpper, helpUpperIndex})
+}
+
+const helpLower = `- 'lower' converts a string to lower case, e.g., {{lower .Name}}
+`
+
+// OptLower indicates that the 'lower' function should be enabled.
+// 'lower' converts a string to lower case, e.g., {{lower .Name}}
+func OptLower(c *Config) {
+	if _, ok := c.funcMap["lower"]; ok {
+		return
+	}
+	c.funcMap["lower"] = strings.ToLower
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"lower", helpLower, helpLowerIndex})
+}
+
+const helpTrim = `- 'trim' removes leading and trailing white space from a string.  It takes an
+  optional second parameter, a string containing the set of characters to
+  remove in place of white space, e.g.,
+
+  {{trim .Name "-_"}}
+
+  removes all leading and trailing hyphens and underscores from Name.
+`
+
+// OptTrim indicates that the 'trim' function should be enabled.
+// 'trim' removes leading and trailing white space from a string.  It takes an
+// optional second parameter, a string containing the set of characters to
+// remove in place of white space, e.g.,
+//
+//  {{trim .Name "-_"}}
+//
+// removes all leading and trailing hyphens and underscores from Name.
+func OptTrim(c *Config) {
+	if _, ok := c.funcMap["trim"]; ok {
+		return
+	}
+	c.funcMap["trim"] = trim
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"trim", helpTrim, helpTrimIndex})
+}
+
+const helpReplace = `- 'replace' takes three parameters, a string, old and new.  It returns a copy
+  of the string in which all occurrences of old are replaced by new, e.g.,
+
+  {{replace .Path "/" "\\"}}
+`
+
+// OptReplace indicates that the 'replace' function should be enabled.
+// 'replace' takes three parameters, a string, old and new.  It returns a copy
+// of the string in which all occurrences of old are replaced by new, e.g.,
+//
+//  {{replace .Path "/" "\\"}}
+func OptReplace(c *Config) {
+	if _, ok := c.funcMap["replace"]; ok {
+		return
+	}
+	c.funcMap["replace"] = replace
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"replace", helpReplace, helpReplaceIndex})
+}
+
+const helpSplit = `- 'split' splits a string into a slice of substrings separated by a given
+  separator, e.g.,
+
+  {{range split .Path "/"}}{{println .}}{{end}}
+
+  prints each component of Path on a separate line.
+`
+
+// OptSplit indicates that the 'split' function should be enabled.
+// 'split' splits a string into a slice of substrings separated by a given
+// separator, e.g.,
+//
+//  {{range split .Path "/"}}{{println .}}{{end}}
+//
+// prints each component of Path on a separate line.
+func OptSplit(c *Config) {
+	if _, ok := c.funcMap["split"]; ok {
+		return
+	}
+	c.funcMap["split"] = split
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"split", helpSplit, helpSplitIndex})
+}
+
+const helpJoin = `- 'join' concatenates the elements of a slice or an array into a single
+  string.  The elements are separated by the second parameter.  Elements that
+  are not strings are converted to strings before they are joined, e.g.,
+
+  {{join .Battles ", "}}
+`
+
+// OptJoin indicates that the 'join' function should be enabled.
+// 'join' concatenates the elements of a slice or an array into a single
+// string.  The elements are separated by the second parameter.  Elements that
+// are not strings are converted to strings before they are joined, e.g.,
+//
+//  {{join .Battles ", "}}
+func OptJoin(c *Config) {
+	if _, ok := c.funcMap["join"]; ok {
+		return
 	}
+	c.funcMap["join"] = join
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"join", helpJoin, helpJoinIndex})
+}
+
+const helpPadLeft = `- 'padLeft' pads a string on the left until it is at least a given number of
+  characters wide.  An optional third parameter specifies the character to
+  pad with.  Spaces are used if it is omitted, e.g.,
+
+  {{padLeft .Code 5 "0"}}
+
+  outputs the string "37" as "00037".
+`
+
+// OptPadLeft indicates that the 'padLeft' function should be enabled.
+// 'padLeft' pads a string on the left until it is at least a given number of
+// characters wide.  An optional third parameter specifies the character to
+// pad with.  Spaces are used if it is omitted, e.g.,
+//
+//  {{padLeft .Code 5 "0"}}
+//
+// outputs the string "37" as "00037".
+func OptPadLeft(c *Config) {
+	if _, ok := c.funcMap["padLeft"]; ok {
+		return
+	}
+	c.funcMap["padLeft"] = padLeft
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"padLeft", helpPadLeft, helpPadLeftIndex})
+}
+
+const helpPadRight = `- 'padRight' is similar to padLeft but it adds the padding to the end of the
+  string.
+`
 
-	return template.New(name).Funcs(getFuncMap(cfg)).Parse(tmplSrc)
+// OptPadRight indicates that the 'padRight' function should be enabled.
+// 'padRight' is similar to padLeft but it adds the padding to the end of the
+// string.
+func OptPadRight(c *Config) {
+	if _, ok := c.funcMap["padRight"]; ok {
+		return
+	}
+	c.funcMap["padRight"] = padRight
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"padRight", helpPadRight, helpPadRightIndex})
+}
+
+const helpTruncate = `- 'truncate' shortens a string to a maximum number of characters.  An
+  optional third parameter provides a suffix that is appended to strings that
+  are truncated.  The suffix counts towards the maximum length, e.g.,
+
+  {{truncate .Description 20 "..."}}
+
+  outputs at most 20 characters of Description.
+`
+
+// OptTruncate indicates that the 'truncate' function should be enabled.
+// 'truncate' shortens a string to a maximum number of characters.  An
+// optional third parameter provides a suffix that is appended to strings that
+// are truncated.  The suffix counts towards the maximum length, e.g.,
+//
+//  {{truncate .Description 20 "..."}}
+//
+// outputs at most 20 characters of Description.
+func OptTruncate(c *Config) {
+	if _, ok := c.funcMap["truncate"]; ok {
+		return
+	}
+	c.funcMap["truncate"] = truncate
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"truncate", helpTruncate, helpTruncateIndex})
+}
+
+const helpRegexReplace = `- 'regexReplace' replaces all the matches of a regular expression in a string
+  with a replacement string.  The replacement string may refer to submatches
+  using $1, $2, etc, e.g.,
+
+  {{regexReplace .Name "^(\\w+) (\\w+)$" "$2, $1"}}
+
+  swaps the first and second words of Name.
+`
+
+// OptRegexReplace indicates that the 'regexReplace' function should be enabled.
+// 'regexReplace' replaces all the matches of a regular expression in a string
+// with a replacement string.  The replacement string may refer to submatches
+// using $1, $2, etc, e.g.,
+//
+//  {{regexReplace .Name "^(\\w+) (\\w+)$" "$2, $1"}}
+//
+// swaps the first and second words of Name.
+func OptRegexReplace(c *Config) {
+	if _, ok := c.funcMap["regexReplace"]; ok {
+		return
+	}
+	c.funcMap["regexReplace"] = regexReplace
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"regexReplace", helpRegexReplace, helpRegexReplaceIndex})
+}
+
+// OptStrings is a convenience function that enables the following string
+// manipulation functions; 'upper', 'lower', 'trim', 'replace', 'split', 'join',
+// 'padLeft', 'padRight', 'truncate' and 'regexReplace'
+func OptStrings(c *Config) {
+	OptUpper(c)
+	OptLower(c)
+	OptTrim(c)
+	OptReplace(c)
+	OptSplit(c)
+	OptJoin(c)
+	OptPadLeft(c)
+	OptPadRight(c)
+	OptTruncate(c)
+	OptRegexReplace(c)
+}
+
+const helpAdd = `- 'add' returns the sum of two numbers, e.g., {{add .High .Low}}.  The
+  numbers may be of any integer or floating point type.  If either number is
+  a float, the result is a float64.  Otherwise, if both numbers are unsigned,
+  the result is a uint.  Otherwise the result is an int.  The same rules apply
+  to 'sub', 'mul', 'div', 'mod' and 'abs'.  Integer overflow is reported as
+  an error.
+`
+
+// OptAdd indicates that the 'add' function should be enabled.
+// 'add' returns the sum of two numbers, e.g., {{add .High .Low}}.  The
+// numbers may be of any integer or floating point type.  If either number is
+// a float, the result is a float64.  Otherwise, if both numbers are unsigned,
+// the result is a uint.  Otherwise the result is an int.  The same rules apply
+// to 'sub', 'mul', 'div', 'mod' and 'abs'.  Integer overflow is reported as
+// an error.
+func OptAdd(c *Config) {
+	if _, ok := c.funcMap["add"]; ok {
+		return
+	}
+	c.funcMap["add"] = add
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"add", helpAdd, helpAddIndex})
+}
+
+const helpSub = `- 'sub' subtracts its second argument from its first, e.g., {{sub .High .Low}}.
+`
+
+// OptSub indicates that the 'sub' function should be enabled.
+// 'sub' subtracts its second argument from its first, e.g., {{sub .High .Low}}.
+func OptSub(c *Config) {
+	if _, ok := c.funcMap["sub"]; ok {
+		return
+	}
+	c.funcMap["sub"] = sub
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"sub", helpSub, helpSubIndex})
+}
+
+const helpMul = `- 'mul' returns the product of two numbers, e.g., {{mul .Current .Volume}}.
+`
+
+// OptMul indicates that the 'mul' function should be enabled.
+// 'mul' returns the product of two numbers, e.g., {{mul .Current .Volume}}.
+func OptMul(c *Config) {
+	if _, ok := c.funcMap["mul"]; ok {
+		return
+	}
+	c.funcMap["mul"] = mul
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"mul", helpMul, helpMulIndex})
+}
+
+const helpDiv = `- 'div' divides its first argument by its second, e.g., {{div .Total .Count}}.
+  Integers are divided using integer division.  Division by zero is reported
+  as an error.
+`
+
+// OptDiv indicates that the 'div' function should be enabled.
+// 'div' divides its first argument by its second, e.g., {{div .Total .Count}}.
+// Integers are divided using integer division.  Division by zero is reported
+// as an error.
+func OptDiv(c *Config) {
+	if _, ok := c.funcMap["div"]; ok {
+		return
+	}
+	c.funcMap["div"] = div
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"div", helpDiv, helpDivIndex})
+}
+
+const helpMod = `- 'mod' returns the remainder of dividing its first argument by its second,
+  e.g., {{mod $i 2}}.  Division by zero is reported as an error.
+`
+
+// OptMod indicates that the 'mod' function should be enabled.
+// 'mod' returns the remainder of dividing its first argument by its second,
+// e.g., {{mod $i 2}}.  Division by zero is reported as an error.
+func OptMod(c *Config) {
+	if _, ok := c.funcMap["mod"]; ok {
+		return
+	}
+	c.funcMap["mod"] = mod
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"mod", helpMod, helpModIndex})
+}
+
+const helpRound = `- 'round' rounds a number to the nearest integer, returning a float64, e.g.,
+  {{round .Current}}.  An optional second argument specifies the number of
+  decimal places to round to, e.g., {{round .Current 2}}.
+`
+
+// OptRound indicates that the 'round' function should be enabled.
+// 'round' rounds a number to the nearest integer, returning a float64, e.g.,
+// {{round .Current}}.  An optional second argument specifies the number of
+// decimal places to round to, e.g., {{round .Current 2}}.
+func OptRound(c *Config) {
+	if _, ok := c.funcMap["round"]; ok {
+		return
+	}
+	c.funcMap["round"] = round
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"round", helpRound, helpRoundIndex})
+}
+
+const helpFloor = `- 'floor' returns the greatest integer value less than or equal to a number
+  as a float64, e.g., {{floor .Current}}.
+`
+
+// OptFloor indicates that the 'floor' function should be enabled.
+// 'floor' returns the greatest integer value less than or equal to a number
+// as a float64, e.g., {{floor .Current}}.
+func OptFloor(c *Config) {
+	if _, ok := c.funcMap["floor"]; ok {
+		return
+	}
+	c.funcMap["floor"] = floor
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"floor", helpFloor, helpFloorIndex})
+}
+
+const helpCeil = `- 'ceil' returns the least integer value greater than or equal to a number
+  as a float64, e.g., {{ceil .Current}}.
+`
+
+// OptCeil indicates that the 'ceil' function should be enabled.
+// 'ceil' returns the least integer value greater than or equal to a number
+// as a float64, e.g., {{ceil .Current}}.
+func OptCeil(c *Config) {
+	if _, ok := c.funcMap["ceil"]; ok {
+		return
+	}
+	c.funcMap["ceil"] = ceil
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"ceil", helpCeil, helpCeilIndex})
+}
+
+const helpAbs = `- 'abs' returns the absolute value of a number, e.g., {{abs (sub .Low .High)}}.
+`
+
+// OptAbs indicates that the 'abs' function should be enabled.
+// 'abs' returns the absolute value of a number, e.g., {{abs (sub .Low .High)}}.
+func OptAbs(c *Config) {
+	if _, ok := c.funcMap["abs"]; ok {
+		return
+	}
+	c.funcMap["abs"] = abs
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"abs", helpAbs, helpAbsIndex})
+}
+
+const helpPercent = `- 'percent' expresses its first argument as a percentage of its second,
+  returning a float64, e.g.,
+
+  {{printf "%.1f%%" (percent (sub .Current .Low) .Low)}}
+
+  outputs the percentage by which Current exceeds Low.  Division by zero is
+  reported as an error.
+`
+
+// OptPercent indicates that the 'percent' function should be enabled.
+// 'percent' expresses its first argument as a percentage of its second,
+// returning a float64, e.g.,
+//
+//  {{printf "%.1f%%" (percent (sub .Current .Low) .Low)}}
+//
+// outputs the percentage by which Current exceeds Low.  Division by zero is
+// reported as an error.
+func OptPercent(c *Config) {
+	if _, ok := c.funcMap["percent"]; ok {
+		return
+	}
+	c.funcMap["percent"] = percent
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"percent", helpPercent, helpPercentIndex})
+}
+
+// OptMath is a convenience function that enables the following arithmetic
+// functions; 'add', 'sub', 'mul', 'div', 'mod', 'round', 'floor', 'ceil',
+// 'abs' and 'percent'
+func OptMath(c *Config) {
+	OptAdd(c)
+	OptSub(c)
+	OptMul(c)
+	OptDiv(c)
+	OptMod(c)
+	OptRound(c)
+	OptFloor(c)
+	OptCeil(c)
+	OptAbs(c)
+	OptPercent(c)
+}
+
+const helpNow = `- 'now' returns the current time as a time.Time, e.g., {{formatTime now "date"}}.
+  The current time can be frozen using the OptFrozenNow option, in which case
+  'since' and 'ago' also use the frozen time.
+`
+
+// OptNow indicates that the 'now' function should be enabled.
+// 'now' returns the current time as a time.Time, e.g., {{formatTime now "date"}}.
+// The current time can be frozen using the OptFrozenNow option, in which case
+// 'since' and 'ago' also use the frozen time.
+func OptNow(c *Config) {
+	if _, ok := c.funcMap["now"]; ok {
+		return
+	}
+	c.funcMap["now"] = now
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"now", helpNow, helpNowIndex})
+}
+
+const helpFormatTime = `- 'formatTime' formats a time.Time using a time layout, e.g.,
+
+  {{formatTime .LastTrade "Mon Jan 2 15:04"}}
+
+  The layout may also be one of the following names; rfc3339, rfc3339nano,
+  rfc1123, rfc822, ansic, unix, kitchen, stamp, date, time and datetime.  These
+  names can also be passed to formatCol.  If the layout is omitted, rfc3339
+  is used.
+`
+
+// OptFormatTime indicates that the 'formatTime' function should be enabled.
+// 'formatTime' formats a time.Time using a time layout, e.g.,
+//
+//  {{formatTime .LastTrade "Mon Jan 2 15:04"}}
+//
+// The layout may also be one of the following names; rfc3339, rfc3339nano,
+// rfc1123, rfc822, ansic, unix, kitchen, stamp, date, time and datetime.  These
+// names can also be passed to formatCol.  If the layout is omitted, rfc3339
+// is used.
+func OptFormatTime(c *Config) {
+	if _, ok := c.funcMap["formatTime"]; ok {
+		return
+	}
+	c.funcMap["formatTime"] = formatTime
+	c.funcHelp = append(c.funcHelp,
+		funcHelpInfo{"formatTime", helpFormatTime, helpFormatTimeIndex})
+}
+
+const helpParseTime = `- 'parseTime' parses a string into a time.Time.  It accepts an optional
+  layout, which may be one of the names accepted by 'formatTime', e.g.,
+
+  {{since (parseTime "2017-03-17" "date")}}
+
+  If the layout is omitted, rfc3339 is used.
+`
+
+// OptParseTime indicates that the 'parseTime' function should be enabled.
+// 'parseTime' parses a string into a time.Time.  It accepts an optional
+// layout, which may be one of the names accepted by 'formatTime', e.g.,
+//
+//  {{since (parseTime "2017-03-17" "date")}}
+//
+// If the layout is omitted, rfc3339 is used.
+func OptParseTime(c *Config) {
+	if _, ok := c.funcMap["parseTime"]; ok {
+		return
+	}
+	c.funcMap["parseTime"] = parseTime
+	c.funcHelp = append(c.funcHelp,
+		funcHelpInfo{"parseTime", helpParseTime, helpParseTimeIndex})
+}
+
+const helpTimeTrunc = `- 'timeTrunc' rounds a time.Time down to the start of a given unit, which may
+  be "year", "month", "day", "hour", "minute", "second" or a duration such
+  as "15m", e.g.,
+
+  {{timeTrunc .LastTrade "day"}}
+
+  outputs midnight on the day of the last trade.
+`
+
+// OptTimeTrunc indicates that the 'timeTrunc' function should be enabled.
+// 'timeTrunc' rounds a time.Time down to the start of a given unit, which may
+// be "year", "month", "day", "hour", "minute", "second" or a duration such
+// as "15m", e.g.,
+//
+//  {{timeTrunc .LastTrade "day"}}
+//
+// outputs midnight on the day of the last trade.
+func OptTimeTrunc(c *Config) {
+	if _, ok := c.funcMap["timeTrunc"]; ok {
+		return
+	}
+	c.funcMap["timeTrunc"] = timeTrunc
+	c.funcHelp = append(c.funcHelp,
+		funcHelpInfo{"timeTrunc", helpTimeTrunc, helpTimeTruncIndex})
+}
+
+const helpAddDuration = `- 'addDuration' adds a duration to a time.Time.  The duration may be a
+  time.Duration, a number of seconds or a string such as "-1h30m", e.g.,
+
+  {{addDuration .LastTrade "24h"}}
+`
+
+// OptAddDuration indicates that the 'addDuration' function should be enabled.
+// 'addDuration' adds a duration to a time.Time.  The duration may be a
+// time.Duration, a number of seconds or a string such as "-1h30m", e.g.,
+//
+//  {{addDuration .LastTrade "24h"}}
+func OptAddDuration(c *Config) {
+	if _, ok := c.funcMap["addDuration"]; ok {
+		return
+	}
+	c.funcMap["addDuration"] = addDuration
+	c.funcHelp = append(c.funcHelp,
+		funcHelpInfo{"addDuration", helpAddDuration, helpAddDurationIndex})
+}
+
+const helpInZone = `- 'inZone' converts a time.Time to a given time zone, e.g.,
+
+  {{formatTime (inZone .LastTrade "America/New_York") "kitchen"}}
+
+  The zone may also be "UTC" or "Local".  A copy of the time zone database is
+  built into the program in case the host does not provide one.
+`
+
+// OptInZone indicates that the 'inZone' function should be enabled.
+// 'inZone' converts a time.Time to a given time zone, e.g.,
+//
+//  {{formatTime (inZone .LastTrade "America/New_York") "kitchen"}}
+//
+// The zone may also be "UTC" or "Local".  A copy of the time zone database is
+// built into the program in case the host does not provide one.  This
+// requires Go 1.15 or later.
+func OptInZone(c *Config) {
+	if _, ok := c.funcMap["inZone"]; ok {
+		return
+	}
+	c.funcMap["inZone"] = inZone
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"inZone", helpInZone, helpInZoneIndex})
+}
+
+// OptTime is a convenience function that enables the following date and time
+// functions; 'now', 'formatTime', 'parseTime', 'timeTrunc', 'addDuration',
+// 'inZone', 'since' and 'ago'
+func OptTime(c *Config) {
+	OptNow(c)
+	OptFormatTime(c)
+	OptParseTime(c)
+	OptTimeTrunc(c)
+	OptAddDuration(c)
+	OptInZone(c)
+	OptSince(c)
+	OptAgo(c)
+}
+
+const helpList = `- 'list' returns a slice containing its arguments, e.g.,
+
+  {{range list "a" "b" "c"}}{{println .}}{{end}}
+`
+
+// OptList indicates that the 'list' function should be enabled.
+// 'list' returns a slice containing its arguments, e.g.,
+//
+//  {{range list "a" "b" "c"}}{{println .}}{{end}}
+func OptList(c *Config) {
+	if _, ok := c.funcMap["list"]; ok {
+		return
+	}
+	c.funcMap["list"] = list
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"list", helpList, helpListIndex})
+}
+
+const helpDict = `- 'dict' returns a map created from a list of key and value pairs.  The keys
+  must be strings.  Lists of maps created by 'dict' can be passed to table
+  and the other functions that accept slices of maps, e.g.,
+
+  {{table (list (dict "Name" "Alice" "Age" 33) (dict "Name" "Bob" "Age" 28))}}
+`
+
+// OptDict indicates that the 'dict' function should be enabled.
+// 'dict' returns a map created from a list of key and value pairs.  The keys
+// must be strings.  Lists of maps created by 'dict' can be passed to table
+// and the other functions that accept slices of maps, e.g.,
+//
+//  {{table (list (dict "Name" "Alice" "Age" 33) (dict "Name" "Bob" "Age" 28))}}
+func OptDict(c *Config) {
+	if _, ok := c.funcMap["dict"]; ok {
+		return
+	}
+	c.funcMap["dict"] = dict
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"dict", helpDict, helpDictIndex})
+}
+
+const helpSeq = `- 'seq' returns a slice of integers.  It takes up to three arguments, start,
+  end and step.  The slice starts at start, which defaults to 0, and
+  increments by step, which defaults to 1, stopping before end is reached.
+  If only one argument is provided it is treated as end.  For example,
+
+  {{rows . (seq 0 10 2)}}
+
+  returns the 1st, 3rd, 5th, 7th and 9th rows of '.'.
+`
+
+// OptSeq indicates that the 'seq' function should be enabled.
+// 'seq' returns a slice of integers.  It takes up to three arguments, start,
+// end and step.  The slice starts at start, which defaults to 0, and
+// increments by step, which defaults to 1, stopping before end is reached.
+// If only one argument is provided it is treated as end.  For example,
+//
+//  {{rows . (seq 0 10 2)}}
+//
+// returns the 1st, 3rd, 5th, 7th and 9th rows of '.'.
+func OptSeq(c *Config) {
+	if _, ok := c.funcMap["seq"]; ok {
+		return
+	}
+	c.funcMap["seq"] = seq
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"seq", helpSeq, helpSeqIndex})
+}
+
+const helpDefault = `- 'default' returns its first argument unless it is empty, in which case its
+  second argument is returned.  Values are empty if they would be considered
+  false by the if action, e.g., {{default .Name "unknown"}}.
+`
+
+// OptDefault indicates that the 'default' function should be enabled.
+// 'default' returns its first argument unless it is empty, in which case its
+// second argument is returned.  Values are empty if they would be considered
+// false by the if action, e.g., {{default .Name "unknown"}}.
+func OptDefault(c *Config) {
+	if _, ok := c.funcMap["default"]; ok {
+		return
+	}
+	c.funcMap["default"] = defaultValue
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"default", helpDefault, helpDefaultIndex})
+}
+
+const helpCoalesce = `- 'coalesce' returns the first of its arguments that is not empty, or nil if
+  they are all empty, e.g., {{coalesce .Nickname .Name "unknown"}}.
+`
+
+// OptCoalesce indicates that the 'coalesce' function should be enabled.
+// 'coalesce' returns the first of its arguments that is not empty, or nil if
+// they are all empty, e.g., {{coalesce .Nickname .Name "unknown"}}.
+func OptCoalesce(c *Config) {
+	if _, ok := c.funcMap["coalesce"]; ok {
+		return
+	}
+	c.funcMap["coalesce"] = coalesce
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"coalesce", helpCoalesce, helpCoalesceIndex})
+}
+
+const helpTernary = `- 'ternary' returns its second argument if its first argument is not empty
+  and its third argument otherwise, e.g., {{ternary .Running "up" "down"}}.
+`
+
+// OptTernary indicates that the 'ternary' function should be enabled.
+// 'ternary' returns its second argument if its first argument is not empty
+// and its third argument otherwise, e.g., {{ternary .Running "up" "down"}}.
+func OptTernary(c *Config) {
+	if _, ok := c.funcMap["ternary"]; ok {
+		return
+	}
+	c.funcMap["ternary"] = ternary
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"ternary", helpTernary, helpTernaryIndex})
+}
+
+// OptBuilders is a convenience function that enables the following functions
+// that build values; 'list', 'dict', 'seq', 'default', 'coalesce' and
+// 'ternary'
+func OptBuilders(c *Config) {
+	OptList(c)
+	OptDict(c)
+	OptSeq(c)
+	OptDefault(c)
+	OptCoalesce(c)
+	OptTernary(c)
+}
+
+const helpUnion = `- 'union' takes two slices or arrays of the same type and the name of a
+  field, which may be a dotted path, on which to compare their elements.  It
+  returns a new slice containing the elements of the first slice followed by
+  the elements of the second slice whose field values do not appear in the
+  first.  Only the first element with a given value is retained.  For
+  example,
+
+  {{table (union .Desired .Actual "Host.Name")}}
+
+  outputs all the hosts that appear in either .Desired or .Actual.
+`
+
+// OptUnion indicates that the 'union' function should be enabled.
+// 'union' takes two slices or arrays of the same type and the name of a
+// field, which may be a dotted path, on which to compare their elements.  It
+// returns a new slice containing the elements of the first slice followed by
+// the elements of the second slice whose field values do not appear in the
+// first.  Only the first element with a given value is retained.  For
+// example,
+//
+//  {{table (union .Desired .Actual "Host.Name")}}
+//
+// outputs all the hosts that appear in either .Desired or .Actual.
+func OptUnion(c *Config) {
+	if _, ok := c.funcMap["union"]; ok {
+		return
+	}
+	c.funcMap["union"] = union
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"union", helpUnion, helpUnionIndex})
+}
+
+const helpIntersect = `- 'intersect' is similar to union except that it returns a slice containing
+  the elements of the first slice whose field values also appear in the
+  second.  For example,
+
+  {{table (intersect .Desired .Actual "Host.Name")}}
+
+  outputs the desired hosts that are actually present.
+`
+
+// OptIntersect indicates that the 'intersect' function should be enabled.
+// 'intersect' is similar to union except that it returns a slice containing
+// the elements of the first slice whose field values also appear in the
+// second.  For example,
+//
+//  {{table (intersect .Desired .Actual "Host.Name")}}
+//
+// outputs the desired hosts that are actually present.
+func OptIntersect(c *Config) {
+	if _, ok := c.funcMap["intersect"]; ok {
+		return
+	}
+	c.funcMap["intersect"] = intersect
+	c.funcHelp = append(c.funcHelp,
+		funcHelpInfo{"intersect", helpIntersect, helpIntersectIndex})
+}
+
+const helpDifference = `- 'difference' is similar to union except that it returns a slice containing
+  the elements of the first slice whose field values do not appear in the
+  second.  For example,
+
+  {{table (difference .Desired .Actual "Host.Name")}}
+
+  outputs the desired hosts that are missing.
+`
+
+// OptDifference indicates that the 'difference' function should be enabled.
+// 'difference' is similar to union except that it returns a slice containing
+// the elements of the first slice whose field values do not appear in the
+// second.  For example,
+//
+//  {{table (difference .Desired .Actual "Host.Name")}}
+//
+// outputs the desired hosts that are missing.
+func OptDifference(c *Config) {
+	if _, ok := c.funcMap["difference"]; ok {
+		return
+	}
+	c.funcMap["difference"] = difference
+	c.funcHelp = append(c.funcHelp,
+		funcHelpInfo{"difference", helpDifference, helpDifferenceIndex})
+}
+
+// OptSets is a convenience function that enables the following set
+// functions; 'union', 'intersect' and 'difference'
+func OptSets(c *Config) {
+	OptUnion(c)
+	OptIntersect(c)
+	OptDifference(c)
+}
+
+const helpReverse = `- 'reverse' operates on a slice or an array, returning a new slice containing
+  the same elements in reverse order.  For example,
+
+  {{ head (reverse .) 2}}
+
+  returns a slice containing the last two elements of '.', last element first.
+`
+
+// OptReverse indicates that the 'reverse' function should be enabled.
+// 'reverse' operates on a slice or an array, returning a new slice containing
+// the same elements in reverse order.  For example,
+//
+//  {{ head (reverse .) 2}}
+//
+// returns a slice containing the last two elements of '.', last element first.
+func OptReverse(c *Config) {
+	if _, ok := c.funcMap["reverse"]; ok {
+		return
+	}
+	c.funcMap["reverse"] = reverse
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"reverse", helpReverse, helpReverseIndex})
+}
+
+const helpSkip = `- 'skip' is the complement of head.  It returns a slice containing all but the
+  first n elements of the input slice.  If n is not provided, the first
+  element is skipped.  For example,
+
+  {{ skip . 2}}
+
+  returns a slice containing all but the first two elements of '.'.
+`
+
+// OptSkip indicates that the 'skip' function should be enabled.
+// 'skip' is the complement of head.  It returns a slice containing all but the
+// first n elements of the input slice.  If n is not provided, the first
+// element is skipped.  For example,
+//
+//  {{ skip . 2}}
+//
+// returns a slice containing all but the first two elements of '.'.
+func OptSkip(c *Config) {
+	if _, ok := c.funcMap["skip"]; ok {
+		return
+	}
+	c.funcMap["skip"] = skip
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"skip", helpSkip, helpSkipIndex})
+}
+
+const helpSubslice = `- 'subslice' returns a new slice containing the elements of a slice or an
+  array from index lo up to, but not including, index hi.  If hi is not
+  provided, the new slice extends to the end of the input slice.  Negative
+  indices are counted from the end of the slice and indices that are out of
+  range are clamped.  For example,
+
+  {{ subslice . 1 -1}}
+
+  returns a slice containing all but the first and last elements of '.'.
+  Unlike the builtin 'slice' function, 'subslice' never fails because of an
+  out of range index.  It can also be applied to strings.
+`
+
+// OptSubslice indicates that the 'subslice' function should be enabled.
+// 'subslice' returns a new slice containing the elements of a slice or an
+// array from index lo up to, but not including, index hi.  If hi is not
+// provided, the new slice extends to the end of the input slice.  Negative
+// indices are counted from the end of the slice and indices that are out of
+// range are clamped.  For example,
+//
+//  {{ subslice . 1 -1}}
+//
+// returns a slice containing all but the first and last elements of '.'.
+// Unlike the builtin 'slice' function, 'subslice' never fails because of an
+// out of range index.  It can also be applied to strings.
+func OptSubslice(c *Config) {
+	if _, ok := c.funcMap["subslice"]; ok {
+		return
+	}
+	c.funcMap["subslice"] = subslice
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"subslice", helpSubslice, helpSubsliceIndex})
+}
+
+const helpChunk = `- 'chunk' splits a slice or an array into a slice of slices, each of which
+  contains n elements, apart from the last which may contain fewer.  It can
+  be used to paginate output.  For example,
+
+  {{ table (index (chunk . 10) 2)}}
+
+  outputs the 21st to the 30th elements of '.'.
+`
+
+// OptChunk indicates that the 'chunk' function should be enabled.
+// 'chunk' splits a slice or an array into a slice of slices, each of which
+// contains n elements, apart from the last which may contain fewer.  It can
+// be used to paginate output.  For example,
+//
+//  {{ table (index (chunk . 10) 2)}}
+//
+// outputs the 21st to the 30th elements of '.'.
+func OptChunk(c *Config) {
+	if _, ok := c.funcMap["chunk"]; ok {
+		return
+	}
+	c.funcMap["chunk"] = chunk
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"chunk", helpChunk, helpChunkIndex})
+}
+
+const helpFlattenSlices = `- 'flattenSlices' takes a slice of slices and returns a single slice
+  containing all of their elements.  For example,
+
+  {{ flattenSlices (chunk . 10)}}
+
+  returns a slice containing the same elements as '.'.
+`
+
+// OptFlattenSlices indicates that the 'flattenSlices' function should be
+// enabled.  'flattenSlices' takes a slice of slices and returns a single slice
+// containing all of their elements.  For example,
+//
+//  {{ flattenSlices (chunk . 10)}}
+//
+// returns a slice containing the same elements as '.'.
+func OptFlattenSlices(c *Config) {
+	if _, ok := c.funcMap["flattenSlices"]; ok {
+		return
+	}
+	c.funcMap["flattenSlices"] = flattenSlices
+	c.funcHelp = append(c.funcHelp,
+		funcHelpInfo{"flattenSlices", helpFlattenSlices, helpFlattenSlicesIndex})
+}
+
+const helpZip = `- 'zip' takes two or more slices or arrays and returns a slice of structures.
+  The nth structure contains the nth element of each input slice in fields
+  named Item1, Item2, etc.  The returned slice is as long as the shortest
+  input slice.  For example,
+
+  {{ table (zip .Names .Ages)}}
+
+  outputs a table whose rows pair each name with an age.
+`
+
+// OptZip indicates that the 'zip' function should be enabled.
+// 'zip' takes two or more slices or arrays and returns a slice of structures.
+// The nth structure contains the nth element of each input slice in fields
+// named Item1, Item2, etc.  The returned slice is as long as the shortest
+// input slice.  For example,
+//
+//  {{ table (zip .Names .Ages)}}
+//
+// outputs a table whose rows pair each name with an age.
+func OptZip(c *Config) {
+	if _, ok := c.funcMap["zip"]; ok {
+		return
+	}
+	c.funcMap["zip"] = zip
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"zip", helpZip, helpZipIndex})
+}
+
+const helpShuffle = `- 'shuffle' operates on a slice or an array, returning a new slice containing
+  the same elements in a random order.  An optional seed may be provided, in
+  which case the same order is returned each time the template is executed.
+  For example,
+
+  {{ table (shuffle . 42)}}
+`
+
+// OptShuffle indicates that the 'shuffle' function should be enabled.
+// 'shuffle' operates on a slice or an array, returning a new slice containing
+// the same elements in a random order.  An optional seed may be provided, in
+// which case the same order is returned each time the template is executed.
+// For example,
+//
+//  {{ table (shuffle . 42)}}
+func OptShuffle(c *Config) {
+	if _, ok := c.funcMap["shuffle"]; ok {
+		return
+	}
+	c.funcMap["shuffle"] = shuffle
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"shuffle", helpShuffle, helpShuffleIndex})
+}
+
+const helpSample = `- 'sample' operates on a slice or an array, returning a new slice containing
+  n elements chosen at random.  The elements appear in the same order as in
+  the input slice.  An optional seed may be provided, in which case the same
+  elements are chosen each time the template is executed.  For example,
+
+  {{ table (sample . 20 42)}}
+
+  outputs 20 random rows of '.' using the seed 42.  If '.' contains fewer than
+  20 elements, all of them are returned.
+`
+
+// OptSample indicates that the 'sample' function should be enabled.
+// 'sample' operates on a slice or an array, returning a new slice containing
+// n elements chosen at random.  The elements appear in the same order as in
+// the input slice.  An optional seed may be provided, in which case the same
+// elements are chosen each time the template is executed.  For example,
+//
+//  {{ table (sample . 20 42)}}
+//
+// outputs 20 random rows of '.' using the seed 42.  If '.' contains fewer than
+// 20 elements, all of them are returned.
+func OptSample(c *Config) {
+	if _, ok := c.funcMap["sample"]; ok {
+		return
+	}
+	c.funcMap["sample"] = sample
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"sample", helpSample, helpSampleIndex})
+}
+
+// OptReshape is a convenience function that enables the following functions
+// that reshape slices; 'reverse', 'skip', 'subslice', 'chunk',
+// 'flattenSlices', 'zip', 'shuffle' and 'sample'
+func OptReshape(c *Config) {
+	OptReverse(c)
+	OptSkip(c)
+	OptSubslice(c)
+	OptChunk(c)
+	OptFlattenSlices(c)
+	OptZip(c)
+	OptShuffle(c)
+	OptSample(c)
+}
+
+const helpEnumerate = `- 'enumerate' operates on a slice or an array of structures or maps.  It
+  returns a new slice in which each element contains an additional integer
+  field, whose name is given by the second parameter, holding the 1 based
+  position of the element in the slice.  For structures, the new field is
+  placed before the existing fields.  For example,
+
+  {{table (enumerate (sort . "Volume" "dsc") "Position")}}
+
+  outputs a table sorted by Volume whose first column, Position, numbers the
+  rows.
+`
+
+// OptEnumerate indicates that the 'enumerate' function should be enabled.
+// 'enumerate' operates on a slice or an array of structures or maps.  It
+// returns a new slice in which each element contains an additional integer
+// field, whose name is given by the second parameter, holding the 1 based
+// position of the element in the slice.  For structures, the new field is
+// placed before the existing fields.  For example,
+//
+//  {{table (enumerate (sort . "Volume" "dsc") "Position")}}
+//
+// outputs a table sorted by Volume whose first column, Position, numbers the
+// rows.
+func OptEnumerate(c *Config) {
+	if _, ok := c.funcMap["enumerate"]; ok {
+		return
+	}
+	c.funcMap["enumerate"] = enumerate
+	c.funcHelp = append(c.funcHelp,
+		funcHelpInfo{"enumerate", helpEnumerate, helpEnumerateIndex})
+}
+
+const helpRank = `- 'rank' sorts a slice or an array of structures or maps by a given field,
+  in the same way as sort, and adds an integer field called Rank to each
+  element.  Elements with equal values share the same rank.  By default
+  competition ranking is used, e.g., 1, 2, 2, 4.  Passing "dense" as an
+  additional parameter selects dense ranking, e.g., 1, 2, 2, 3.  For example,
+
+  {{table (rank . "Volume" "dsc" "dense")}}
+
+  outputs a table of '.' ranked by descending Volume.
+`
+
+// OptRank indicates that the 'rank' function should be enabled.
+// 'rank' sorts a slice or an array of structures or maps by a given field,
+// in the same way as sort, and adds an integer field called Rank to each
+// element.  Elements with equal values share the same rank.  By default
+// competition ranking is used, e.g., 1, 2, 2, 4.  Passing "dense" as an
+// additional parameter selects dense ranking, e.g., 1, 2, 2, 3.  For example,
+//
+//  {{table (rank . "Volume" "dsc" "dense")}}
+//
+// outputs a table of '.' ranked by descending Volume.
+func OptRank(c *Config) {
+	if _, ok := c.funcMap["rank"]; ok {
+		return
+	}
+	c.funcMap["rank"] = rank
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"rank", helpRank, helpRankIndex})
+}
+
+// OptEnumerations is a convenience function that enables the 'enumerate' and
+// 'rank' functions
+func OptEnumerations(c *Config) {
+	OptEnumerate(c)
+	OptRank(c)
+}
+
+const helpFromJSON = `- 'fromjson' decodes a string containing JSON into a value that can be
+  operated on by the other functions.  Objects are decoded into maps and
+  arrays of objects into slices of maps, which can be passed to table, sort
+  and filter.  Integers are decoded into int64s and other numbers into
+  float64s.  For example,
+
+  {{table (sort (fromjson .Annotations) "Name")}}
+`
+
+// OptFromJSON indicates that the 'fromjson' function should be enabled.
+// 'fromjson' decodes a string containing JSON into a value that can be
+// operated on by the other functions.  Objects are decoded into maps and
+// arrays of objects into slices of maps, which can be passed to table, sort
+// and filter.  Integers are decoded into int64s and other numbers into
+// float64s.  For example,
+//
+//  {{table (sort (fromjson .Annotations) "Name")}}
+func OptFromJSON(c *Config) {
+	if _, ok := c.funcMap["fromjson"]; ok {
+		return
+	}
+	c.funcMap["fromjson"] = fromJSON
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"fromjson", helpFromJSON, helpFromJSONIndex})
+}
+
+const helpFromYAML = `- 'fromyaml' is similar to fromjson except that it decodes YAML.  Only a
+  subset of YAML is supported; block and single line flow collections, plain
+  and quoted scalars, block scalars and comments.  Anchors, aliases, tags,
+  multi-line plain scalars and multiple documents are not supported and are
+  reported as errors.  For example,
+
+  {{range (fromyaml .Metadata).hosts}}{{println .name}}{{end}}
+`
+
+// OptFromYAML indicates that the 'fromyaml' function should be enabled.
+// 'fromyaml' is similar to fromjson except that it decodes YAML.  Only a
+// subset of YAML is supported; block and single line flow collections, plain
+// and quoted scalars, block scalars and comments.  Anchors, aliases, tags,
+// multi-line plain scalars and multiple documents are not supported and are
+// reported as errors.  For example,
+//
+//  {{range (fromyaml .Metadata).hosts}}{{println .name}}{{end}}
+func OptFromYAML(c *Config) {
+	if _, ok := c.funcMap["fromyaml"]; ok {
+		return
+	}
+	c.funcMap["fromyaml"] = fromYAML
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"fromyaml", helpFromYAML, helpFromYAMLIndex})
+}
+
+const helpFromCSV = `- 'fromcsv' decodes a string containing CSV into a slice of structures in
+  the same way as totable.  It accepts the same options as totable.  For
+  example,
+
+  {{table (fromcsv .Report "sample=100")}}
+`
+
+// OptFromCSV indicates that the 'fromcsv' function should be enabled.
+// 'fromcsv' decodes a string containing CSV into a slice of structures in
+// the same way as totable.  It accepts the same options as totable.  For
+// example,
+//
+//  {{table (fromcsv .Report "sample=100")}}
+func OptFromCSV(c *Config) {
+	if _, ok := c.funcMap["fromcsv"]; ok {
+		return
+	}
+	c.funcMap["fromcsv"] = fromCSV
+	c.funcHelp = append(c.funcHelp, funcHelpInfo{"fromcsv", helpFromCSV, helpFromCSVIndex})
+}
+
+// OptDecoders is a convenience function that enables the following functions
+// that decode strings; 'fromjson', 'fromyaml' and 'fromcsv'
+func OptDecoders(c *Config) {
+	OptFromJSON(c)
+	OptFromYAML(c)
+	OptFromCSV(c)
+}
+
+// NewConfig creates a new Config object that can be passed to other functions
+// in this package.  The Config option keeps track of which new functions are
+// added to Go's template libray.  If this function is called without arguments,
+// none of the functions defined in this package are enabled in the resulting Config
+// object.  To control which functions get added specify some options, e.g.,
+//
+//  ctx := tfortools.NewConfig(tfortools.OptHead, tfortools.OptTail)
+//
+// creates a new Config object that enables the 'head' and 'tail' functions only.
+//
+// To add all the functions, use the OptAllFNs options, e.g.,
+//
+//    ctx := tfortools.NewConfig(tfortools.OptAllFNs)
+func NewConfig(options ...func(*Config)) *Config {
+	c := &Config{
+		funcMap:        make(template.FuncMap),
+		nilPlaceholder: defaultNilPlaceholder,
+	}
+	for _, f := range options {
+		f(c)
+	}
+	sort.Sort(c)
+
+	return c
+}
+
+// TemplateFunctionHelp generates formatted documentation that describes the
+// additional functions that the Config object c adds to Go's templating language.
+// If c is nil, documentation is generated for all functions provided by
+// tfortools.
+func TemplateFunctionHelp(c *Config) string {
+	b := &bytes.Buffer{}
+	_, _ = b.WriteString("Some new functions have been added to Go's template language\n\n")
+
+	for _, h := range getHelpers(c) {
+		_, _ = b.WriteString(h.help)
+	}
+	return b.String()
+}
+
+// TemplateFunctionNames returns a slice of all the functions enabled in the
+// supplied Config object.
+func TemplateFunctionNames(c *Config) []string {
+	helpers := getHelpers(c)
+	names := make([]string, len(helpers))
+	for i := range helpers {
+		names[i] = helpers[i].name
+	}
+	return names
+}
+
+// TemplateFunctionHelpSingle returns help for a single function specified by
+// name.  An error is returned if the function cannot be found.
+func TemplateFunctionHelpSingle(name string, c *Config) (string, error) {
+	helpers := getHelpers(c)
+	for i := range helpers {
+		if helpers[i].name == name {
+			return helpers[i].help, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not defined", name)
+}
+
+// OutputToTemplate executes the template, whose source is contained within the
+// tmplSrc parameter, on the object obj.  The name of the template is given by
+// the name parameter.  The results of the execution are output to w.
+// The functions enabled in the cfg parameter will be made available to the
+// template source code specified in tmplSrc.  If cfg is nil, all the
+// additional functions provided by tfortools will be enabled.  If one of
+// these functions fails, the error returned wraps an *Error that describes
+// the failure.  If the execution of the template exceeds one of the limits
+// set in cfg, a *LimitError is returned.
+func OutputToTemplate(w io.Writer, name, tmplSrc string, obj interface{}, cfg *Config) (err error) {
+	return executeTemplate(nil, w, name, tmplSrc, obj, cfg)
+}
+
+// executeTemplate implements OutputToTemplate and OutputToTemplateContext.
+// ctx is nil if the execution cannot be cancelled.
+func executeTemplate(ctx context.Context, w io.Writer, name, tmplSrc string, obj interface{},
+	cfg *Config) error {
+	e := newEnv(cfg)
+	if ctx != nil && ctx.Done() != nil {
+		e.ctx = ctx
+		w = contextWriter{ctx, w}
+	}
+
+	funcs := e.bindFuncs(cfg)
+	t, err := template.New(name).Funcs(funcs).Parse(tmplSrc)
+	if err != nil {
+		return suggestFunction(err, cfg)
+	}
+	if sb := configSandbox(cfg); sb != nil {
+		errs, guards := checkTemplate(t, funcs, reflect.TypeOf(obj), sb, true)
+		if len(errs) > 0 {
+			return errs
+		}
+		sb.apply(t, guards)
+	}
+	w = e.applyLimits(t, w)
+	if err = t.Execute(w, obj); err != nil {
+		if e.ctx != nil && e.ctx.Err() != nil {
+			return e.ctx.Err()
+		}
+		if limitErr := findLimitError(err); limitErr != nil {
+			return limitErr
+		}
+		setErrorLocation(err)
+		return err
+	}
+	return nil
+}
+
+// contextWriter is an io.Writer that fails once its context is cancelled.
+// It allows the execution of templates that loop over large inputs to be
+// interrupted.
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (c contextWriter) Write(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.w.Write(p)
+}
+
+// OutputToTemplateContext is similar to OutputToTemplate with the exception
+// that the execution of the template can be cancelled using ctx.  The
+// functions provided by tfortools that iterate over their input, such as
+// sort, filter, cols and table, check ctx periodically and abort the
+// execution of the template if ctx is cancelled.  Execution is also aborted
+// the next time the template writes to w after ctx has been cancelled.
+// If the execution of the template is aborted, ctx.Err() is returned.
+//
+// OutputToTemplateContext is useful when executing templates supplied by
+// untrusted users, e.g.,
+//
+//  ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+//  defer cancel()
+//  err := tfortools.OutputToTemplateContext(ctx, w, "user", src, obj, nil)
+func OutputToTemplateContext(ctx context.Context, w io.Writer, name, tmplSrc string, obj interface{},
+	cfg *Config) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return executeTemplate(ctx, w, name, tmplSrc, obj, cfg)
+}
+
+// CreateTemplate creates a new template, whose source is contained within the
+// tmplSrc parameter and whose name is given by the name parameter. The functions
+// enabled in the cfg parameter will be made available to the template source code
+// specified in tmplSrc.  If cfg is nil, all the additional functions provided by
+// tfortools will be enabled.  If sandbox mode is enabled in cfg, the
+// methods called by the template are checked as it executes.  Of the limits
+// set in cfg, only those enforced by the template functions apply to the
+// returned template.  The output, iteration and depth limits do not.
+// See OptMaxOutput.
+func CreateTemplate(name, tmplSrc string, cfg *Config) (*template.Template, error) {
+	if tmplSrc == "" {
+		return nil, fmt.Errorf("template %s contains no source", name)
+	}
+
+	funcs := getFuncMap(cfg)
+	t, err := template.New(name).Funcs(funcs).Parse(tmplSrc)
+	if err != nil {
+		return nil, suggestFunction(err, cfg)
+	}
+	if sb := configSandbox(cfg); sb != nil {
+		errs, guards := checkTemplate(t, funcs, nil, sb, true)
+		if len(errs) > 0 {
+			return nil, errs
+		}
+		sb.apply(t, guards)
+	}
+	return t, nil
 }
 
 // GenerateUsageUndecorated returns a formatted string identifying the
@@ -915,14 +2696,16 @@ func CreateTemplate(name, tmplSrc string, cfg *Config) (*template.Template, erro
 // than as tags.  This tag can be used to document your structures.
 func GenerateUsageUndecorated(i interface{}) string {
 	var buf bytes.Buffer
-	generateIndentedUsage(&buf, i)
+	generateIndentedUsage(&buf, i, nil)
 	return buf.String()
 }
 
 // GenerateUsageDecorated is similar to GenerateUsageUndecorated with the
 // exception that it outputs the usage information for all the new functions
 // enabled in the Config object cfg.  If cfg is nil, help information is
-// printed for all new template functions defined by this package.
+// printed for all new template functions defined by this package.  If
+// sandbox mode is enabled in cfg, only the methods that templates are
+// permitted to call are listed.
 func GenerateUsageDecorated(flag string, i interface{}, cfg *Config) string {
 	var buf bytes.Buffer
 
@@ -930,8 +2713,8 @@ func GenerateUsageDecorated(flag string, i interface{}, cfg *Config) string {
 		"The template passed to the -%s option operates on a\n\n",
 		flag)
 
-	generateIndentedUsage(&buf, i)
+	generateIndentedUsage(&buf, i, configSandbox(cfg))
 	fmt.Fprintln(&buf)
-	fmt.Fprintf(&buf, TemplateFunctionHelp(cfg))
+	fmt.Fprint(&buf, TemplateFunctionHelp(cfg))
 	return buf.String()
 }
@@ -16,13 +16,19 @@ package tfortools
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"text/template"
+	"time"
 )
 
 type testint int
@@ -120,6 +126,8 @@ func TestOptions(t *testing.T) {
 		OptFilterHasSuffix,
 		OptFilterFolded,
 		OptFilterRegexp,
+		OptFilterNil,
+		OptFilterNotNil,
 		OptToJSON,
 		OptToCSV,
 		OptSelect,
@@ -141,6 +149,64 @@ func TestOptions(t *testing.T) {
 		OptPromote,
 		OptSliceof,
 		OptToTable,
+		OptKeys,
+		OptValues,
+		OptEntries,
+		OptFormatCol,
+		OptHumanBytes,
+		OptHumanDuration,
+		OptSince,
+		OptAgo,
+		OptSINumber,
+		OptThousands,
+		OptUpper,
+		OptLower,
+		OptTrim,
+		OptReplace,
+		OptSplit,
+		OptJoin,
+		OptPadLeft,
+		OptPadRight,
+		OptTruncate,
+		OptRegexReplace,
+		OptAdd,
+		OptSub,
+		OptMul,
+		OptDiv,
+		OptMod,
+		OptRound,
+		OptFloor,
+		OptCeil,
+		OptAbs,
+		OptPercent,
+		OptNow,
+		OptFormatTime,
+		OptParseTime,
+		OptTimeTrunc,
+		OptAddDuration,
+		OptInZone,
+		OptList,
+		OptDict,
+		OptSeq,
+		OptDefault,
+		OptCoalesce,
+		OptTernary,
+		OptUnion,
+		OptIntersect,
+		OptDifference,
+		OptReverse,
+		OptSkip,
+		OptSubslice,
+		OptChunk,
+		OptFlattenSlices,
+		OptZip,
+		OptShuffle,
+		OptSample,
+		OptEnumerate,
+		OptRank,
+		OptFromJSON,
+		OptFromYAML,
+		OptFromCSV,
 	}
 
 	// Check that specifying an option twice does not lead to duplicate help.
@@ -187,6 +253,29 @@ func TestOptAllFns(t *testing.T) {
 	if oldSliceLen != len(funcHelpSlice) {
 		t.Errorf("Global funcHelpSlice should not be modified")
 	}
+
+	// Check that the convenience functions enable the functions that they
+	// document.
+
+	groups := []struct {
+		opt   func(*Config)
+		names []string
+	}{
+		{OptStrings, []string{"upper", "lower", "trim", "replace", "split", "join",
+			"padLeft", "padRight", "truncate", "regexReplace"}},
+		{OptBuilders, []string{"list", "dict", "seq", "default", "coalesce", "ternary"}},
+		{OptSets, []string{"union", "intersect", "difference"}},
+		{OptReshape, []string{"reverse", "skip", "subslice", "chunk", "flattenSlices",
+			"zip", "shuffle", "sample"}},
+		{OptEnumerations, []string{"enumerate", "rank"}},
+		{OptDecoders, []string{"fromjson", "fromyaml", "fromcsv"}},
+	}
+	for _, g := range groups {
+		names := TemplateFunctionNames(NewConfig(g.opt))
+		if !reflect.DeepEqual(names, g.names) {
+			t.Errorf("Expected %v got %v", g.names, names)
+		}
+	}
 }
 
 // Check an error is returned when cols is used incorrectly.
@@ -246,7 +335,7 @@ func TestSliceOfPointers(t *testing.T) {
 	}
 }
 
-func testToTableInvalid(t *testing.T, data [][]string) {
+func testToTableInvalid(t *testing.T, data [][]string, options ...string) {
 	defer func() {
 		err := recover()
 		if err == nil {
@@ -256,7 +345,22 @@ func testToTableInvalid(t *testing.T, data [][]string) {
 		}
 	}()
 
-	_ = toTable(data)
+	_ = toTable(data, options...)
+}
+
+func testTemplateOutput(t *testing.T, name, script string, data interface{}, expected string) {
+	var b bytes.Buffer
+	if err := OutputToTemplate(&b, name, script, data, nil); err != nil {
+		t.Errorf("%s: unexpected error: %v", script, err)
+		return
+	}
+	lines := strings.Split(b.String(), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " ")
+	}
+	if got := strings.Join(lines, "\n"); got != expected {
+		t.Errorf("%s: expected\n%q\ngot\n%q", script, expected, got)
+	}
 }
 
 func TestToTable(t *testing.T) {
@@ -290,14 +394,22 @@ func TestToTable(t *testing.T) {
 		{"lowercase", " Contains Spaces ", "*invalid_char", "1_num_start"},
 		{"A", "B", "10", "10.5"},
 		{"A", "B", "i'm a string", "10.5"},
-	})
+	}, "sample=1")
+
+	testToTableInvalid(t, [][]string{
+		{"Code"},
+		{"10"},
+		{""},
+	}, "sample=1")
+
+	testToTableInvalid(t, [][]string{{"Code"}, {"10"}}, "sample=0")
 
 	testToTableInvalid(t, nil)
 
 	testToTableInvalid(t, [][]string{
-		{"lowercase", "lowercase", "*invalid_char", "1_num_start"},
+		{"lowercase", "Lowercase", "*invalid_char", "1_num_start"},
 		{"A", "B", "10", "10.5"},
-		{"A", "B", "i'm a string", "10.5"},
+		{"A", "B", "i'm a string"},
 	})
 
 	testToTableInvalid(t, [][]string{
@@ -308,6 +420,117 @@ func TestToTable(t *testing.T) {
 
 }
 
+// Check totable infers column types from all the rows
+//
+// Convert a table whose columns contain mixtures of ints and floats, bools,
+// dates and empty cells.
+//
+// The field types of the returned structures should be the widest types
+// needed to hold the values of each column, with pointer fields used for
+// columns that contain empty cells.
+func TestToTableInference(t *testing.T) {
+	data := [][]string{
+		{"Int", "Float", "Mixed", "Bool", "Date", "Nullable", "Text", "Empty"},
+		{"1", "1", "true", "true", "2017-03-17", "", "", ""},
+		{"2", "1.5", "10", "FALSE", "2017-03-17T10:59:00Z", "7", "x", ""},
+	}
+	fieldTypes := []reflect.Type{
+		reflect.TypeOf(0), reflect.TypeOf(0.0), reflect.TypeOf(""),
+		reflect.TypeOf(false), reflect.TypeOf(time.Time{}),
+		reflect.TypeOf((*int)(nil)), reflect.TypeOf(""), reflect.TypeOf(""),
+	}
+
+	val := reflect.ValueOf(toTable(data))
+	typ := val.Type().Elem()
+	for i, ft := range fieldTypes {
+		if typ.Field(i).Type != ft {
+			t.Errorf("Unexpected type for %s, wanted %s got %s",
+				typ.Field(i).Name, ft, typ.Field(i).Type)
+		}
+	}
+
+	var b bytes.Buffer
+	script := `{{range totable .}}{{.Float}} {{.Bool}} {{.Date.Day}} {{.Nullable}}{{println}}{{end}}`
+	if err := OutputToTemplate(&b, "totable", script, data, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "1 true 17 <nil>\n1.5 false 17 7\n"
+	if b.String() != expected {
+		t.Errorf("Expected %q got %q", expected, b.String())
+	}
+}
+
+// Check the options accepted by totable
+//
+// Convert tables without headers, with duplicate headings and with explicit
+// schemas.
+//
+// The field names and types of the returned structures should match those
+// expected.  Invalid schemas and ragged rows should result in an error.
+func TestToTableOptions(t *testing.T) {
+	tests := []struct {
+		data    [][]string
+		options []string
+		names   []string
+		types   []reflect.Type
+	}{
+		{
+			[][]string{{"a", "1"}, {"b", "2"}},
+			[]string{"noheader"},
+			[]string{"Col1", "Col2"},
+			[]reflect.Type{reflect.TypeOf(""), reflect.TypeOf(0)},
+		},
+		{
+			[][]string{{"name", "Name", "name?", "Name_2"}, {"a", "b", "c", "d"}},
+			nil,
+			[]string{"Name", "Name_2", "Name_", "Name_2_2"},
+			[]reflect.Type{reflect.TypeOf(""), reflect.TypeOf(""), reflect.TypeOf(""),
+				reflect.TypeOf("")},
+		},
+		{
+			[][]string{{"x", "y", "z"}, {"a", "1", "1"}, {"b", "", "2"}},
+			[]string{"schema=Name:string,Age:*float,Count"},
+			[]string{"Name", "Age", "Count"},
+			[]reflect.Type{reflect.TypeOf(""), reflect.TypeOf((*float64)(nil)), reflect.TypeOf(0)},
+		},
+		{
+			[][]string{{"10", "true"}},
+			[]string{"noheader", "schema=Code:string,OK:bool"},
+			[]string{"Code", "OK"},
+			[]reflect.Type{reflect.TypeOf(""), reflect.TypeOf(false)},
+		},
+	}
+
+	for _, tt := range tests {
+		typ := reflect.TypeOf(toTable(tt.data, tt.options...)).Elem()
+		if typ.NumField() != len(tt.names) {
+			t.Errorf("%v: expected %d fields got %d", tt.options, len(tt.names), typ.NumField())
+			continue
+		}
+		for i := range tt.names {
+			if typ.Field(i).Name != tt.names[i] || typ.Field(i).Type != tt.types[i] {
+				t.Errorf("%v: expected field %s %s got %s %s", tt.options,
+					tt.names[i], tt.types[i], typ.Field(i).Name, typ.Field(i).Type)
+			}
+		}
+	}
+
+	testToTableInvalid(t, [][]string{{"a", "b"}, {"1"}})
+	testToTableInvalid(t, [][]string{{"a"}, {"1", "2"}})
+	testToTableInvalid(t, [][]string{{"a", "b"}}, "schema=A:int")
+	testToTableInvalid(t, [][]string{{"a"}, {"1"}}, "schema=A:complex")
+	testToTableInvalid(t, [][]string{{"a"}, {"x"}}, "schema=A:int")
+	testToTableInvalid(t, [][]string{{"a"}, {""}}, "schema=A:int")
+	testToTableInvalid(t, nil, "noheader")
+	testToTableInvalid(t, [][]string{{"a"}, {"1"}}, "header")
+
+	err := OutputToTemplate(ioutil.Discard, "ragged", `{{totable .}}`,
+		[][]string{{"a", "b"}, {"1", "2"}, {"3"}}, nil)
+	if _, ok := err.(template.ExecError); !ok {
+		t.Errorf("Expected ExecError for ragged rows, got %v", err)
+	}
+}
+
 type testStruct struct{}
 
 func (t *testStruct) DoSomething() {
@@ -341,3 +564,1443 @@ Get2() (string, string)
 		t.Errorf("Expected\n%s\ngot\n%s", expected, buf.String())
 	}
 }
+
+// Test tfortools functions work with slices of maps
+//
+// Sort a slice of maps, such as those produced by decoding arbitrary JSON,
+// filter it, extract some columns and output the result as a table and
+// as CSV.  One of the maps is missing a key.
+//
+// The sort, filter, cols, table and tocsv functions should all accept the
+// slice.  The headings should be the sorted union of the keys and missing
+// values should be output as blanks.
+func TestSliceOfMaps(t *testing.T) {
+	data := []map[string]interface{}{
+		{"Name": "Marcus", "Age": 63.0, "City": "Arpinum"},
+		{"Name": "Gaius", "Age": 55.0},
+		{"Name": "Lucius", "Age": 78.0, "City": "Rome"},
+	}
+
+	tests := []struct {
+		script   string
+		expected string
+	}{
+		{`{{tablex (sort . "Age" "dsc") 0 8 1}}`,
+			"Age City    Name\n78  Rome    Lucius\n63  Arpinum Marcus\n55          Gaius\n"},
+		{`{{tocsv (cols (sort . "City") "Name" "City")}}`,
+			"City,Name\nArpinum,Marcus\nRome,Lucius\n,Gaius\n"},
+		{`{{select (filter . "City" "Rome") "Name"}}`, "Lucius\n"},
+		{`{{htablex (filterHasPrefix . "Name" "G") 0 8 1}}`, "Age:  55\nName: Gaius\n"},
+	}
+
+	for _, tt := range tests {
+		testTemplateOutput(t, "maps", tt.script, data, tt.expected)
+	}
+
+	err := OutputToTemplate(ioutil.Discard, "maps", `{{cols . "Surname"}}`, data, nil)
+	if err == nil {
+		t.Errorf("Error expected when selecting an unknown key")
+	}
+
+	// With OptFirstSeenKeys, the keys missing from the first map follow
+	// its sorted keys in the order in which they are found.
+
+	mixed := []map[string]interface{}{
+		{"Name": "Marcus", "Age": 63},
+		{"Zone": "North", "City": "Rome"},
+	}
+	for _, opt := range []struct {
+		cfg      *Config
+		expected string
+	}{
+		{nil, "Age,City,Name,Zone\n63,,Marcus,\n,Rome,,North\n"},
+		{NewConfig(OptAllFns, OptFirstSeenKeys), "Age,Name,City,Zone\n63,Marcus,,\n,,Rome,North\n"},
+	} {
+		var b bytes.Buffer
+		if err := OutputToTemplate(&b, "maps", `{{tocsv .}}`, mixed, opt.cfg); err != nil {
+			t.Fatalf("Unexpected error processing slice of maps: %v", err)
+		}
+		if b.String() != opt.expected {
+			t.Errorf("expected\n%q\ngot\n%q", opt.expected, b.String())
+		}
+	}
+}
+
+type testVM struct {
+	Name   string
+	CPUs   int
+	Memory int
+}
+
+type testContainer struct {
+	Name  string
+	Image string
+	CPUs  int
+}
+
+// Test tfortools functions work with slices of interfaces
+//
+// Sort and filter a slice of interfaces holding two different types of
+// structs and a pointer to a struct, and output the results as tables.
+//
+// The headings of the tables should be the union of the fields of the
+// structs in first-seen order.  Fields not present in an element should
+// be output as blanks.
+func TestSliceOfInterfaces(t *testing.T) {
+	data := []interface{}{
+		testVM{"db", 4, 8192},
+		testContainer{"web", "nginx", 1},
+		&testVM{"cache", 2, 2048},
+	}
+
+	tests := []struct {
+		script   string
+		expected string
+	}{
+		{`{{tablex (sort . "CPUs") 0 8 1}}`,
+			"Name  Image CPUs Memory\nweb   nginx 1\ncache       2    2048\ndb          4    8192\n"},
+		{`{{tablex (sort . "Memory" "dsc") 0 8 1}}`,
+			"Name  CPUs Memory Image\ndb    4    8192\ncache 2    2048\nweb   1           nginx\n"},
+		{`{{tablex (cols (filterHasSuffix . "Name" "b") "Name" "Image") 0 8 1}}`,
+			"Name Image\ndb\nweb  nginx\n"},
+		{`{{select . "Memory"}}`, "8192\n\n2048\n"},
+	}
+
+	for _, tt := range tests {
+		testTemplateOutput(t, "interfaces", tt.script, data, tt.expected)
+	}
+
+	err := OutputToTemplate(ioutil.Discard, "interfaces", `{{sort . "Disk"}}`, data, nil)
+	if err == nil {
+		t.Errorf("Error expected when sorting by an unknown field")
+	}
+}
+
+// Check that nil pointers are handled when traversing field paths
+//
+// Filter, select and tabulate a slice of structures containing nil pointers,
+// some of which need to be traversed to reach the requested field.  One
+// element of the slice is itself a nil pointer.
+//
+// None of the functions should fail.  Nil values should be skipped by
+// filter and output as "<nil>" by select and table.
+func TestNilFields(t *testing.T) {
+	type owner struct {
+		Name  string
+		Email *string
+	}
+	type resource struct {
+		Name  string
+		Owner *owner
+	}
+	data := []*resource{
+		{"db", &owner{"Marcus", nil}},
+		{"web", nil},
+		nil,
+	}
+
+	tests := []struct {
+		script   string
+		expected string
+	}{
+		{`{{select . "Owner.Name"}}`, "Marcus\n<nil>\n<nil>\n"},
+		{`{{select . "Owner.Email"}}`, "<nil>\n<nil>\n<nil>\n"},
+		{`{{len (filter . "Owner.Name" "Marcus")}}`, "1"},
+		{`{{len (filterNil . "Owner.Name")}}`, "2"},
+		{`{{tablex . 0 8 1}}`, "Name  Owner\ndb    &{Marcus <nil>}\nweb   <nil>\n<nil> <nil>\n"},
+	}
+
+	for _, tt := range tests {
+		testTemplateOutput(t, "nil", tt.script, data, tt.expected)
+	}
+}
+
+// Check formatCol works with select, tocsv and slices of maps
+//
+// Format a column of a slice of maps and output it with select and tocsv.
+// Then try to use a time layout with a field that is not a time.Time.
+//
+// The formatted values should be output by select and tocsv, the other
+// values should be unchanged and the final script should fail.
+func TestFormatCol(t *testing.T) {
+	data := []map[string]interface{}{
+		{"Name": "Big Company", "Current": 120.234},
+		{"Name": "Tiny Corp", "Current": 0.5},
+	}
+
+	tests := []struct {
+		script   string
+		expected string
+	}{
+		{`{{select (formatCol . "Current" "%06.2f") "Current"}}`, "120.23\n000.50\n"},
+		{`{{tocsv (sort (formatCol . "Current" "%.0f") "Current")}}`,
+			"Current,Name\n0,Tiny Corp\n120,Big Company\n"},
+	}
+
+	for _, tt := range tests {
+		var b bytes.Buffer
+		err := OutputToTemplate(&b, "formatCol", tt.script, data, nil)
+		if err != nil {
+			t.Errorf("Unexpected error formatting columns: %v", err)
+			continue
+		}
+		if b.String() != tt.expected {
+			t.Errorf("%s: expected\n%q\ngot\n%q", tt.script, tt.expected, b.String())
+		}
+	}
+
+	structData := []struct{ Current float64 }{{1.0}}
+	err := OutputToTemplate(ioutil.Discard, "formatCol", `{{formatCol . "Current" "15:04"}}`,
+		structData, nil)
+	if err == nil {
+		t.Errorf("Error expected when using a time layout with a float64")
+	}
+}
+
+// Check the human readable unit functions can be used as column formatters
+//
+// Format the columns of a slice of structs using the names of the human
+// readable unit functions and output the result using tablex.  Then call
+// since and ago on a time three hours in the past.
+//
+// The columns should be formatted using the named functions and since and
+// ago should report a time three hours ago.
+func TestHumanUnits(t *testing.T) {
+	data := []struct {
+		Name   string
+		Volume int
+		Size   uint64
+		Uptime time.Duration
+	}{
+		{"Happy Enterprises", 6395624278, 1 << 30, 49 * time.Hour},
+		{"Tiny Corp", 155, 100, 30 * time.Second},
+	}
+
+	script := `{{tablex (formatCol . "Volume" "thousands" "Size" "humanBytes" "Uptime" "humanDuration") 0 8 1}}`
+	expected := `Name              Volume        Size   Uptime
+Happy Enterprises 6,395,624,278 1.0GiB 2d1h
+Tiny Corp         155           100B   30.0s
+`
+	testTemplateOutput(t, "units", script, data, expected)
+
+	var b bytes.Buffer
+	past := time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC)
+	cfg := NewConfig(OptAllFns, OptFrozenNow(past.Add(3*time.Hour)))
+	if err := OutputToTemplate(&b, "ago", `{{humanDuration (since .)}} {{ago .}}`, past, cfg); err != nil {
+		t.Fatalf("Unexpected error calling ago: %v", err)
+	}
+	if b.String() != "3h0m 3h0m ago" {
+		t.Errorf("Expected 3h0m 3h0m ago, got %s", b.String())
+	}
+
+	numbers := []struct {
+		value    interface{}
+		expected string
+	}{
+		{int64(1<<53 + 1), "9,007,199,254,740,993"},
+		{uint64(math.MaxUint64), "18,446,744,073,709,551,615"},
+		{int64(math.MinInt64), "-9,223,372,036,854,775,808"},
+		{-1234567, "-1,234,567"},
+		{1234.5, "1,234.5"},
+		{uint8(255), "255"},
+	}
+	for _, n := range numbers {
+		b.Reset()
+		if err := OutputToTemplate(&b, "thousands", `{{thousands .}}`, n.value, nil); err != nil {
+			t.Fatalf("Unexpected error calling thousands: %v", err)
+		}
+		if b.String() != n.expected {
+			t.Errorf("Expected %s, got %s", n.expected, b.String())
+		}
+	}
+
+	err := OutputToTemplate(ioutil.Discard, "units", `{{humanBytes .}}`, "ten", nil)
+	if err == nil {
+		t.Errorf("Error expected when passing a string to humanBytes")
+	}
+}
+
+// Check the string manipulation functions
+//
+// Execute a series of scripts that call each of the string functions
+// enabled by OptStrings.
+//
+// The output of each script should match the expected output.
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		script   string
+		expected string
+	}{
+		{`{{lower "MiXeD"}}`, "mixed"},
+		{`{{trim "  spaces  "}}|{{trim "--dashes__" "-_"}}`, "spaces|dashes"},
+		{`{{replace "a/b/c" "/" "."}}`, "a.b.c"},
+		{`{{index (split "a,b,c" ",") 1}}`, "b"},
+		{`{{join (split "a,b,c" ",") "-"}}`, "a-b-c"},
+		{`{{join .Ints ", "}}`, "1, 2, 3"},
+		{`{{padLeft "37" 5 "0"}}|{{padLeft "toolong" 3}}|{{padRight "ab" 4}}|`, "00037|toolong|ab  |"},
+		{`{{truncate "Too many GOSUBs" 10 "..."}}|{{truncate "short" 10 "..."}}`, "Too man...|short"},
+		{`{{regexReplace "Marcus Cicero" "^(\\w+) (\\w+)$" "$2, $1"}}`, "Cicero, Marcus"},
+	}
+
+	data := struct{ Ints []int }{[]int{1, 2, 3}}
+	cfg := NewConfig(OptStrings)
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := OutputToTemplate(&b, "strings", tt.script, data, cfg); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.script, err)
+			continue
+		}
+		if b.String() != tt.expected {
+			t.Errorf("%s: expected %q got %q", tt.script, tt.expected, b.String())
+		}
+	}
+
+	invalid := []string{
+		`{{regexReplace "abc" "(" ""}}`,
+		`{{padLeft "abc" 5 "ab"}}`,
+		`{{truncate "abc" -1}}`,
+		`{{join 10 ","}}`,
+	}
+	for _, script := range invalid {
+		if err := OutputToTemplate(ioutil.Discard, "strings", script, data, cfg); err == nil {
+			t.Errorf("%s: error expected", script)
+		}
+	}
+}
+
+// Check the arithmetic functions
+//
+// Execute a series of scripts that call each of the functions enabled by
+// OptMath, using a mixture of integer and floating point types.
+//
+// The output of each script should match the expected output and scripts
+// that divide by zero or overflow should fail.
+func TestMath(t *testing.T) {
+	tests := []struct {
+		script   string
+		expected string
+	}{
+		{`{{add 1 2}}`, "3"},
+		{`{{add .Int8 .Uint}}`, "-3"},
+		{`{{add .Uint .Uint}}`, "14"},
+		{`{{add .Int8 .Float}}`, "-8.5"},
+		{`{{sub 1 2}}`, "-1"},
+		{`{{sub .Uint 8}}`, "-1"},
+		{`{{mul .Uint 6}}`, "42"},
+		{`{{mul .Float 2}}`, "3"},
+		{`{{div 7 2}}`, "3"},
+		{`{{div 7.0 2}}`, "3.5"},
+		{`{{mod -7 3}}`, "-1"},
+		{`{{mod 7.5 2}}`, "1.5"},
+		{`{{round 2.5}}|{{round 3.14159 2}}|{{round .Int8}}`, "3|3.14|-10"},
+		{`{{floor -1.5}}|{{ceil -1.5}}`, "-2|-1"},
+		{`{{abs .Int8}}|{{abs -1.5}}|{{abs .Uint}}`, "10|1.5|7"},
+		{`{{percent 1 8}}`, "12.5"},
+		{`{{index .Strings (add 1 1)}}`, "c"},
+	}
+
+	data := struct {
+		Int8    int8
+		Uint    uint32
+		Uint8   uint8
+		Float   float64
+		Strings []string
+	}{-10, 7, 8, 1.5, []string{"a", "b", "c"}}
+	cfg := NewConfig(OptMath)
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := OutputToTemplate(&b, "math", tt.script, data, cfg); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.script, err)
+			continue
+		}
+		if b.String() != tt.expected {
+			t.Errorf("%s: expected %q got %q", tt.script, tt.expected, b.String())
+		}
+	}
+
+	invalid := []string{
+		`{{div 1 0}}`,
+		`{{div 1.0 0}}`,
+		`{{mod 1 0}}`,
+		`{{percent 1 0}}`,
+		`{{add 9223372036854775807 1}}`,
+		`{{sub .Uint .Uint8}}`,
+		`{{mul 9223372036854775807 2}}`,
+		`{{add "1" 2}}`,
+		`{{round 1.5 1 2}}`,
+	}
+	for _, script := range invalid {
+		if err := OutputToTemplate(ioutil.Discard, "math", script, data, cfg); err == nil {
+			t.Errorf("%s: error expected", script)
+		}
+	}
+}
+
+// Check the date and time functions
+//
+// Execute a series of scripts that call each of the functions enabled by
+// OptTime using a Config object whose current time is frozen.
+//
+// The output of each script should match the expected output.
+func TestTime(t *testing.T) {
+	frozen := time.Date(2017, time.March, 17, 12, 23, 45, 0, time.UTC)
+	tests := []struct {
+		script   string
+		expected string
+	}{
+		{`{{formatTime now}}`, "2017-03-17T12:23:45Z"},
+		{`{{formatTime .Trade "kitchen"}}|{{formatTime .Trade "date"}}`, "10:59AM|2017-03-17"},
+		{`{{formatTime .Trade "Jan 2"}}`, "Mar 17"},
+		{`{{formatTime (parseTime "2017-03-16" "date")}}`, "2017-03-16T00:00:00Z"},
+		{`{{formatTime (timeTrunc .Trade "day")}}`, "2017-03-17T00:00:00Z"},
+		{`{{formatTime (timeTrunc .Trade "hour") "time"}}`, "10:00:00"},
+		{`{{formatTime (timeTrunc .Trade "15m") "time"}}`, "10:45:00"},
+		{`{{formatTime (addDuration .Trade "-1h") "time"}}`, "09:59:00"},
+		{`{{formatTime (addDuration .Trade 60) "time"}}`, "11:00:00"},
+		{`{{formatTime (inZone .Trade "America/New_York")}}`, "2017-03-17T06:59:00-04:00"},
+		{`{{since .Trade}}|{{ago .Trade}}`, "1h24m45s|1h24m ago"},
+		{`{{select (formatCol .Rows "Trade" "ago") "Trade"}}`, "1h24m ago\n"},
+	}
+
+	trade := time.Date(2017, time.March, 17, 10, 59, 0, 0, time.UTC)
+	data := struct {
+		Trade time.Time
+		Rows  []struct{ Trade time.Time }
+	}{trade, []struct{ Trade time.Time }{{trade}}}
+	cfg := NewConfig(OptTime, OptFormatCol, OptSelect, OptFrozenNow(frozen))
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := OutputToTemplate(&b, "time", tt.script, data, cfg); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.script, err)
+			continue
+		}
+		if b.String() != tt.expected {
+			t.Errorf("%s: expected %q got %q", tt.script, tt.expected, b.String())
+		}
+	}
+
+	invalid := []string{
+		`{{parseTime "yesterday"}}`,
+		`{{timeTrunc .Trade "fortnight"}}`,
+		`{{addDuration .Trade "soon"}}`,
+		`{{inZone .Trade "Middle/Earth"}}`,
+		`{{formatTime "now"}}`,
+	}
+	for _, script := range invalid {
+		if err := OutputToTemplate(ioutil.Discard, "time", script, data, cfg); err == nil {
+			t.Errorf("%s: error expected", script)
+		}
+	}
+}
+
+// Check the collection building functions
+//
+// Execute a series of scripts that call list, dict, seq, default, coalesce
+// and ternary, passing their results to other functions.
+//
+// The output of each script should match the expected output.
+func TestBuilders(t *testing.T) {
+	tests := []struct {
+		script   string
+		expected string
+	}{
+		{`{{list "a" 1 true}}`, "[a 1 true]"},
+		{`{{len (list)}}`, "0"},
+		{`{{with dict "A" 1 "B" "two"}}{{.A}}-{{.B}}{{end}}`, "1-two"},
+		{`{{tablex (sort (list (dict "Name" "b" "Age" 2) (dict "Name" "a" "Age" 1)) "Name") 0 8 1 "Age" "Name"}}`,
+			"Age Name\n1   a\n2   b\n"},
+		{`{{seq 3}}|{{seq 2 5}}|{{seq 0 10 3}}|{{seq 3 0 -1}}|{{seq 5 2}}`, "[0 1 2]|[2 3 4]|[0 3 6 9]|[3 2 1]|[]"},
+		{`{{range rows .Names (seq 0 5 2)}}{{.}}{{end}}`, "ace"},
+		{`{{range rows .Names 4 (seq 2) 9}}{{.}}{{end}}`, "eab"},
+		{`{{default .Empty "x"}}|{{default .Names "x"}}|{{default 0 1}}`, "x|[a b c d e]|1"},
+		{`{{coalesce .Empty 0 "" "first" "second"}}|{{coalesce .Empty}}`, "first|<no value>"},
+		{`{{ternary true "yes" "no"}}|{{ternary .Empty "yes" "no"}}`, "yes|no"},
+	}
+
+	data := struct {
+		Names []string
+		Empty string
+	}{[]string{"a", "b", "c", "d", "e"}, ""}
+	for _, tt := range tests {
+		testTemplateOutput(t, "builders", tt.script, data, tt.expected)
+	}
+
+	invalid := []string{
+		`{{dict "A"}}`,
+		`{{dict 1 2}}`,
+		`{{seq 0 10 0}}`,
+		`{{seq}}`,
+		`{{rows .Names "1"}}`,
+	}
+	for _, script := range invalid {
+		if err := OutputToTemplate(ioutil.Discard, "builders", script, data, nil); err == nil {
+			t.Errorf("%s: error expected", script)
+		}
+	}
+}
+
+// Check the set operations
+//
+// Compute the union, intersection and difference of two slices of structures
+// keyed on a nested field and pass the results to other functions.
+//
+// The output of each script should match the expected output.  Slices of
+// different types and invalid field names should result in an error.
+func TestSetOperations(t *testing.T) {
+	type host struct{ Name string }
+	type vm struct {
+		Host  *host
+		State string
+	}
+
+	// Structures containing interface values are comparable but cannot
+	// be used as map keys if the interfaces hold slices.
+
+	type label struct{ Value interface{} }
+	type tagged struct {
+		Label label
+		Name  string
+	}
+	data := struct {
+		Desired []vm
+		Actual  []vm
+		Names   []string
+		Tagged  []tagged
+	}{
+		Desired: []vm{{&host{"a"}, "up"}, {&host{"b"}, "up"}, {&host{"c"}, "up"}, {&host{"a"}, "dup"}},
+		Actual:  []vm{{&host{"c"}, "down"}, {&host{"d"}, "new"}, {nil, "orphan"}, {&host{"a"}, "up"}},
+		Names:   []string{"x"},
+		Tagged: []tagged{{label{[]int{1}}, "a"}, {label{[]int{1}}, "b"}, {label{[]int{2}}, "c"},
+			{label{3}, "d"}, {label{"[1]"}, "e"}, {label{nil}, "f"}, {label{"<nil>"}, "g"}},
+	}
+
+	tests := []struct {
+		script   string
+		expected string
+	}{
+		{`{{range union .Desired .Actual "Host.Name"}}{{.State}} {{end}}`, "up up up new orphan "},
+		{`{{range intersect .Desired .Actual "Host.Name"}}{{.Host.Name}} {{end}}`, "a c "},
+		{`{{range difference .Desired .Actual "Host.Name"}}{{.Host.Name}} {{end}}`, "b "},
+		{`{{range difference .Actual .Desired "Host.Name"}}{{.State}} {{end}}`, "new orphan "},
+		{`{{len (intersect .Desired .Desired "State")}}`, "2"},
+		{`{{range sort (union .Actual .Desired "State") "State"}}{{.State}} {{end}}`, "down dup new orphan up "},
+		{`{{range union .Tagged .Tagged "Label"}}{{.Name}} {{end}}`, "a c d e f g "},
+		{`{{range difference .Tagged (head .Tagged 1) "Label"}}{{.Name}} {{end}}`, "c d e f g "},
+
+		// Values that cannot be used as map keys, and nil values, must not
+		// be confused with strings that have the same representation.
+
+		{`{{range union .Tagged .Tagged "Label.Value"}}{{.Name}} {{end}}`, "a c d e f g "},
+		{`{{range intersect .Tagged (head .Tagged 1) "Label.Value"}}{{.Name}} {{end}}`, "a "},
+		{`{{range intersect .Tagged (rows .Tagged 5) "Label.Value"}}{{.Name}} {{end}}`, "f "},
+		{`{{range difference .Tagged (rows .Tagged 4 6) "Label.Value"}}{{.Name}} {{end}}`, "a c d f "},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := OutputToTemplate(&b, "sets", tt.script, data, nil); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.script, err)
+			continue
+		}
+		if b.String() != tt.expected {
+			t.Errorf("%s: expected %q got %q", tt.script, tt.expected, b.String())
+		}
+	}
+
+	invalid := []string{
+		`{{union .Desired .Names "Host.Name"}}`,
+		`{{intersect .Desired .Actual "Host.Address"}}`,
+		`{{difference .Desired "abc" "State"}}`,
+	}
+	for _, script := range invalid {
+		if err := OutputToTemplate(ioutil.Discard, "sets", script, data, nil); err == nil {
+			t.Errorf("%s: error expected", script)
+		}
+	}
+}
+
+// Check the slice reshaping functions
+//
+// Execute a series of scripts that call reverse, skip, slice, chunk,
+// flattenSlices and zip, checking that their results retain the element
+// types of their inputs.
+//
+// The output of each script should match the expected output.
+func TestReshape(t *testing.T) {
+	tests := []struct {
+		script   string
+		expected string
+	}{
+		{`{{reverse .Ints}}|{{reverse .Empty}}`, "[5 4 3 2 1]|[]"},
+		{`{{skip .Ints}}|{{skip .Ints 3}}|{{skip .Ints 10}}`, "[2 3 4 5]|[4 5]|[]"},
+		{`{{subslice .Ints 1 3}}|{{subslice .Ints -2}}|{{subslice .Ints 1 -1}}|{{subslice .Ints -10 10}}|{{subslice .Ints 3 1}}`,
+			"[2 3]|[4 5]|[2 3 4]|[1 2 3 4 5]|[]"},
+		{`{{subslice "hello" 1 -1}}`, "ell"},
+		{`{{slice .Ints 1 3}}|{{slice .Ints 1 2 3}}|{{slice "hello" 1}}`, "[2 3]|[2]|ello"},
+		{`{{chunk .Ints 2}}|{{len (chunk .Empty 2)}}`, "[[1 2] [3 4] [5]]|0"},
+		{`{{flattenSlices (chunk .Ints 2)}}`, "[1 2 3 4 5]"},
+		{`{{flattenSlices .Mixed}}`, "[1 2 a]"},
+		{`{{range zip .Names .Ints}}{{.Item1}}={{.Item2}} {{end}}`, "a=1 b=2 c=3 "},
+		{`{{range sort (zip .Names .Ints) "Item2" "dsc"}}{{.Item1}}{{end}}`, "cba"},
+		{`{{printf "%T" (reverse .Ints)}} {{printf "%T" (chunk .Ints 2)}}`, "[]int [][]int"},
+	}
+
+	data := struct {
+		Ints  []int
+		Empty []int
+		Names [3]string
+		Mixed []interface{}
+	}{
+		Ints:  []int{1, 2, 3, 4, 5},
+		Names: [3]string{"a", "b", "c"},
+		Mixed: []interface{}{[]int{1, 2}, nil, []string{"a"}},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := OutputToTemplate(&b, "reshape", tt.script, data, nil); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.script, err)
+			continue
+		}
+		if b.String() != tt.expected {
+			t.Errorf("%s: expected %q got %q", tt.script, tt.expected, b.String())
+		}
+	}
+
+	invalid := []string{
+		`{{reverse 1}}`,
+		`{{skip .Ints -1}}`,
+		`{{subslice .Ints}}`,
+		`{{slice .Ints 1 10}}`,
+		`{{chunk .Ints 0}}`,
+		`{{flattenSlices .Ints}}`,
+		`{{zip .Ints}}`,
+	}
+	for _, script := range invalid {
+		if err := OutputToTemplate(ioutil.Discard, "reshape", script, data, nil); err == nil {
+			t.Errorf("%s: error expected", script)
+		}
+	}
+}
+
+// Check shuffle and sample
+//
+// Shuffle and sample a slice of integers using a fixed seed.
+//
+// The same seed should always produce the same output.  Shuffled slices
+// should be permutations of the input and samples should contain the
+// requested number of elements in their original order.
+func TestShuffleSample(t *testing.T) {
+	data := make([]int, 50)
+	for i := range data {
+		data[i] = i
+	}
+
+	run := func(script string) string {
+		var b bytes.Buffer
+		if err := OutputToTemplate(&b, "sample", script, data, nil); err != nil {
+			t.Fatalf("%s: unexpected error: %v", script, err)
+		}
+		return b.String()
+	}
+
+	shuffled := run(`{{range shuffle . 42}}{{.}} {{end}}`)
+	if shuffled != run(`{{range shuffle . 42}}{{.}} {{end}}`) {
+		t.Errorf("shuffle with the same seed returned different results")
+	}
+	if shuffled == run(`{{range shuffle . 43}}{{.}} {{end}}`) {
+		t.Errorf("shuffle with different seeds returned the same results")
+	}
+	seen := make(map[string]bool)
+	for _, f := range strings.Fields(shuffled) {
+		seen[f] = true
+	}
+	if len(seen) != len(data) || shuffled == run(`{{range .}}{{.}} {{end}}`) {
+		t.Errorf("shuffle did not return a permutation of its input: %s", shuffled)
+	}
+
+	sampled := run(`{{range sample . 10 42}}{{.}} {{end}}`)
+	if sampled != run(`{{range sample . 10 42}}{{.}} {{end}}`) {
+		t.Errorf("sample with the same seed returned different results")
+	}
+	fields := strings.Fields(sampled)
+	if len(fields) != 10 {
+		t.Errorf("expected 10 elements from sample, got %d", len(fields))
+	}
+	for i := 1; i < len(fields); i++ {
+		prev, _ := strconv.Atoi(fields[i-1])
+		cur, _ := strconv.Atoi(fields[i])
+		if prev >= cur {
+			t.Errorf("sample did not preserve the order of its input: %s", sampled)
+			break
+		}
+	}
+	if n := run(`{{len (sample . 100 1)}}|{{len (sample . 0)}}`); n != "50|0" {
+		t.Errorf("unexpected sample sizes %s", n)
+	}
+
+	invalid := []string{
+		`{{sample . -1}}`,
+		`{{sample . 1 2 3}}`,
+		`{{shuffle 10}}`,
+	}
+	for _, script := range invalid {
+		if err := OutputToTemplate(ioutil.Discard, "sample", script, data, nil); err == nil {
+			t.Errorf("%s: error expected", script)
+		}
+	}
+}
+
+// Check enumerate and rank
+//
+// Enumerate and rank slices of structures and maps, using both competition
+// and dense ranking.
+//
+// The output of each script should match the expected output.  The new
+// column should appear first in tables of structures.
+func TestEnumerateRank(t *testing.T) {
+	type stock struct {
+		Name   string
+		Volume int
+		hidden int
+	}
+	data := struct {
+		Stocks []*stock
+		Maps   []map[string]int
+	}{
+		Stocks: []*stock{{"a", 10, 0}, {"b", 30, 0}, {"c", 10, 0}, {"d", 5, 0}, nil},
+		Maps:   []map[string]int{{"V": 2}, {"V": 1}, {"V": 2}},
+	}
+
+	tests := []struct {
+		script   string
+		expected string
+	}{
+		{`{{tablex (enumerate (head .Stocks 2) "N") 0 8 1}}`, "N Name Volume\n1 a    10\n2 b    30\n"},
+		{`{{range rank (head .Stocks 4) "Volume" "dsc"}}{{.Rank}}{{.Name}} {{end}}`, "1b 2a 2c 4d"},
+		{`{{range rank (head .Stocks 4) "Volume" "dsc" "dense"}}{{.Rank}}{{.Name}} {{end}}`, "1b 2a 2c 3d"},
+		{`{{range rank (head .Stocks 4) "Volume"}}{{.Rank}}{{.Name}} {{end}}`, "1d 2a 2c 4b"},
+		{`{{range enumerate .Stocks "N"}}{{.N}}{{.Name}} {{end}}`, "1a 2b 3c 4d 5"},
+		{`{{range rank .Maps "V" "dense" "dsc"}}{{.Rank}}{{.V}} {{end}}`, "12 12 21"},
+		{`{{range enumerate (rank .Maps "V") "Row"}}{{.Row}}{{.Rank}} {{end}}`, "11 22 32"},
+	}
+	for _, tt := range tests {
+		testTemplateOutput(t, "rank", tt.script, data, tt.expected)
+	}
+
+	invalid := []string{
+		`{{enumerate .Stocks "Name"}}`,
+		`{{enumerate .Stocks "rank"}}`,
+		`{{rank .Stocks "Price"}}`,
+		`{{rank .Stocks "Volume" "up"}}`,
+		`{{rank (rank .Maps "V") "V"}}`,
+	}
+	for _, script := range invalid {
+		if err := OutputToTemplate(ioutil.Discard, "rank", script, data, nil); err == nil {
+			t.Errorf("%s: error expected", script)
+		}
+	}
+}
+
+// Check fromjson and fromcsv
+//
+// Decode JSON and CSV strings and pass the results to table, sort and
+// filter.
+//
+// The output of each script should match the expected output and invalid
+// input should result in an error.
+func TestFromJSONCSV(t *testing.T) {
+	data := struct{ JSON, CSV string }{
+		JSON: `[{"Name": "b", "Size": 2}, {"Name": "a", "Size": 10, "Tags": ["x"]}]`,
+		CSV:  "Name,Size\nb,2\na,10\n",
+	}
+	tests := []struct {
+		script   string
+		expected string
+	}{
+		{`{{printf "%T" (fromjson .JSON)}}`, "[]map[string]interface {}"},
+		{`{{range sort (fromjson .JSON) "Size" "dsc"}}{{.Name}}{{end}}`, "ab"},
+		{`{{select (filter (fromjson .JSON) "Name" "a") "Size"}}`, "10\n"},
+		{`{{(fromjson "{\"a\": {\"b\": [1, 2]}}").a.b}}`, "[1 2]"},
+		{`{{with fromjson "{\"V\": 6395624278, \"F\": 2.5, \"E\": 1e3}"}}{{.V}} {{printf "%T %T" .V .F}} {{.E}}{{end}}`,
+			"6395624278 int64 float64 1000"},
+		{`{{tablex (fromjson "[{\"Volume\": 6395624278}]") 0 8 1}}`, "Volume     \n6395624278 \n"},
+		{`{{range sort (fromcsv .CSV) "Size"}}{{.Name}}{{end}}`, "ba"},
+		{`{{printf "%T" (index (fromcsv .CSV "noheader") 0).Col2}}`, "string"},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := OutputToTemplate(&b, "decode", tt.script, data, nil); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.script, err)
+			continue
+		}
+		if b.String() != tt.expected {
+			t.Errorf("%s: expected %q got %q", tt.script, tt.expected, b.String())
+		}
+	}
+
+	invalid := []string{
+		`{{fromjson "{"}}`,
+		`{{fromjson "{} {}"}}`,
+		`{{fromcsv "a,b\n1"}}`,
+		`{{fromcsv "a\n\"1"}}`,
+	}
+	for _, script := range invalid {
+		if err := OutputToTemplate(ioutil.Discard, "decode", script, data, nil); err == nil {
+			t.Errorf("%s: error expected", script)
+		}
+	}
+}
+
+// Check the YAML parser used by fromyaml
+//
+// Parse a series of YAML documents that use each of the supported features.
+//
+// The decoded values should match the expected values.  Unsupported or
+// malformed YAML should result in an ExecError.
+func TestFromYAML(t *testing.T) {
+	tests := []struct {
+		yaml     string
+		expected interface{}
+	}{
+		{"", nil},
+		{"hello", "hello"},
+		{"a: 1\nb: 2.5\nc: true\nd: ~\ne: text # comment\n",
+			map[string]interface{}{"a": 1, "b": 2.5, "c": true, "d": nil, "e": "text"}},
+		{"# header\n---\n- 1\n- two\n-   'three # not a comment'\n",
+			[]interface{}{1, "two", "three # not a comment"}},
+		{"hosts:\n  - name: a\n    ip: 10.0.0.1\n  - name: b\n    ports: [80, 443]\n",
+			map[string]interface{}{"hosts": []map[string]interface{}{
+				{"name": "a", "ip": "10.0.0.1"},
+				{"name": "b", "ports": []interface{}{80, 443}},
+			}}},
+		{"list:\n- a\n- b\nnext:\n  nested:\n    deep: \"x: y\"\n",
+			map[string]interface{}{
+				"list": []interface{}{"a", "b"},
+				"next": map[string]interface{}{"nested": map[string]interface{}{"deep": "x: y"}},
+			}},
+		{"- - 1\n  - 2\n- {a: 1, 'b c': [x, \"y\"]}\n",
+			[]interface{}{
+				[]interface{}{1, 2},
+				map[string]interface{}{"a": 1, "b c": []interface{}{"x", "y"}},
+			}},
+		{"lit: |\n  line 1\n    indented\n\n  line 3\nfold: >-\n  a\n  b\n\n  c\nurl: http://host:80/\n",
+			map[string]interface{}{
+				"lit":  "line 1\n  indented\n\nline 3\n",
+				"fold": "a b\nc",
+				"url":  "http://host:80/",
+			}},
+		{"'it''s': \"tab\\there\"\nempty:\n", map[string]interface{}{"it's": "tab\there", "empty": nil}},
+		{"---\na: '*x'\nb: 1.5e300\n...\n", map[string]interface{}{"a": "*x", "b": 1.5e300}},
+	}
+	nested := strings.Repeat("[", maxYAMLDepth) + strings.Repeat("]", maxYAMLDepth)
+	if _, err := DecodeYAML([]byte(nested)); err != nil {
+		t.Errorf("unexpected error decoding %d nested sequences: %v", maxYAMLDepth, err)
+	}
+
+	for _, tt := range tests {
+		got := fromYAML(tt.yaml)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%q: expected %#v got %#v", tt.yaml, tt.expected, got)
+		}
+	}
+
+	invalid := []string{
+		"a: 1\na: 2\n",
+		"a: 1\n  b: 2\n",
+		"a:\n\t- 1\n",
+		"[1, 2\n",
+		"a: 1\n- b\n",
+		"a: |2\n  x\n",
+		"a: 1\n---\nb: 2\n",
+		"a: 1\n...\nb: 2\n",
+		"a: &x 1\nb: *x\n",
+		"- !!str 1\n",
+		"&x a: 1\n",
+		"[*x]\n",
+		"12345678901234567890\n",
+		strings.Repeat("[", 100000),
+		"a: " + strings.Repeat("{b: ", 100000),
+		strings.Repeat("- ", 100000) + "1\n",
+	}
+	for _, src := range invalid {
+		func() {
+			defer func() {
+				if _, ok := recover().(template.ExecError); !ok {
+					t.Errorf("%q: expected ExecError", src)
+				}
+			}()
+			_ = fromYAML(src)
+		}()
+	}
+}
+
+type completeMethods struct {
+	Name string
+	Tags map[string]string
+	Sub  *struct{ Value int }
+	priv int
+}
+
+func (c completeMethods) Len() int              { return len(c.Name) }
+func (c *completeMethods) Check() (bool, error) { return true, nil }
+func (c completeMethods) Set(v int)             {}
+
+func TestComplete(t *testing.T) {
+	obj := &completeMethods{
+		Name: "x",
+		Tags: map[string]string{"env": "prod", "empty": ""},
+	}
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{"{{.", []string{".Check", ".Len", ".Name", ".Sub", ".Tags"}},
+		{"{{.N", []string{".Name"}},
+		{"{{.Tags.e", []string{".Tags.empty", ".Tags.env"}},
+		{"{{.Sub.", []string{".Sub.Value"}},
+		{"{{len $.L", []string{"$.Len"}},
+		{"{{.Name.", nil},
+		{"{{.Missing.", nil},
+		{"{{fil", []string{"filter", "filterContains", "filterFolded",
+			"filterHasPrefix", "filterHasSuffix", "filterNil", "filterNotNil",
+			"filterRegexp"}},
+		{"{{table (pri", []string{"print", "printf", "println"}},
+		{"{{en", []string{"end", "entries", "enumerate"}},
+	}
+	for _, tt := range tests {
+		got := Complete(tt.text, obj, nil)
+		if len(got) != len(tt.expected) || (len(got) > 0 && !reflect.DeepEqual(got, tt.expected)) {
+			t.Errorf("%q: expected %v got %v", tt.text, tt.expected, got)
+		}
+	}
+
+	cfg := NewConfig(OptHead)
+	got := Complete("{{he", obj, cfg)
+	if !reflect.DeepEqual(got, []string{"head"}) {
+		t.Errorf("expected [head] got %v", got)
+	}
+}
+
+func TestREPL(t *testing.T) {
+	input := ":help head\nrows .\n!!\n!5\n!x\n:help missing\n:nope\n:complete len (hea\nhe\t\n:quit\nlen .\n"
+	var buf bytes.Buffer
+	if err := REPL(strings.NewReader(input), &buf, []int{1, 2}, NewConfig(OptHead)); err != nil {
+		t.Fatalf("REPL failed: %v", err)
+	}
+
+	out := buf.String()
+	for _, s := range []string{
+		"'head' operates",
+		"error: template: repl:1: function \"rows\" not defined",
+		"error: no history entry !5",
+		"error: invalid history reference !x",
+		"error: missing is not defined",
+		"error: unknown command :nope",
+		"> head\n",
+		"error: template: repl:1: function \"he\" not defined",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected %q in output\n%s", s, out)
+		}
+	}
+	if strings.Contains(out, "> 2") {
+		t.Errorf("REPL did not exit on :quit\n%s", out)
+	}
+}
+
+func TestREPLLineEditing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tfortools")
+	if err != nil {
+		t.Fatalf("Unable to create temporary directory: %v", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	history := filepath.Join(dir, "history")
+
+	input := "rows\x7f\x7f\x7f\x7flen .\r" +
+		"\x1b[A\r" +
+		"he\t . 1\r" +
+		"l\t\x15" +
+		"x\x1b[D\x01y\x05z\r" +
+		"junk\x03" +
+		"\x04"
+	var buf bytes.Buffer
+	cfg := NewConfig(OptHead, OptREPLLineEditing, OptREPLHistory(history))
+	if err := REPL(strings.NewReader(input), &buf, []int{1, 2}, cfg); err != nil {
+		t.Fatalf("REPL failed: %v", err)
+	}
+
+	out := buf.String()
+	for _, s := range []string{
+		"\r> len .\x1b[K\n2\n",
+		"\r> head . 1\x1b[K\n[1]\n",
+		"\nle  len  lt\n",
+		"error: template: repl:1: function \"yxz\" not defined",
+		"junk\x1b[K^C\n",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected %q in output\n%q", s, out)
+		}
+	}
+	if strings.Count(out, "\n2\n") != 2 {
+		t.Errorf("expected the up arrow to recall len .\n%q", out)
+	}
+
+	saved, err := ioutil.ReadFile(history)
+	if err != nil {
+		t.Fatalf("Unable to read history: %v", err)
+	}
+	if string(saved) != "len .\nlen .\nhead . 1\nyxz\n" {
+		t.Errorf("unexpected history %q", saved)
+	}
+
+	buf.Reset()
+	cfg = NewConfig(OptHead, OptREPLHistory(history))
+	if err := REPL(strings.NewReader(":history\n!3\n"), &buf, []int{1, 2}, cfg); err != nil {
+		t.Fatalf("REPL failed: %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, "   4  yxz\n") || !strings.Contains(out, "head . 1\n[1]\n") {
+		t.Errorf("history was not restored\n%s", out)
+	}
+}
+
+type checkRow struct {
+	Name   string
+	Volume int
+	Owner  *struct{ ID int }
+	Tags   map[string]string
+}
+
+func (c checkRow) Total() int { return c.Volume }
+
+func TestCheck(t *testing.T) {
+	valid := []string{
+		`{{sort . "Volume" "dsc"}}`,
+		`{{range .}}{{.Name}} {{.Owner.ID}} {{.Tags.anything}} {{.Total}}{{end}}`,
+		`{{range $i, $r := .}}{{$i}} {{$r.Name}}{{end}}`,
+		`{{with index . 0}}{{.Name}}{{else}}{{len .}}{{end}}`,
+		`{{range cols . "Name" "Volume"}}{{.Volume}}{{end}}`,
+		`{{range promote . "Owner"}}{{.ID}}{{end}}`,
+		`{{filter . "Owner.ID" "1" | head | len}}`,
+		`{{range enumerate . "Index"}}{{.Index}} {{.Name}}{{end}}`,
+		`{{range (rank . "Volume")}}{{.Rank}}{{end}}`,
+		`{{range zip . (promote . "Name")}}{{.Item1.Name}}{{.Item2}}{{end}}`,
+		`{{range entries (index . 0).Tags}}{{.Key}}{{.Value}}{{end}}`,
+		`{{$x := index . 0}}{{$x = index . 1}}{{$x.Name}}`,
+		`{{range (fromjson "[]")}}{{.Anything}}{{end}}`,
+		`{{define "row"}}{{.Name}}{{end}}{{range .}}{{template "row" .}}{{end}}`,
+	}
+	for _, src := range valid {
+		if err := Check(src, []checkRow{}, nil); err != nil {
+			t.Errorf("%s: unexpected error %v", src, err)
+		}
+	}
+
+	invalid := []struct {
+		src      string
+		expected string
+	}{
+		{`{{sort . "Volme"}}`, "1:9: sort: Volme is not a valid field name, did you mean Volume? " +
+			"Valid fields are: Name, Volume, Owner, Tags"},
+		{"{{range .}}\n{{.Nme}}{{end}}", "2:2: can't evaluate field Nme in type tfortools.checkRow, " +
+			"did you mean Name? Valid fields are: Total, Name, Volume, Owner, Tags"},
+		{`{{range cols . "Name"}}{{.Volume}}{{end}}`,
+			"1:25: can't evaluate field Volume in type struct { Name string }. Valid fields are: Name"},
+		{`{{cols . "Name" "Bad"}}`, "1:16: cols: Bad is not a valid field name. " +
+			"Valid fields are: Name, Volume, Owner, Tags"},
+		{`{{range promote . "Owner"}}{{.Name}}{{end}}`,
+			"1:29: can't evaluate field Name in type struct { ID int }. Valid fields are: ID"},
+		{`{{filter . "Owner.Name" "x"}}`, "1:11: filter: Name is not a valid field name. Valid fields are: ID"},
+		{`{{range $r := .}}{{$r.Owner.Bad}}{{end}}`,
+			"1:21: can't evaluate field Bad in type struct { ID int }. Valid fields are: ID"},
+		{`{{enumerate . "Name"}}`,
+			"1:2: enumerate: tfortools.checkRow already contains a field called Name"},
+		{`{{head}}`, "1:2: wrong number of args for head: want at least 1 got 0"},
+		{`{{. | promote "Owner" "x"}}`, "1:6: wrong number of args for promote: want 2 got 3"},
+		{`{{promote .}}`, "1:2: wrong number of args for promote: want 2 got 1"},
+		{`{{not 1 2}}`, "1:2: wrong number of args for not: want 1 got 2"},
+		{`{{define "t"}}{{.Bad}}{{end}}{{template "t" index . 0}}`,
+			"1:16: can't evaluate field Bad in type tfortools.checkRow. " +
+				"Valid fields are: Total, Name, Volume, Owner, Tags"},
+		{`{{.Name}}{{len}}`, "1:2: can't evaluate field Name in type []tfortools.checkRow\n" +
+			"1:11: wrong number of args for len: want 1 got 0"},
+	}
+	for _, tt := range invalid {
+		err := Check(tt.src, reflect.TypeOf([]checkRow{}), nil)
+		if _, ok := err.(CheckErrors); !ok || err.Error() != tt.expected {
+			t.Errorf("%s: expected %q got %v", tt.src, tt.expected, err)
+		}
+	}
+
+	if _, ok := Check("{{", nil, nil).(CheckErrors); ok {
+		t.Errorf("parse errors should not be returned as CheckErrors")
+	}
+	if err := Check(`{{head .}}`, []int{}, NewConfig(OptTail)); err == nil {
+		t.Errorf("expected error for disabled function")
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	data := []struct {
+		Name   string
+		Volume int
+	}{{"a", 1}}
+	maps := []map[string]int{{"count": 1, "size": 2}}
+
+	tests := []struct {
+		script   string
+		obj      interface{}
+		expected string
+	}{
+		{`{{sort . "Volme"}}`, data,
+			"Volme is not a valid field name, did you mean Volume? Valid fields are: Name, Volume"},
+		{`{{sort . "volume"}}`, data, "did you mean Volume?"},
+		{`{{cols . "Name" "Price"}}`, data,
+			"Price is not a valid field name. Valid fields are: Name, Volume"},
+		{`{{filter . "Nmae" "a"}}`, data, "did you mean Name?"},
+		{`{{promote . "Vol"}}`, data, "Field Vol not found. Valid fields are: Name, Volume"},
+		{`{{formatCol . "Nam" "%s"}}`, data, "did you mean Name?"},
+		{`{{cols . "cont"}}`, maps, "did you mean count? Valid fields are: count, size"},
+		{`{{sort . "szie"}}`, maps, "did you mean size?"},
+		{`{{hed .}}`, data, `function "hed" not defined, did you mean head?`},
+		{`{{tabel .}}`, data, "did you mean table?"},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		err := OutputToTemplate(&b, "suggest", tt.script, tt.obj, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: expected error containing %q got %v", tt.script, tt.expected, err)
+		}
+	}
+
+	_, err := CreateTemplate("suggest", "{{xyzzy .}}", nil)
+	if err == nil || strings.Contains(err.Error(), "did you mean") {
+		t.Errorf("unexpected suggestion in %v", err)
+	}
+
+	names := closestNames("Nmae", []string{"Name", "Game", "Volume", "name"})
+	if !reflect.DeepEqual(names, []string{"Name", "name"}) {
+		t.Errorf("unexpected suggestions %v", names)
+	}
+}
+
+func TestError(t *testing.T) {
+	data := []struct {
+		Name   string
+		Volume int
+	}{{"a", 1}}
+
+	tests := []struct {
+		script   string
+		obj      interface{}
+		fn       string
+		arg      string
+		kind     ErrorKind
+		location string
+	}{
+		{`{{sort . "Volme"}}`, data, "sort", "Volme", KindBadField, "error:1:2"},
+		{"\n  {{promote . \"X\"}}", data, "promote", "X", KindBadField, "error:2:4"},
+		{`{{enumerate . "name"}}`, data, "enumerate", "name", KindBadField, "error:1:2"},
+		{`{{head 1}}`, data, "head", "", KindBadType, "error:1:2"},
+		{`{{filter 1 "a" "b"}}`, data, "filter", "", KindBadType, "error:1:2"},
+		{`{{tablex . 1 1 1 "a" "b" "c"}}`, data, "tablex", "", KindBadArity, "error:1:2"},
+		{`{{sort . "Name" "up"}}`, data, "sort", "", KindBadValue, "error:1:2"},
+		{`{{with .}}{{div 1 0}}{{end}}`, data, "div", "", KindBadValue, "error:1:12"},
+	}
+	for _, tt := range tests {
+		err := OutputToTemplate(ioutil.Discard, "error", tt.script, tt.obj, nil)
+		e := findError(err)
+		if e == nil {
+			t.Errorf("%s: expected *Error got %v", tt.script, err)
+			continue
+		}
+		if e.Func != tt.fn || e.Arg != tt.arg || e.Kind != tt.kind || e.Location != tt.location {
+			t.Errorf("%s: unexpected error %+v (%s)", tt.script, *e, e.Kind)
+		}
+	}
+
+	if findError(OutputToTemplate(ioutil.Discard, "error", "{{.X}}", data, nil)) != nil {
+		t.Errorf("*Error returned for error not raised by tfortools")
+	}
+	if KindInternal.String() != "internal error" || ErrorKind(99).String() != "ErrorKind(99)" {
+		t.Errorf("unexpected ErrorKind names")
+	}
+}
+
+type cancelData struct {
+	Rows   []struct{ Name string }
+	cancel context.CancelFunc
+}
+
+func (c *cancelData) Stop() bool {
+	c.cancel()
+	return true
+}
+
+func TestOutputToTemplateContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	data := &cancelData{cancel: cancel}
+	data.Rows = make([]struct{ Name string }, 10000)
+	for i := range data.Rows {
+		data.Rows[i].Name = strconv.Itoa(len(data.Rows) - i)
+	}
+
+	var b bytes.Buffer
+	script := `{{len (filter (sort .Rows "Name") "Name" "1")}}`
+	if err := OutputToTemplateContext(ctx, &b, "ctx", script, data, nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if b.String() != "1" {
+		t.Errorf("expected 1 got %s", b.String())
+	}
+
+	for _, script := range []string{
+		`{{$s := .Stop}}{{$x := sort .Rows "Name"}}`,
+		`{{$s := .Stop}}{{$x := filter .Rows "Name" "1"}}`,
+		`{{$s := .Stop}}{{$x := cols .Rows "Name"}}`,
+		`{{$s := .Stop}}{{$x := table .Rows}}`,
+		`{{$s := .Stop}}{{$x := union .Rows .Rows "Name"}}`,
+		`{{$s := .Stop}}{{$x := rank .Rows "Name"}}`,
+		`{{$s := .Stop}}{{range .Rows}}{{.Name}}{{end}}`,
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		data.cancel = cancel
+		b.Reset()
+		err := OutputToTemplateContext(ctx, &b, "ctx", script, data, nil)
+		if err != context.Canceled {
+			t.Errorf("%s: expected %v got %v", script, context.Canceled, err)
+		}
+		if b.Len() != 0 {
+			t.Errorf("%s: unexpected output after cancellation", script)
+		}
+	}
+
+	cancel()
+	err := OutputToTemplateContext(ctx, &b, "ctx", "{{.}}", data, nil)
+	if err != context.Canceled {
+		t.Errorf("expected %v got %v", context.Canceled, err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	data := make([]struct{ Name string }, 100)
+	for i := range data {
+		data[i].Name = strconv.Itoa(i)
+	}
+
+	tests := []struct {
+		script string
+		opt    func(*Config)
+		err    *LimitError
+		output string
+	}{
+		{`{{range .}}{{.Name}}{{end}}`, OptMaxOutput(5), &LimitError{ResourceOutput, 5}, "01234"},
+		{`{{len .}}`, OptMaxOutput(3), nil, "100"},
+		{`{{range .}}{{end}}`, OptMaxIterations(99), &LimitError{ResourceIterations, 99}, ""},
+		{`{{range .}}{{end}}`, OptMaxIterations(100), nil, ""},
+		{`{{range $i, $e := .}}{{range $}}{{end}}{{end}}`, OptMaxIterations(1000),
+			&LimitError{ResourceIterations, 1000}, ""},
+		{`{{range (head . 2)}}{{range (head $ 3)}}x{{end}}{{end}}`, OptMaxIterations(8), nil, "xxxxxx"},
+		{`{{range .}}{{.Name}}{{break}}{{else}}empty{{end}}`, OptMaxIterations(100), nil, "0"},
+		{`{{len (cols . "Name")}}`, OptMaxSliceLen(99), &LimitError{ResourceSliceLen, 99}, ""},
+		{`{{len (head . 99)}}`, OptMaxSliceLen(99), nil, "99"},
+		{`{{len (seq 1000000000)}}`, OptMaxSliceLen(10), &LimitError{ResourceSliceLen, 10}, ""},
+		{`{{len (rows . 1 2 3)}}`, OptMaxSliceLen(3), nil, "3"},
+		{`{{len (seq -9223372036854775808 9223372036854775807)}}`, OptMaxSliceLen(10),
+			&LimitError{ResourceSliceLen, 10}, ""},
+		{`{{seq 9223372036854775800 9223372036854775807 3}}`, OptMaxSliceLen(10), nil,
+			"[9223372036854775800 9223372036854775803 9223372036854775806]"},
+		{`{{len (chunk . 1)}}`, OptMaxSliceLen(50), &LimitError{ResourceSliceLen, 50}, ""},
+		{`{{len (chunk . 2)}}`, OptMaxSliceLen(50), nil, "50"},
+		{`{{len (zip . .)}}`, OptMaxSliceLen(50), &LimitError{ResourceSliceLen, 50}, ""},
+		{`{{len (flattenSlices (chunk . 10))}}`, OptMaxSliceLen(50), &LimitError{ResourceSliceLen, 50}, ""},
+		{`{{len (padLeft "" 100000000)}}`, OptMaxOutput(10), &LimitError{ResourceOutput, 10}, ""},
+		{`{{padRight "ab" 4 "."}}`, OptMaxOutput(10), nil, "ab.."},
+		{`{{len (padRight "" 100000000)}}`, OptMaxSliceLen(50), &LimitError{ResourceSliceLen, 50}, ""},
+		{`{{define "r"}}{{if .}}{{template "r" (slice . 1)}}{{end}}{{end}}{{template "r" (head . 5)}}`,
+			OptMaxDepth(5), &LimitError{ResourceDepth, 5}, ""},
+		{`{{define "r"}}{{if .}}{{template "r" (slice . 1)}}{{end}}{{end}}{{template "r" (head . 4)}}`,
+			OptMaxDepth(5), nil, ""},
+		{`{{define "a"}}a{{end}}{{template "a"}}{{template "a"}}{{template "a"}}`,
+			OptMaxDepth(1), nil, "aaa"},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		cfg := NewConfig(OptAllFns, tt.opt)
+		err := OutputToTemplate(&b, "limits", tt.script, data, cfg)
+		if tt.err == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.script, err)
+			}
+		} else if lErr, ok := err.(*LimitError); !ok || *lErr != *tt.err {
+			t.Errorf("%s: expected %v got %v", tt.script, tt.err, err)
+		}
+		if b.String() != tt.output {
+			t.Errorf("%s: expected output %q got %q", tt.script, tt.output, b.String())
+		}
+	}
+
+	err := OutputToTemplate(ioutil.Discard, "limits", `{{tfortoolsLeave}}`, data,
+		NewConfig(OptAllFns, OptMaxDepth(1)))
+	if err == nil || !strings.Contains(err.Error(), "not defined") {
+		t.Errorf("limit functions should not be callable from templates: %v", err)
+	}
+
+	// Only the limits enforced by the template functions apply to
+	// templates created by CreateTemplate.
+
+	tmpl, err := CreateTemplate("limits", `{{len (seq 100)}}`,
+		NewConfig(OptAllFns, OptMaxSliceLen(10), OptMaxIterations(1)))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := tmpl.Execute(ioutil.Discard, data); findLimitError(err) == nil {
+		t.Errorf("expected the slice length limit to be enforced: %v", err)
+	}
+	tmpl, err = CreateTemplate("limits", `{{range .}}x{{end}}`,
+		NewConfig(OptAllFns, OptMaxSliceLen(10), OptMaxIterations(1)))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := tmpl.Execute(ioutil.Discard, data); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	lErr := &LimitError{ResourceDepth, 3}
+	if lErr.Error() != "template depth limit of 3 exceeded" || Resource(9).String() != "Resource(9)" {
+		t.Errorf("unexpected error message %s", lErr)
+	}
+}
+
+type sandboxData struct {
+	Name  string
+	count int
+}
+
+func (s *sandboxData) Bump() int          { s.count++; return s.count }
+func (s sandboxData) Title() string       { return strings.ToUpper(s.Name) }
+func (s sandboxData) Size() (int, error)  { return len(s.Name), nil }
+func (s sandboxData) Greet(n string) bool { return n == s.Name }
+func (s *sandboxData) Close() error       { s.count++; return nil }
+func (s *sandboxData) Delete() string {
+	s.Name = ""
+	return "deleted"
+}
+
+func TestSandbox(t *testing.T) {
+	tests := []struct {
+		script string
+		opt    func(*Config)
+		err    string
+		output string
+	}{
+		{`{{range .}}{{.Bump}}{{end}}`, nil, "", "11"},
+		{`{{range .}}{{.Bump}}{{end}}`, OptSandbox, "1:13: method Bump of type tfortools.sandboxData is not permitted", ""},
+		{`{{range .}}{{.Name}}{{end}}`, OptSandbox, "", "ab"},
+		{`{{with index . 0}}{{.Title}}{{end}}`, OptSandbox, "method Title", ""},
+		{`{{with index . 0}}{{.Title}}{{end}}`, OptAllowMethods(sandboxData{}, "Title"), "", "A"},
+		{`{{(index . 1).Title}}`, OptAllowMethods(&sandboxData{}, "Title"), "", "B"},
+		{`{{range .}}{{.Bump}}{{end}}`, OptAllowMethods(sandboxData{}, "Title"), "method Bump", ""},
+		{`{{range .}}{{.Size}}{{.Title}}{{end}}`, OptAllowGetters, "", "1A1B"},
+		{`{{range .}}{{.Greet "a"}}{{end}}`, OptAllowGetters, "method Greet", ""},
+		{`{{range .}}{{.Close}}{{end}}`, OptAllowGetters, "method Close", ""},
+		{`{{$x := index . 0}}{{$x.Greet "a"}}`, OptAllowMethods(sandboxData{}, "Greet"), "", "true"},
+		{`{{define "t"}}{{.Bump}}{{end}}{{template "t" index . 0}}`, OptSandbox, "method Bump", ""},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		data := []sandboxData{{Name: "a"}, {Name: "b"}}
+		cfg := NewConfig(OptAllFns)
+		if tt.opt != nil {
+			cfg = NewConfig(OptAllFns, tt.opt)
+		}
+		err := OutputToTemplate(&b, "sandbox", tt.script, data, cfg)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.script, err)
+			}
+		} else if _, ok := err.(CheckErrors); !ok || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected error %q got %v", tt.script, tt.err, err)
+		}
+		if b.String() != tt.output {
+			t.Errorf("%s: expected output %q got %q", tt.script, tt.output, b.String())
+		}
+		if data[0].count != 0 && tt.err != "" {
+			t.Errorf("%s: rejected template was executed", tt.script)
+		}
+	}
+
+	// The types of these values are not known until the template is
+	// executed, so their methods are checked as it executes.
+
+	dynamic := []struct {
+		script string
+		opt    func(*Config)
+		output string
+	}{
+		{`{{(coalesce .).Delete}}`, OptSandbox, ""},
+		{`{{range list .}}{{.Delete}}{{end}}`, OptSandbox, ""},
+		{`{{(ternary true . .).Delete}}`, OptSandbox, ""},
+		{`{{$x := 1}}{{$x = .}}{{$x.Delete}}`, OptSandbox, ""},
+		{`{{define "t"}}{{.Delete}}{{end}}{{if false}}{{template "t" 1}}{{end}}{{template "t" (coalesce .)}}`,
+			OptSandbox, ""},
+		{`{{(coalesce .).Title}}`, OptAllowMethods(sandboxData{}, "Title"), "A"},
+		{`{{range list .}}{{.Name}}{{.Size}}{{end}}`, OptAllowGetters, "a1"},
+		{`{{(coalesce .).Delete}}`, OptAllowMethods(sandboxData{}, "Delete"), "deleted"},
+	}
+	for _, tt := range dynamic {
+		var b bytes.Buffer
+		data := &sandboxData{Name: "a"}
+		err := OutputToTemplate(&b, "sandbox", tt.script, data, NewConfig(OptAllFns, tt.opt))
+		if tt.output == "" {
+			if err == nil || !strings.Contains(err.Error(), "method Delete of type") {
+				t.Errorf("%s: expected Delete to be rejected, got %v", tt.script, err)
+			}
+			if data.Name != "a" {
+				t.Errorf("%s: Delete was called", tt.script)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.script, err)
+		}
+		if b.String() != tt.output {
+			t.Errorf("%s: expected output %q got %q", tt.script, tt.output, b.String())
+		}
+	}
+
+	data := &sandboxData{Name: "a"}
+	tmpl, err := CreateTemplate("sandbox", `{{.Title}}{{.Delete}}`,
+		NewConfig(OptAllFns, OptAllowMethods(sandboxData{}, "Title")))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, data)
+	if err == nil || data.Name != "a" || out.String() != "A" {
+		t.Errorf("CreateTemplate should check methods as the template executes: %q %v",
+			out.String(), err)
+	}
+
+	cfg := NewConfig(OptAllFns, OptAllowMethods(sandboxData{}, "Title"))
+	if err := Check(`{{range .}}{{.Size}}{{end}}`, []sandboxData{}, cfg); err == nil ||
+		err.Error() != "1:13: method Size of type tfortools.sandboxData is not permitted" {
+		t.Errorf("expected Check to reject Size: %v", err)
+	}
+
+	usage := GenerateUsageDecorated("f", sandboxData{}, cfg)
+	if !strings.Contains(usage, "Title() string") || strings.Contains(usage, "Bump") {
+		t.Errorf("usage should only list permitted methods:\n%s", usage)
+	}
+	var b bytes.Buffer
+	if err := OutputToTemplate(&b, "describe", `{{describe .}}`, sandboxData{},
+		NewConfig(OptDescribe, OptAllowGetters)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !strings.Contains(b.String(), "Size() (int, error)") || strings.Contains(b.String(), "Greet") {
+		t.Errorf("describe should only list permitted methods:\n%s", b.String())
+	}
+	if !strings.Contains(GenerateUsageUndecorated(sandboxData{}), "Greet(string) bool") {
+		t.Errorf("GenerateUsageUndecorated should list all methods")
+	}
+}
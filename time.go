@@ -0,0 +1,119 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tfortools
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// namedLayouts contains the names that can be passed to formatTime,
+// parseTime and formatCol in place of a time layout.
+var namedLayouts = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"rfc1123":     time.RFC1123,
+	"rfc822":      time.RFC822,
+	"ansic":       time.ANSIC,
+	"unix":        time.UnixDate,
+	"kitchen":     time.Kitchen,
+	"stamp":       time.Stamp,
+	"date":        "2006-01-02",
+	"time":        "15:04:05",
+	"datetime":    "2006-01-02 15:04:05",
+}
+
+// timeLayout returns the layout called name, or name itself if it is not
+// one of the namedLayouts.
+func timeLayout(name string) string {
+	if layout, ok := namedLayouts[name]; ok {
+		return layout
+	}
+	return name
+}
+
+func optionalLayout(fnName string, layout []string) string {
+	switch len(layout) {
+	case 0:
+		return time.RFC3339
+	case 1:
+		return timeLayout(layout[0])
+	}
+	fatalArity(fnName, "accepts a maximum of one layout")
+	return ""
+}
+
+func formatTime(obj interface{}, layout ...string) string {
+	return toTime("formatTime", obj).Format(optionalLayout("formatTime", layout))
+}
+
+func parseTime(value string, layout ...string) time.Time {
+	t, err := time.Parse(optionalLayout("parseTime", layout), value)
+	if err != nil {
+		fatalf("parseTime", "%v", err)
+	}
+	return t
+}
+
+func timeTrunc(obj interface{}, unit string) time.Time {
+	t := toTime("timeTrunc", obj)
+	y, m, d := t.Date()
+	loc := t.Location()
+	switch strings.ToLower(unit) {
+	case "year":
+		return time.Date(y, time.January, 1, 0, 0, 0, 0, loc)
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	case "day":
+		return time.Date(y, m, d, 0, 0, 0, 0, loc)
+	case "hour":
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, loc)
+	case "minute":
+		return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, loc)
+	case "second":
+		return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, loc)
+	}
+
+	dur, err := time.ParseDuration(unit)
+	if err != nil || dur <= 0 {
+		fatalf("timeTrunc", "%s is not a valid unit", unit)
+	}
+	return t.Truncate(dur)
+}
+
+func addDuration(obj interface{}, duration interface{}) time.Time {
+	t := toTime("addDuration", obj)
+	val := derefPtr(reflect.ValueOf(duration))
+	if val.Kind() == reflect.String {
+		d, err := time.ParseDuration(val.String())
+		if err != nil {
+			fatalf("addDuration", "%v", err)
+		}
+		return t.Add(d)
+	}
+	return t.Add(toDuration("addDuration", duration))
+}
+
+func inZone(obj interface{}, name string) time.Time {
+	t := toTime("inZone", obj)
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		fatalf("inZone", "%v", err)
+	}
+	return t.In(loc)
+}
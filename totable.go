@@ -0,0 +1,384 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tfortools
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+func sanitizeName(name string) string {
+	var buf bytes.Buffer
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "X"
+	}
+
+	rune, len := utf8.DecodeRuneInString(name)
+	if !unicode.IsLetter(rune) {
+		_, _ = buf.WriteRune('X')
+	} else {
+		_, _ = buf.WriteRune(unicode.ToTitle(rune))
+		name = name[len:]
+	}
+
+	for _, rune := range name {
+		if !unicode.IsLetter(rune) && !unicode.IsDigit(rune) {
+			rune = '_'
+		}
+		_, _ = buf.WriteRune(rune)
+	}
+
+	return buf.String()
+}
+
+// A cellKind identifies the type of value stored in a totable cell.  The
+// kinds are ordered so that a column containing cells of different kinds
+// can be assigned the wider kind, e.g., a column containing ints and floats
+// is a float column.
+type cellKind int
+
+const (
+	cellEmpty cellKind = iota
+	cellInt
+	cellFloat
+	cellBool
+	cellTime
+	cellString
+)
+
+// timeLayouts are the layouts used to recognise times in totable cells.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+var cellTypes = map[cellKind]reflect.Type{
+	cellInt:    reflect.TypeOf(0),
+	cellFloat:  reflect.TypeOf(0.0),
+	cellBool:   reflect.TypeOf(false),
+	cellTime:   timeType,
+	cellString: reflect.TypeOf(""),
+}
+
+func parseCellTime(v string) (time.Time, error) {
+	var err error
+	for _, layout := range timeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, v); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+func parseCellBool(v string) (bool, error) {
+	switch strings.ToLower(v) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("%s is not a bool", v)
+}
+
+func guessKind(v string) cellKind {
+	if v == "" {
+		return cellEmpty
+	}
+	if _, err := strconv.Atoi(v); err == nil {
+		return cellInt
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return cellFloat
+	}
+	if _, err := parseCellBool(v); err == nil {
+		return cellBool
+	}
+	if _, err := parseCellTime(v); err == nil {
+		return cellTime
+	}
+	return cellString
+}
+
+// widenKind returns the narrowest kind that can represent values of both
+// kinds a and b.
+func widenKind(a, b cellKind) cellKind {
+	switch {
+	case a == b || b == cellEmpty:
+		return a
+	case a == cellEmpty:
+		return b
+	case (a == cellInt && b == cellFloat) || (a == cellFloat && b == cellInt):
+		return cellFloat
+	}
+	return cellString
+}
+
+// tableColumn records the kind of the values in a column of a totable
+// input and whether the column contains any empty cells.
+type tableColumn struct {
+	kind     cellKind
+	nullable bool
+}
+
+// fieldType returns the type of the structure field used to store the
+// column.  Columns that contain empty cells are stored in pointer fields,
+// unless they are string columns, in which case empty cells are stored as
+// empty strings.
+func (c tableColumn) fieldType() reflect.Type {
+	if c.kind == cellEmpty {
+		return cellTypes[cellString]
+	}
+	typ := cellTypes[c.kind]
+	if c.nullable && c.kind != cellString {
+		typ = reflect.PtrTo(typ)
+	}
+	return typ
+}
+
+// inferColumns determines the kinds of the columns of rows, examining at
+// most sample rows.  If sample is 0, all the rows are examined.
+func inferColumns(rows [][]string, columns, sample int) []tableColumn {
+	cols := make([]tableColumn, columns)
+	if sample == 0 || sample > len(rows) {
+		sample = len(rows)
+	}
+	for _, row := range rows[:sample] {
+		for j, v := range row {
+			kind := guessKind(v)
+			if kind == cellEmpty {
+				cols[j].nullable = true
+			}
+			cols[j].kind = widenKind(cols[j].kind, kind)
+		}
+	}
+	return cols
+}
+
+func parseCell(kind cellKind, v string) (interface{}, error) {
+	switch kind {
+	case cellInt:
+		return strconv.Atoi(v)
+	case cellFloat:
+		return strconv.ParseFloat(v, 64)
+	case cellBool:
+		return parseCellBool(v)
+	case cellTime:
+		return parseCellTime(v)
+	}
+	return v, nil
+}
+
+// setCell stores the value of a single cell in the field f, whose type was
+// determined by col.  The row and column numbers are used in error messages.
+func setCell(f reflect.Value, col tableColumn, v string, row, column int) {
+	if v == "" && f.Kind() == reflect.Ptr {
+		return
+	}
+	if v == "" && col.kind != cellString && col.kind != cellEmpty {
+		fatalf("totable", "empty cell found in non-nullable column at (%d, %d)",
+			column, row)
+	}
+
+	val, err := parseCell(col.kind, v)
+	if err != nil {
+		fatalf("totable", "%s expected found %s at (%d, %d)",
+			cellTypes[col.kind], v, column, row)
+	}
+	if f.Kind() == reflect.Ptr {
+		p := reflect.New(f.Type().Elem())
+		p.Elem().Set(reflect.ValueOf(val))
+		f.Set(p)
+		return
+	}
+	f.Set(reflect.ValueOf(val))
+}
+
+// schemaTypes maps the type names that can be used in a totable schema to
+// cell kinds.
+var schemaTypes = map[string]cellKind{
+	"int":    cellInt,
+	"float":  cellFloat,
+	"bool":   cellBool,
+	"time":   cellTime,
+	"string": cellString,
+}
+
+// schemaColumn describes a column listed in a totable schema.  If inferred
+// is true, no type was specified for the column.
+type schemaColumn struct {
+	name     string
+	col      tableColumn
+	inferred bool
+}
+
+// parseSchema parses a schema of the form "Name:string,Age:*int".  Types
+// prefixed with a '*' are stored in pointer fields, allowing empty cells.
+// The type may be omitted, in which case it is inferred.
+func parseSchema(schema string) []schemaColumn {
+	var cols []schemaColumn
+	for _, entry := range strings.Split(schema, ",") {
+		parts := strings.SplitN(entry, ":", 2)
+		sc := schemaColumn{name: strings.TrimSpace(parts[0]), inferred: true}
+		if sc.name == "" {
+			fatalf("totable", "missing column name in schema %q", schema)
+		}
+		if len(parts) == 2 {
+			typ := strings.TrimSpace(parts[1])
+			sc.col.nullable = strings.HasPrefix(typ, "*")
+			kind, ok := schemaTypes[strings.TrimPrefix(typ, "*")]
+			if !ok {
+				fatalf("totable", "unknown type %q in schema", typ)
+			}
+			sc.col.kind = kind
+			sc.inferred = false
+		}
+		cols = append(cols, sc)
+	}
+	return cols
+}
+
+// tableOptions holds the options passed to totable.
+type tableOptions struct {
+	sample   int
+	noHeader bool
+	schema   []schemaColumn
+}
+
+func parseTableOptions(options []string) tableOptions {
+	var opts tableOptions
+	for _, opt := range options {
+		switch {
+		case opt == "noheader":
+			opts.noHeader = true
+		case strings.HasPrefix(opt, "sample="):
+			n, err := strconv.Atoi(strings.TrimPrefix(opt, "sample="))
+			if err != nil || n < 1 {
+				fatalf("totable", "invalid sample size %q", opt)
+			}
+			opts.sample = n
+		case strings.HasPrefix(opt, "schema="):
+			opts.schema = parseSchema(strings.TrimPrefix(opt, "schema="))
+		default:
+			fatalf("totable", "unknown option %q", opt)
+		}
+	}
+	return opts
+}
+
+// FieldNames converts names, e.g., the headings of the columns of a table
+// or the keys of a JSON object, into the names of exported structure
+// fields, in the same way as ToTable and the totable function.  Characters
+// that cannot appear in an identifier are replaced by underscores, names
+// that do not start with a letter are prefixed with an X and empty names
+// are converted to X.  Names that are the same after conversion are made
+// unique by appending a suffix, e.g., Name, Name_2.
+func FieldNames(names []string) []string {
+	fields := make([]string, len(names))
+	used := make(map[string]bool, len(names))
+	for i, name := range names {
+		field := sanitizeName(name)
+		unique := field
+		for n := 2; used[unique]; n++ {
+			unique = field + "_" + strconv.Itoa(n)
+		}
+		used[unique] = true
+		fields[i] = unique
+	}
+	return fields
+}
+
+func toTable(data [][]string, options ...string) interface{} {
+	defer recoverExecError("totable", "Invalid use of totable: %v")
+
+	opts := parseTableOptions(options)
+
+	var names []string
+	rows := data
+	switch {
+	case opts.noHeader && len(data) > 0:
+		for i := range data[0] {
+			names = append(names, "Col"+strconv.Itoa(i+1))
+		}
+	case opts.noHeader:
+		fatalf("totable", "Expected at least one row")
+	case len(data) < 2:
+		fatalf("totable", "Expected at least two rows")
+	default:
+		names = data[0]
+		rows = data[1:]
+	}
+
+	if opts.schema != nil {
+		if len(opts.schema) != len(names) {
+			fatalf("totable", "schema describes %d columns but the table has %d",
+				len(opts.schema), len(names))
+		}
+		names = make([]string, len(opts.schema))
+		for i, sc := range opts.schema {
+			names[i] = sc.name
+		}
+	}
+
+	for i, row := range rows {
+		if len(row) != len(names) {
+			fatalf("totable", "row %d has %d columns, expected %d", i, len(row), len(names))
+		}
+	}
+
+	cols := inferColumns(rows, len(names), opts.sample)
+	for i, sc := range opts.schema {
+		if !sc.inferred {
+			cols[i] = sc.col
+		}
+	}
+
+	for i, name := range names {
+		if strings.TrimSpace(name) == "" {
+			fatalf("totable", "column %d does not have a name", i)
+		}
+	}
+
+	fields := make([]reflect.StructField, len(names))
+	for i, name := range FieldNames(names) {
+		fields[i] = reflect.StructField{
+			Name: name,
+			Type: cols[i].fieldType(),
+		}
+	}
+
+	sTyp := reflect.StructOf(fields)
+	newVal := reflect.MakeSlice(reflect.SliceOf(sTyp), len(rows), len(rows))
+	for i, row := range rows {
+		sVal := newVal.Index(i)
+		for j, v := range row {
+			setCell(sVal.Field(j), cols[j], v, i, j)
+		}
+	}
+
+	return newVal.Interface()
+}
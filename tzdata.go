@@ -0,0 +1,25 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.15
+// +build go1.15
+
+package tfortools
+
+// Importing time/tzdata embeds a copy of the time zone database in programs
+// that use this package.  It is only consulted by inZone if the time zone
+// database cannot be found on the host system.
+import _ "time/tzdata"
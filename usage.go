@@ -151,11 +151,12 @@ func formatType(buf *bytes.Buffer, unformattedType []byte) {
 	_, _ = buf.Write(formattedType[len(typePrefix):])
 }
 
-func dumpMethods(buf *bytes.Buffer, typ reflect.Type) {
+func dumpMethods(buf *bytes.Buffer, typ reflect.Type, sb *sandbox) {
 	var i int
 
 	for i = 0; i < typ.NumMethod(); i++ {
-		if typ.Method(i).PkgPath == "" {
+		m := typ.Method(i)
+		if m.PkgPath == "" && sb.permits(typ, m) {
 			break
 		}
 	}
@@ -168,7 +169,7 @@ func dumpMethods(buf *bytes.Buffer, typ reflect.Type) {
 
 	for i = 0; i < typ.NumMethod(); i++ {
 		m := typ.Method(i)
-		if m.PkgPath != "" {
+		if m.PkgPath != "" || !sb.permits(typ, m) {
 			continue
 		}
 		typ := m.Type
@@ -185,7 +186,7 @@ func dumpMethods(buf *bytes.Buffer, typ reflect.Type) {
 			fmt.Fprintf(buf, " %v", typ.Out(0))
 		} else if typ.NumOut() > 1 {
 			fmt.Fprintf(buf, " (")
-			fmt.Fprintf(buf, typ.Out(0).String())
+			fmt.Fprint(buf, typ.Out(0).String())
 			for j := 1; j < typ.NumOut(); j++ {
 				fmt.Fprintf(buf, ", %v", typ.Out(j))
 			}
@@ -195,7 +196,7 @@ func dumpMethods(buf *bytes.Buffer, typ reflect.Type) {
 	}
 }
 
-func generateIndentedUsage(buf *bytes.Buffer, i interface{}) {
+func generateIndentedUsage(buf *bytes.Buffer, i interface{}, sb *sandbox) {
 	var source bytes.Buffer
 	typ := reflect.TypeOf(i)
 
@@ -205,5 +206,5 @@ func generateIndentedUsage(buf *bytes.Buffer, i interface{}) {
 	if typ.Kind() != reflect.Ptr {
 		typ = reflect.PtrTo(typ)
 	}
-	dumpMethods(buf, typ)
+	dumpMethods(buf, typ, sb)
 }
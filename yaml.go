@@ -0,0 +1,514 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tfortools
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// This file contains a parser for the subset of YAML used by most
+// configuration files and APIs.  It supports block mappings and sequences,
+// flow mappings and sequences that fit on a single line, plain, single and
+// double quoted scalars, literal and folded block scalars and comments.
+// Anchors, aliases, tags, multi-line plain scalars and multiple documents
+// are not supported and are reported as errors, as are integers that do
+// not fit in an int and collections nested more than maxYAMLDepth levels
+// deep.  Mappings are decoded into map[string]interface{},
+// sequences into []interface{} and scalars into strings, ints, float64s,
+// bools or nil.
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+	depth int
+
+	// started is true once the content of the document has been found
+	// and ended is true once a document end marker has been found.
+	started bool
+	ended   bool
+}
+
+// maxYAMLDepth is the maximum depth to which collections can be nested.  It
+// prevents hostile documents from exhausting the stack, as the parsers are
+// recursive.
+const maxYAMLDepth = 1000
+
+// yamlIndicators are the characters that introduce anchors, aliases and
+// tags.
+const yamlIndicators = "&*!"
+
+func yamlErrorf(num int, format string, args ...interface{}) {
+	fatalf("fromyaml", "line %d: %s", num, fmt.Sprintf(format, args...))
+}
+
+func newYAMLParser(src string) *yamlParser {
+	src = strings.Replace(src, "\r\n", "\n", -1)
+	rawLines := strings.Split(src, "\n")
+	lines := make([]yamlLine, 0, len(rawLines))
+	for i, raw := range rawLines {
+		text := strings.TrimLeft(raw, " ")
+		line := yamlLine{
+			num:    i + 1,
+			indent: len(raw) - len(text),
+			text:   strings.TrimRight(text, " \t"),
+		}
+		if strings.HasPrefix(line.text, "\t") {
+			yamlErrorf(line.num, "tabs cannot be used for indentation")
+		}
+		lines = append(lines, line)
+	}
+	return &yamlParser{lines: lines}
+}
+
+// stripYAMLComment removes any comment from the end of text.
+func stripYAMLComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.IndexByte(" [{,:", text[i-1]) != -1 {
+				quote = c
+			}
+		case c == '#':
+			if i == 0 || text[i-1] == ' ' || text[i-1] == '\t' {
+				return strings.TrimRight(text[:i], " \t")
+			}
+		}
+	}
+	return text
+}
+
+// peek returns the next line that contains YAML content, skipping blank
+// lines, comments and document markers.  nil is returned when there are no
+// more lines.  Only a single document is supported, so document markers
+// that introduce a second document are reported as errors.
+func (p *yamlParser) peek() *yamlLine {
+	for ; p.pos < len(p.lines); p.pos++ {
+		l := &p.lines[p.pos]
+		text := stripYAMLComment(l.text)
+		if text == "" || (l.indent == 0 && strings.HasPrefix(text, "%")) {
+			continue
+		}
+		if l.indent == 0 && text == "---" {
+			if p.started {
+				yamlErrorf(l.num, "multiple documents are not supported")
+			}
+			continue
+		}
+		if l.indent == 0 && text == "..." {
+			p.ended = true
+			continue
+		}
+		if p.ended {
+			yamlErrorf(l.num, "multiple documents are not supported")
+		}
+		p.started = true
+		return l
+	}
+	return nil
+}
+
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func isYAMLBlockScalar(text string) bool {
+	return strings.HasPrefix(text, "|") || strings.HasPrefix(text, ">")
+}
+
+// splitYAMLKey splits a line of a block mapping into its key and the text
+// that follows the key.  ok is false if text is not a mapping entry.
+func splitYAMLKey(text string) (key, rest string, ok bool) {
+	if text == "" || isYAMLSeqItem(text) || strings.IndexByte("[{#", text[0]) != -1 {
+		return "", "", false
+	}
+
+	end := 0
+	if text[0] == '"' || text[0] == '\'' {
+		end = quotedYAMLEnd(text)
+		if end < 0 {
+			return "", "", false
+		}
+	}
+
+	for i := end; i < len(text); i++ {
+		if text[i] == ':' && (i == len(text)-1 || text[i+1] == ' ') {
+			key = strings.TrimSpace(text[:i])
+			if key[0] == '"' || key[0] == '\'' {
+				key = unquoteYAML(key)
+			}
+			return key, strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// quotedYAMLEnd returns the index of the character following the quoted
+// string at the start of text, or -1 if the string is not terminated.
+func quotedYAMLEnd(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func unquoteYAML(s string) string {
+	if s[0] == '\'' {
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1)
+	}
+	u, err := strconv.Unquote(s)
+	if err != nil {
+		fatalf("fromyaml", "invalid quoted string %s", s)
+	}
+	return u
+}
+
+// parseNode parses the node that starts on the next line, provided that
+// the line is indented by at least minIndent spaces.
+func (p *yamlParser) parseNode(minIndent int) interface{} {
+	l := p.peek()
+	if l == nil || l.indent < minIndent {
+		return nil
+	}
+
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxYAMLDepth {
+		yamlErrorf(l.num, "collections are nested more than %d levels deep", maxYAMLDepth)
+	}
+
+	text := stripYAMLComment(l.text)
+	if isYAMLSeqItem(text) {
+		return p.parseSequence(l.indent)
+	}
+	if _, _, ok := splitYAMLKey(text); ok {
+		return p.parseMapping(l.indent)
+	}
+	p.pos++
+	return parseYAMLScalar(text)
+}
+
+func (p *yamlParser) parseSequence(indent int) []interface{} {
+	seq := []interface{}{}
+	for {
+		l := p.peek()
+		if l == nil || l.indent != indent {
+			break
+		}
+		text := stripYAMLComment(l.text)
+		if !isYAMLSeqItem(text) {
+			break
+		}
+
+		rest := strings.TrimLeft(text[1:], " ")
+		_, _, isKey := splitYAMLKey(rest)
+		switch {
+		case rest == "":
+			p.pos++
+			seq = append(seq, p.parseNode(indent+1))
+		case isYAMLBlockScalar(rest):
+			p.pos++
+			seq = append(seq, p.parseBlockScalar(indent, rest))
+		case isKey || isYAMLSeqItem(rest):
+			// The item is a nested collection that starts on the
+			// same line as the '-'.  Replace the line with one
+			// containing only the collection and parse it.
+			offset := len(text) - len(rest)
+			*l = yamlLine{num: l.num, indent: indent + offset, text: rest}
+			seq = append(seq, p.parseNode(indent+offset))
+		default:
+			p.pos++
+			seq = append(seq, parseYAMLScalar(rest))
+		}
+	}
+	return seq
+}
+
+func (p *yamlParser) parseMapping(indent int) map[string]interface{} {
+	m := make(map[string]interface{})
+	for {
+		l := p.peek()
+		if l == nil || l.indent != indent {
+			break
+		}
+		text := stripYAMLComment(l.text)
+		key, rest, ok := splitYAMLKey(text)
+		if !ok {
+			if isYAMLSeqItem(text) {
+				break
+			}
+			yamlErrorf(l.num, "mapping key expected")
+		}
+		if strings.IndexByte(yamlIndicators, text[0]) != -1 {
+			yamlErrorf(l.num, "anchors, aliases and tags are not supported")
+		}
+		if _, dup := m[key]; dup {
+			yamlErrorf(l.num, "duplicate key %s", key)
+		}
+		p.pos++
+
+		switch {
+		case rest == "":
+			next := p.peek()
+			switch {
+			case next != nil && next.indent > indent:
+				m[key] = p.parseNode(next.indent)
+			case next != nil && next.indent == indent && isYAMLSeqItem(stripYAMLComment(next.text)):
+				m[key] = p.parseSequence(indent)
+			default:
+				m[key] = nil
+			}
+		case isYAMLBlockScalar(rest):
+			m[key] = p.parseBlockScalar(indent, rest)
+		default:
+			m[key] = parseYAMLScalar(rest)
+		}
+	}
+	return m
+}
+
+// parseBlockScalar parses a literal (|) or folded (>) block scalar whose
+// lines are indented by more than parentIndent spaces.  header is the
+// indicator that introduced the scalar, optionally followed by a chomping
+// indicator.
+func (p *yamlParser) parseBlockScalar(parentIndent int, header string) string {
+	folded := header[0] == '>'
+	chomp := header[1:]
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		yamlErrorf(p.lines[p.pos-1].num, "unsupported block scalar header %s", header)
+	}
+
+	var lines []string
+	blockIndent := -1
+	for ; p.pos < len(p.lines); p.pos++ {
+		l := p.lines[p.pos]
+		if l.text == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if l.indent <= parentIndent || (blockIndent != -1 && l.indent < blockIndent) {
+			break
+		}
+		if blockIndent == -1 {
+			blockIndent = l.indent
+		}
+		lines = append(lines, strings.Repeat(" ", l.indent-blockIndent)+l.text)
+	}
+
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	var b bytes.Buffer
+	for i, line := range lines {
+		if folded {
+			if i > 0 && line != "" && lines[i-1] != "" {
+				b.WriteByte(' ')
+			}
+			if line == "" {
+				b.WriteByte('\n')
+			}
+		} else if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(line)
+	}
+
+	switch {
+	case chomp == "-" || len(lines) == 0:
+	case chomp == "+":
+		b.WriteString(strings.Repeat("\n", trailing+1))
+	default:
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// parseYAMLScalar converts a scalar, or a flow collection, into a value.
+func parseYAMLScalar(text string) interface{} {
+	if text == "" {
+		return nil
+	}
+	if strings.IndexByte("[{\"'", text[0]) != -1 {
+		f := &yamlFlowParser{text: text}
+		v := f.parseValue()
+		f.skipSpace()
+		if f.pos != len(f.text) {
+			fatalf("fromyaml", "unexpected text after %s", text[:f.pos])
+		}
+		return v
+	}
+
+	if strings.IndexByte(yamlIndicators, text[0]) != -1 {
+		fatalf("fromyaml", "anchors, aliases and tags are not supported: %s", text)
+	}
+
+	switch text {
+	case "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	i, err := strconv.Atoi(text)
+	if err == nil {
+		return i
+	}
+	if nErr, ok := err.(*strconv.NumError); ok && nErr.Err == strconv.ErrRange {
+		fatalf("fromyaml", "integer %s is out of range", text)
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil {
+		return f
+	}
+	return text
+}
+
+// yamlFlowParser parses flow collections, e.g., [1, 2] and {a: 1}.
+type yamlFlowParser struct {
+	text  string
+	pos   int
+	depth int
+}
+
+func (f *yamlFlowParser) skipSpace() {
+	for f.pos < len(f.text) && f.text[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+func (f *yamlFlowParser) expect(c byte) {
+	f.skipSpace()
+	if f.pos >= len(f.text) || f.text[f.pos] != c {
+		fatalf("fromyaml", "%q expected in %s", c, f.text)
+	}
+	f.pos++
+}
+
+// token returns the next plain or quoted scalar.  Plain scalars end at any
+// of the characters in stop.
+func (f *yamlFlowParser) token(stop string) (string, bool) {
+	f.skipSpace()
+	start := f.pos
+	if f.pos < len(f.text) && (f.text[f.pos] == '"' || f.text[f.pos] == '\'') {
+		end := quotedYAMLEnd(f.text[f.pos:])
+		if end < 0 {
+			fatalf("fromyaml", "unterminated string in %s", f.text)
+		}
+		f.pos += end
+		return unquoteYAML(f.text[start:f.pos]), true
+	}
+	for f.pos < len(f.text) && strings.IndexByte(stop, f.text[f.pos]) == -1 {
+		f.pos++
+	}
+	return strings.TrimSpace(f.text[start:f.pos]), false
+}
+
+func (f *yamlFlowParser) parseValue() interface{} {
+	f.skipSpace()
+	if f.pos >= len(f.text) {
+		return nil
+	}
+
+	if c := f.text[f.pos]; c == '[' || c == '{' {
+		f.depth++
+		defer func() { f.depth-- }()
+		if f.depth > maxYAMLDepth {
+			fatalf("fromyaml", "collections are nested more than %d levels deep", maxYAMLDepth)
+		}
+	}
+
+	switch f.text[f.pos] {
+	case '[':
+		f.pos++
+		seq := []interface{}{}
+		for {
+			f.skipSpace()
+			if f.pos < len(f.text) && f.text[f.pos] == ']' {
+				f.pos++
+				return seq
+			}
+			seq = append(seq, f.parseValue())
+			f.skipSpace()
+			if f.pos < len(f.text) && f.text[f.pos] == ',' {
+				f.pos++
+				continue
+			}
+			f.expect(']')
+			return seq
+		}
+	case '{':
+		f.pos++
+		m := make(map[string]interface{})
+		for {
+			f.skipSpace()
+			if f.pos < len(f.text) && f.text[f.pos] == '}' {
+				f.pos++
+				return m
+			}
+			key, _ := f.token(":,}")
+			f.expect(':')
+			m[key] = f.parseValue()
+			f.skipSpace()
+			if f.pos < len(f.text) && f.text[f.pos] == ',' {
+				f.pos++
+				continue
+			}
+			f.expect('}')
+			return m
+		}
+	}
+
+	tok, quoted := f.token(",]}")
+	if quoted {
+		return tok
+	}
+	return parseYAMLScalar(tok)
+}
+
+func parseYAML(src string) interface{} {
+	p := newYAMLParser(src)
+	v := p.parseNode(0)
+	if l := p.peek(); l != nil {
+		yamlErrorf(l.num, "unexpected content %q", stripYAMLComment(l.text))
+	}
+	return v
+}